@@ -6,6 +6,7 @@ import (
 
 	"github.com/boombuler/goboy2/consts"
 	"github.com/boombuler/goboy2/mmu"
+	"github.com/boombuler/goboy2/savestate"
 )
 
 type audioChannel bool
@@ -107,6 +108,7 @@ type soundChannel interface {
 	Reset()
 	Active() bool
 	Init(noBoot bool)
+	savestate.Stater
 }
 
 func (apu *APU) Init(noBoot bool) {
@@ -0,0 +1,153 @@
+package apu
+
+import (
+	"math"
+	"time"
+
+	"github.com/boombuler/goboy2/savestate"
+)
+
+// SaveState writes the state of the apu and all sound channels.
+// Samples which are already buffered for the audio output are not part of the state.
+func (apu *APU) SaveState(w *savestate.Writer) {
+	w.U8(apu.volumeSelect)
+	w.U8(apu.channelSelect)
+	w.Bool(apu.active)
+	w.U64(uint64(apu.sampleT))
+	w.U32(math.Float32bits(apu.sampleLeft))
+	w.U32(math.Float32bits(apu.sampleRight))
+	w.Int(apu.fs.counter)
+	w.U8(apu.fs.curStep)
+	for _, ch := range apu.generators {
+		ch.SaveState(w)
+	}
+}
+
+// LoadState restores a state written by SaveState.
+func (apu *APU) LoadState(r *savestate.Reader) {
+	apu.volumeSelect = r.U8()
+	apu.channelSelect = r.U8()
+	apu.active = r.Bool()
+	apu.sampleT = time.Duration(r.U64())
+	apu.sampleLeft = math.Float32frombits(r.U32())
+	apu.sampleRight = math.Float32frombits(r.U32())
+	apu.fs.counter = r.Int()
+	apu.fs.curStep = r.U8() % 8
+	for _, ch := range apu.generators {
+		ch.LoadState(r)
+	}
+}
+
+func (ve *volumeEnvelope) saveState(w *savestate.Writer) {
+	w.U8(ve.volume)
+	w.U8(ve.VolumeLoad)
+	w.Bool(ve.Increase)
+	w.U8(ve.period)
+	w.U8(ve.periodLoad)
+}
+
+func (ve *volumeEnvelope) loadState(r *savestate.Reader) {
+	ve.volume = r.U8()
+	ve.VolumeLoad = r.U8()
+	ve.Increase = r.Bool()
+	ve.period = r.U8()
+	ve.periodLoad = r.U8()
+}
+
+func (s *squareWaveGen) SaveState(w *savestate.Writer) {
+	s.ve.saveState(w)
+	w.Int(s.timerCnt)
+	w.U8(s.dutyIdx)
+	w.U8(s.lengthCounter)
+	w.Bool(s.hi)
+	w.Bool(s.dacEnabled)
+	w.U8(s.dutyMode)
+	w.U8(s.lengthLoad)
+	w.Int(s.timerLoad)
+	w.Bool(s.useLength)
+	w.Bool(s.running)
+}
+
+func (s *squareWaveGen) LoadState(r *savestate.Reader) {
+	s.ve.loadState(r)
+	s.timerCnt = r.Int()
+	s.dutyIdx = r.U8() % 8
+	s.lengthCounter = r.U8()
+	s.hi = r.Bool()
+	s.dacEnabled = r.Bool()
+	s.dutyMode = r.U8()
+	s.lengthLoad = r.U8()
+	s.timerLoad = r.Int()
+	s.useLength = r.Bool()
+	s.running = r.Bool()
+}
+
+func (s *sweepSquareWaveGen) SaveState(w *savestate.Writer) {
+	s.squareWaveGen.SaveState(w)
+	w.U8(s.sweepCtrl)
+	w.Int(s.timer)
+	w.Bool(s.overflowed)
+}
+
+func (s *sweepSquareWaveGen) LoadState(r *savestate.Reader) {
+	s.squareWaveGen.LoadState(r)
+	s.sweepCtrl = r.U8()
+	s.timer = r.Int()
+	s.overflowed = r.Bool()
+}
+
+func (wc *waveChannel) SaveState(w *savestate.Writer) {
+	w.Bool(wc.dacEnabled)
+	w.Int(wc.length)
+	w.Int(wc.timerCnt)
+	w.U32(math.Float32bits(wc.sample))
+	w.Bytes(wc.waveRAM)
+	w.Int(wc.pos)
+	w.Bool(wc.useLength)
+	w.Int(wc.timerLoad)
+	w.U8(wc.volume)
+	w.U8(wc.lengthLoad)
+	w.Bool(wc.running)
+}
+
+func (wc *waveChannel) LoadState(r *savestate.Reader) {
+	wc.dacEnabled = r.Bool()
+	wc.length = r.Int()
+	wc.timerCnt = r.Int()
+	wc.sample = math.Float32frombits(r.U32())
+	r.Bytes(wc.waveRAM)
+	wc.pos = r.Int() & 0x1F
+	wc.useLength = r.Bool()
+	wc.timerLoad = r.Int()
+	wc.volume = r.U8() & 0x03
+	wc.lengthLoad = r.U8()
+	wc.running = r.Bool()
+}
+
+func (ng *noiseGen) SaveState(w *savestate.Writer) {
+	ng.ve.saveState(w)
+	w.Bool(ng.hi)
+	w.U16(ng.lfsr)
+	w.Int(ng.timerCnt)
+	w.U8(ng.length)
+	w.U8(ng.lengthLoad)
+	w.Bool(ng.useLength)
+	w.U8(ng.clockShift)
+	w.Bool(ng.widthMode)
+	w.U8(ng.divisor)
+	w.Bool(ng.running)
+}
+
+func (ng *noiseGen) LoadState(r *savestate.Reader) {
+	ng.ve.loadState(r)
+	ng.hi = r.Bool()
+	ng.lfsr = r.U16()
+	ng.timerCnt = r.Int()
+	ng.length = r.U8()
+	ng.lengthLoad = r.U8()
+	ng.useLength = r.Bool()
+	ng.clockShift = r.U8()
+	ng.widthMode = r.Bool()
+	ng.divisor = r.U8() & 0x07
+	ng.running = r.Bool()
+}
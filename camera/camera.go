@@ -0,0 +1,111 @@
+// Package camera provides image sources for the Game Boy Camera.
+package camera
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/boombuler/goboy2/cartridge"
+)
+
+// Static always captures the same image.
+type Static struct {
+	Image image.Image
+}
+
+func (s Static) Capture() image.Image {
+	return s.Image
+}
+
+// Frames captures the images one after another and starts over after the last one.
+type Frames struct {
+	Images []image.Image
+	next   int
+}
+
+func (f *Frames) Capture() image.Image {
+	if len(f.Images) == 0 {
+		return image.NewGray(image.Rect(0, 0, 0, 0))
+	}
+	img := f.Images[f.next]
+	f.next = (f.next + 1) % len(f.Images)
+	return img
+}
+
+// TestPattern returns a generated image with gray bars in the upper half and
+// a checkerboard in the lower half.
+func TestPattern() Static {
+	img := image.NewGray(image.Rect(0, 0, cartridge.CameraWidth, cartridge.CameraHeight))
+	for y := 0; y < cartridge.CameraHeight; y++ {
+		for x := 0; x < cartridge.CameraWidth; x++ {
+			var v uint8
+			if y < cartridge.CameraHeight/2 {
+				v = uint8(x / 16 * 255 / 7)
+			} else if (x/16+y/16)%2 == 0 {
+				v = 0xFF
+			}
+			img.SetGray(x, y, color.Gray{v})
+		}
+	}
+	return Static{img}
+}
+
+func loadPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+// Open creates the image source for the given path. A png file is captured as a
+// static image, the png files of a directory are captured as frames in the order
+// of their names. An empty path returns the test pattern.
+func Open(path string) (cartridge.ImageSource, error) {
+	if path == "" {
+		return TestPattern(), nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		img, err := loadPNG(path)
+		if err != nil {
+			return nil, err
+		}
+		return Static{img}, nil
+	}
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() && strings.EqualFold(filepath.Ext(f.Name()), ".png") {
+			names = append(names, f.Name())
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no png files found in %s", path)
+	}
+	sort.Strings(names)
+	frames := new(Frames)
+	for _, name := range names {
+		img, err := loadPNG(filepath.Join(path, name))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		frames.Images = append(frames.Images, img)
+	}
+	return frames, nil
+}
@@ -0,0 +1,40 @@
+package camera
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goboy2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, name := range []string{"b.png", "a.png", "c.txt"} {
+		img := image.NewGray(image.Rect(0, 0, 1, 1))
+		img.SetGray(0, 0, color.Gray{uint8(i)})
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		png.Encode(f, img)
+		f.Close()
+	}
+
+	src, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []uint8{1, 0, 1} {
+		if v := color.GrayModel.Convert(src.Capture().At(0, 0)).(color.Gray).Y; v != exp {
+			t.Errorf("Expected frame %d but got %d", exp, v)
+		}
+	}
+}
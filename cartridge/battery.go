@@ -1,52 +1,263 @@
 package cartridge
 
 import (
+	"fmt"
 	"io"
-	"log"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
+// Battery stores the battery backed data of a cartridge.
 type Battery interface {
-	Open() BatteryStream
-	HasData() bool
+	// Load returns the stored data or nil if nothing was stored yet.
+	Load() ([]byte, error)
+	// Save replaces the stored data.
+	Save(data []byte) error
 }
 
-type BatteryStream interface {
-	io.Reader
-	io.Writer
-	io.Seeker
-	io.Closer
+type BatteryFactory func() (Battery, error)
+
+// open creates the battery. If there is no factory there is no battery.
+func (bf BatteryFactory) open() (Battery, error) {
+	if bf == nil {
+		return nil, nil
+	}
+	return bf()
 }
 
-type BatteryFactory func() Battery
+// defaultBackups is the number of backups GetBattery keeps.
+const defaultBackups = 3
+
+// BatteryFile stores the data in a file. The file is replaced atomically, so a
+// crash while saving leaves the previous data untouched.
+type BatteryFile struct {
+	path    string
+	legacy  string
+	backups int
+	rotated bool
+}
 
-type BatteryFile string
+// NewBatteryFile creates a battery which stores the data in the given file. Before
+// the file is overwritten for the first time, the previous data is moved to the
+// backup files "path.1" to "path.n".
+func NewBatteryFile(path string, backups int) *BatteryFile {
+	return &BatteryFile{path: path, backups: backups}
+}
 
-func GetBattery(romFileName string) BatteryFile {
+// GetBattery returns the battery file for the given rom. The save data is
+// stored in a ".sav" file which can be exchanged with other emulators. The
+// ".ram" file of older versions is loaded as long as there is no ".sav" file.
+func GetBattery(romFileName string) *BatteryFile {
 	ext := filepath.Ext(romFileName)
 	baseFileName := romFileName[:len(romFileName)-len(ext)]
-	return BatteryFile(baseFileName + ".ram")
+	bf := NewBatteryFile(baseFileName+".sav", defaultBackups)
+	bf.legacy = baseFileName + ".ram"
+	return bf
 }
 
-func (bf BatteryFile) HasData() bool {
-	_, err := os.Stat(string(bf))
-	return !os.IsNotExist(err)
+func (bf *BatteryFile) backupName(i int) string {
+	return fmt.Sprintf("%s.%d", bf.path, i)
 }
 
-func (bf BatteryFile) Open() BatteryStream {
-	fn := string(bf)
-	if _, err := os.Stat(fn); !os.IsNotExist(err) {
-		ramFile, err := os.OpenFile(fn, os.O_RDWR, os.ModeExclusive)
-		if err != nil {
-			log.Fatal(err)
+func (bf *BatteryFile) Load() ([]byte, error) {
+	data, err := ioutil.ReadFile(bf.path)
+	if os.IsNotExist(err) && bf.legacy != "" {
+		data, err = ioutil.ReadFile(bf.legacy)
+	}
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (bf *BatteryFile) Save(data []byte) error {
+	dir, name := filepath.Split(bf.path)
+	tmp, err := ioutil.TempFile(dir, name+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cErr := tmp.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if !bf.rotated {
+		if err := bf.rotateBackups(); err != nil {
+			return err
 		}
-		return ramFile
-	} else {
-		ramFile, err := os.Create(fn)
+		bf.rotated = true
+	}
+	return os.Rename(tmp.Name(), bf.path)
+}
+
+// rotateBackups copies the current file to the first backup and shifts the older backups.
+func (bf *BatteryFile) rotateBackups() error {
+	if bf.backups <= 0 {
+		return nil
+	}
+	data, err := ioutil.ReadFile(bf.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for i := bf.backups - 1; i > 0; i-- {
+		if err := os.Rename(bf.backupName(i), bf.backupName(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return ioutil.WriteFile(bf.backupName(1), data, 0666)
+}
+
+// MemoryBattery keeps the data in memory.
+type MemoryBattery struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (mb *MemoryBattery) Load() ([]byte, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if mb.data == nil {
+		return nil, nil
+	}
+	return append([]byte(nil), mb.data...), nil
+}
+
+func (mb *MemoryBattery) Save(data []byte) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.data = append(mb.data[:0], data...)
+	return nil
+}
+
+// BackgroundBattery saves the data to another battery on a separate goroutine,
+// so a slow battery like a synced file does not stall the emulation. If the
+// data changes while it is saved, only the newest data is saved afterwards.
+type BackgroundBattery struct {
+	battery Battery
+
+	mu      sync.Mutex
+	idle    sync.Cond
+	busy    bool
+	pending []byte
+	err     error
+}
+
+// NewBackgroundBattery creates a battery which saves to the given battery in the background.
+func NewBackgroundBattery(b Battery) *BackgroundBattery {
+	bb := &BackgroundBattery{battery: b}
+	bb.idle.L = &bb.mu
+	return bb
+}
+
+// Load waits for the running saves and loads the data of the battery.
+func (bb *BackgroundBattery) Load() ([]byte, error) {
+	bb.wait()
+	return bb.battery.Load()
+}
+
+// Save starts saving a copy of the data. It returns the error of an earlier
+// save which failed.
+func (bb *BackgroundBattery) Save(data []byte) error {
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	bb.pending = append(bb.pending[:0], data...)
+	if !bb.busy {
+		bb.busy = true
+		go bb.write()
+	}
+	err := bb.err
+	bb.err = nil
+	return err
+}
+
+// Close waits until all data is saved and returns the error of a failed save.
+func (bb *BackgroundBattery) Close() error {
+	bb.wait()
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	err := bb.err
+	bb.err = nil
+	return err
+}
+
+func (bb *BackgroundBattery) wait() {
+	bb.mu.Lock()
+	for bb.busy {
+		bb.idle.Wait()
+	}
+	bb.mu.Unlock()
+}
+
+func (bb *BackgroundBattery) write() {
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	for len(bb.pending) > 0 {
+		data := bb.pending
+		bb.pending = nil
+		bb.mu.Unlock()
+		err := bb.battery.Save(data)
+		bb.mu.Lock()
 		if err != nil {
-			log.Fatal(err)
+			bb.err = err
+		}
+	}
+	bb.busy = false
+	bb.idle.Broadcast()
+}
+
+// batteryRAM saves the ram of a MBC to the battery whenever it was changed.
+type batteryRAM struct {
+	battery Battery
+	dirty   bool
+	dump    func() []byte
+}
+
+// load passes the stored data to restore, if there is any.
+func (br *batteryRAM) load(restore func(data []byte)) error {
+	if br.battery == nil {
+		return nil
+	}
+	data, err := br.battery.Load()
+	if err != nil {
+		return fmt.Errorf("could not load battery: %v", err)
+	}
+	if data != nil {
+		restore(data)
+	}
+	return nil
+}
+
+// Flush writes the ram to the battery if it was changed since the last flush.
+func (br *batteryRAM) Flush() error {
+	if br.battery == nil || !br.dirty {
+		return nil
+	}
+	if err := br.battery.Save(br.dump()); err != nil {
+		return err
+	}
+	br.dirty = false
+	return nil
+}
+
+// Shutdown flushes the ram and closes the battery, if it has to be closed.
+func (br *batteryRAM) Shutdown() error {
+	err := br.Flush()
+	if c, ok := br.battery.(io.Closer); ok {
+		if cErr := c.Close(); err == nil {
+			err = cErr
 		}
-		return ramFile
 	}
+	return err
 }
@@ -0,0 +1,132 @@
+package cartridge
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBatteryFlush(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	c := &Cartridge{ROMSize: 0x8000, RAMSize: 0x2000}
+	bat := new(MemoryBattery)
+	m, err := createMBC5(c, rom, false, bat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := bat.Load(); data != nil {
+		t.Errorf("Expected unchanged ram not to be saved")
+	}
+
+	m.Write(0x0000, 0x0A)
+	m.Write(0xA010, 0x42)
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := bat.Load()
+	if len(data) != 0x2000 || data[0x10] != 0x42 {
+		t.Errorf("Expected the changed ram to be saved")
+	}
+
+	m2, err := createMBC5(c, rom, false, bat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2.Write(0x0000, 0x0A)
+	if v := m2.Read(0xA010); v != 0x42 {
+		t.Errorf("Expected 42 to be loaded from the battery but got %02X", v)
+	}
+}
+
+func TestBatteryFileBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goboy2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "game.sav")
+
+	bf := NewBatteryFile(path, 2)
+	if data, err := bf.Load(); data != nil || err != nil {
+		t.Fatalf("Expected no data for a missing file but got %v, %v", data, err)
+	}
+	for i := byte(1); i <= 3; i++ {
+		if err := NewBatteryFile(path, 2).Save([]byte{i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// saving multiple times within one session does not create new backups
+	bf = NewBatteryFile(path, 2)
+	bf.Save([]byte{4})
+	bf.Save([]byte{5})
+
+	for name, exp := range map[string]byte{path: 5, path + ".1": 3, path + ".2": 2} {
+		data, err := ioutil.ReadFile(name)
+		if err != nil || !bytes.Equal(data, []byte{exp}) {
+			t.Errorf("Expected %s to contain %d but got %v, %v", name, exp, data, err)
+		}
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 3 {
+		t.Errorf("Expected 3 files but found %d", len(files))
+	}
+}
+
+// blockingBattery saves the data as soon as the test releases it.
+type blockingBattery struct {
+	MemoryBattery
+	saving  chan struct{}
+	release chan error
+}
+
+func (b *blockingBattery) Save(data []byte) error {
+	b.saving <- struct{}{}
+	if err := <-b.release; err != nil {
+		return err
+	}
+	return b.MemoryBattery.Save(data)
+}
+
+func TestBackgroundBattery(t *testing.T) {
+	inner := &blockingBattery{saving: make(chan struct{}), release: make(chan error)}
+	bb := NewBackgroundBattery(inner)
+
+	data := []byte{1}
+	if err := bb.Save(data); err != nil {
+		t.Fatal(err)
+	}
+	<-inner.saving
+	// the data is copied, so it can be changed while it is saved
+	data[0] = 2
+	if err := bb.Save([]byte{3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bb.Save([]byte{4}); err != nil {
+		t.Fatal(err)
+	}
+	inner.release <- nil
+	// only the newest of the waiting data is saved
+	<-inner.saving
+	if data, _ := inner.MemoryBattery.Load(); !bytes.Equal(data, []byte{1}) {
+		t.Errorf("Expected the first data to be saved but got %v", data)
+	}
+	inner.release <- nil
+	if err := bb.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := bb.Load(); !bytes.Equal(data, []byte{4}) {
+		t.Errorf("Expected the newest data to be saved but got %v", data)
+	}
+
+	bb.Save([]byte{5})
+	<-inner.saving
+	inner.release <- os.ErrPermission
+	if err := bb.Close(); err != os.ErrPermission {
+		t.Errorf("Expected the error of the save but got %v", err)
+	}
+}
@@ -0,0 +1,291 @@
+package cartridge
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/boombuler/goboy2/savestate"
+)
+
+const (
+	// CameraWidth and CameraHeight are the size of the image captured by the camera sensor.
+	CameraWidth  = 128
+	CameraHeight = 112
+
+	cameraRegCount   = 0x36
+	cameraRegControl = 0x00
+	cameraRegGain    = 0x01
+	cameraRegExpHigh = 0x02
+	cameraRegExpLow  = 0x03
+	cameraRegEdge    = 0x04
+	cameraRegMatrix  = 0x06
+
+	// cameraImageOffset is the offset of the captured image within the first ram bank.
+	cameraImageOffset = 0x0100
+)
+
+// edge enhancement ratios selected by bits 4-6 of register 4
+var cameraEdgeRatios = [8]float64{0.5, 0.75, 1, 1.25, 2, 3, 4, 5}
+
+// ImageSource provides the images for the sensor of the Game Boy Camera.
+type ImageSource interface {
+	// Capture returns the image the sensor sees when a capture starts.
+	// It is scaled to CameraWidth x CameraHeight.
+	Capture() image.Image
+}
+
+// CameraPort is implemented by MBCs with a camera sensor.
+type CameraPort interface {
+	SetImageSource(src ImageSource)
+}
+
+// SetImageSource connects the sensor of the cartridge with the given image source.
+// It returns false if the cartridge has no camera.
+func (c *Cartridge) SetImageSource(src ImageSource) bool {
+	if p, ok := c.Mapper().(CameraPort); ok {
+		p.SetImageSource(src)
+		return true
+	}
+	return false
+}
+
+type camera struct {
+	batteryRAM
+	rombanks   []rombank
+	rambanks   []rambank
+	activerom  int
+	activeram  int
+	ramEnabled bool
+	// regMode maps the camera registers instead of the ram.
+	regMode bool
+	regs    [cameraRegCount]byte
+	// busy is the number of cycles until the running capture is complete.
+	busy   int
+	source ImageSource
+}
+
+func createCamera(c *Cartridge, data []byte, bat Battery) (MBC, error) {
+	m := new(camera)
+	for rs := c.ROMSize; rs > 0; rs -= rombankSize {
+		m.rombanks = append(m.rombanks, rombank(data[c.ROMSize-rs:c.ROMSize-rs+rombankSize]))
+	}
+	m.rambanks = make([]rambank, c.RAMSize/rambankSize)
+	if len(m.rambanks) > 0 {
+		m.battery = bat
+	}
+	m.dump = func() []byte { return dumpRAMBanks(m.rambanks) }
+	if err := m.load(func(data []byte) { restoreRAMBanks(m.rambanks, data) }); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *camera) SetImageSource(src ImageSource) {
+	m.source = src
+}
+
+func (m *camera) Read(addr uint16) byte {
+	switch {
+	case addr < rombankSize:
+		return m.rombanks[0].Read(addr)
+	case addr < 2*rombankSize:
+		return m.rombanks[m.activerom%len(m.rombanks)].Read(addr)
+	case addr >= 0xA000 && addr < 0xC000:
+		if m.regMode {
+			// only the control register can be read.
+			if addr&0x7F == cameraRegControl {
+				return m.regs[cameraRegControl]
+			}
+			return 0x00
+		}
+		if len(m.rambanks) > 0 {
+			return m.rambanks[m.activeram%len(m.rambanks)][addr-0xA000]
+		}
+	}
+	return 0xFF
+}
+
+func (m *camera) ROMBank(addr uint16) int {
+	if addr < rombankSize {
+		return 0
+	}
+	return m.activerom % len(m.rombanks)
+}
+
+func (m *camera) Write(addr uint16, value byte) {
+	switch {
+	case addr < 0x2000:
+		m.ramEnabled = value&0x0F == 0x0A
+	case addr < 0x4000:
+		m.activerom = int(value & 0x3F)
+	case addr < 0x6000:
+		m.regMode = value&0x10 != 0
+		m.activeram = int(value & 0x0F)
+	case addr >= 0xA000 && addr < 0xC000:
+		if m.regMode {
+			m.writeRegister(int(addr&0x7F), value)
+		} else if m.ramEnabled && len(m.rambanks) > 0 {
+			m.rambanks[m.activeram%len(m.rambanks)][addr-0xA000] = value
+			m.dirty = true
+		}
+	}
+}
+
+func (m *camera) writeRegister(reg int, value byte) {
+	if reg >= cameraRegCount {
+		return
+	}
+	if reg != cameraRegControl {
+		m.regs[reg] = value
+		return
+	}
+	if m.busy > 0 {
+		// a running capture can't be changed.
+		return
+	}
+	m.regs[cameraRegControl] = value & 0x07
+	if value&0x01 != 0 {
+		m.busy = m.captureCycles()
+	}
+}
+
+// captureCycles returns the number of m-cycles a capture with the current settings takes.
+func (m *camera) captureCycles() int {
+	cycles := 32446 + 16*m.exposure()
+	if m.regs[cameraRegGain]&0x80 == 0 {
+		cycles += 512
+	}
+	return cycles
+}
+
+func (m *camera) exposure() int {
+	return int(m.regs[cameraRegExpHigh])<<8 | int(m.regs[cameraRegExpLow])
+}
+
+// Step advances the capture by one m-cycle at normal speed.
+func (m *camera) Step() {
+	if m.busy == 0 {
+		return
+	}
+	if m.busy--; m.busy == 0 {
+		m.capture()
+		m.regs[cameraRegControl] &^= 0x01
+	}
+}
+
+// sensor returns the brightness of the image source for each pixel of the sensor.
+func (m *camera) sensor() *[CameraHeight][CameraWidth]float64 {
+	res := new([CameraHeight][CameraWidth]float64)
+	if m.source == nil {
+		return res
+	}
+	img := m.source.Capture()
+	b := img.Bounds()
+	if b.Empty() {
+		return res
+	}
+	for y := 0; y < CameraHeight; y++ {
+		for x := 0; x < CameraWidth; x++ {
+			px := img.At(b.Min.X+x*b.Dx()/CameraWidth, b.Min.Y+y*b.Dy()/CameraHeight)
+			res[y][x] = float64(color.GrayModel.Convert(px).(color.Gray).Y)
+		}
+	}
+	return res
+}
+
+// capture processes the image of the sensor like the M64282FP chip and stores
+// it as tile data in the first ram bank. The analog part of the chip is
+// approximated: The brightness is scaled by the gain and the exposure time,
+// then the edges are enhanced and the result is dithered with the threshold
+// matrix of the registers.
+func (m *camera) capture() {
+	if len(m.rambanks) == 0 {
+		return
+	}
+	img := m.sensor()
+	// each gain step increases the gain by about 0.5dB
+	gain := math.Pow(10, float64(m.regs[cameraRegGain]&0x1F)/40)
+	scale := gain * float64(m.exposure()) / 0x0800
+
+	pixel := func(x, y int) float64 {
+		if x < 0 {
+			x = 0
+		} else if x >= CameraWidth {
+			x = CameraWidth - 1
+		}
+		if y < 0 {
+			y = 0
+		} else if y >= CameraHeight {
+			y = CameraHeight - 1
+		}
+		return img[y][x] * scale
+	}
+
+	edgeMode := (m.regs[cameraRegGain] >> 5) & 0x03
+	ratio := cameraEdgeRatios[(m.regs[cameraRegEdge]>>4)&0x07]
+	invert := m.regs[cameraRegEdge]&0x08 != 0
+	ram := &m.rambanks[0]
+
+	for y := 0; y < CameraHeight; y++ {
+		for x := 0; x < CameraWidth; x++ {
+			v := pixel(x, y)
+			if edgeMode&0x01 != 0 {
+				v += ratio * (2*pixel(x, y) - pixel(x-1, y) - pixel(x+1, y))
+			}
+			if edgeMode&0x02 != 0 {
+				v += ratio * (2*pixel(x, y) - pixel(x, y-1) - pixel(x, y+1))
+			}
+
+			matrix := m.regs[cameraRegMatrix+((y&3)*4+(x&3))*3:]
+			var col byte
+			switch {
+			case v < float64(matrix[0]):
+				col = 3
+			case v < float64(matrix[1]):
+				col = 2
+			case v < float64(matrix[2]):
+				col = 1
+			}
+			if invert {
+				col = 3 - col
+			}
+
+			offset := cameraImageOffset + ((y/8)*(CameraWidth/8)+x/8)*16 + (y%8)*2
+			bit := byte(0x80) >> uint(x%8)
+			ram[offset] &^= bit
+			ram[offset+1] &^= bit
+			if col&0x01 != 0 {
+				ram[offset] |= bit
+			}
+			if col&0x02 != 0 {
+				ram[offset+1] |= bit
+			}
+		}
+	}
+	m.dirty = true
+}
+
+func (m *camera) SaveState(w *savestate.Writer) {
+	w.Int(m.activerom)
+	w.Int(m.activeram)
+	w.Bool(m.ramEnabled)
+	w.Bool(m.regMode)
+	w.Bytes(m.regs[:])
+	w.Int(m.busy)
+	saveRAMBanks(w, m.rambanks)
+}
+
+func (m *camera) LoadState(r *savestate.Reader) {
+	m.activerom = r.Int() & 0x3F
+	m.activeram = r.Int() & 0x0F
+	m.ramEnabled = r.Bool()
+	m.regMode = r.Bool()
+	r.Bytes(m.regs[:])
+	if m.busy = r.Int(); m.busy < 0 {
+		r.Failf("savestate: invalid camera state")
+		m.busy = 0
+	}
+	loadRAMBanks(r, m.rambanks)
+	m.dirty = true
+}
@@ -0,0 +1,54 @@
+package cartridge
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+type uniformSource color.Gray
+
+func (u uniformSource) Capture() image.Image {
+	return image.NewUniform(color.Gray(u))
+}
+
+func TestCameraCapture(t *testing.T) {
+	c := &Cartridge{ROMSize: 0x8000, RAMSize: 0x20000}
+	m, err := createCamera(c, make([]byte, 0x8000), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.MBC = m
+	if !c.SetImageSource(uniformSource{0x60}) {
+		t.Fatal("Expected the camera to accept an image source")
+	}
+
+	m.Write(0x4000, 0x10)
+	m.Write(0xA002, 0x08) // exposure 0x0800
+	m.Write(0xA003, 0x00)
+	for i := 0; i < 16; i++ {
+		m.Write(0xA006+uint16(i*3), 0x40)
+		m.Write(0xA007+uint16(i*3), 0x80)
+		m.Write(0xA008+uint16(i*3), 0xC0)
+	}
+	m.Write(0xA000, 0x01)
+	if v := m.Read(0xA000); v&0x01 == 0 {
+		t.Errorf("Expected the camera to be busy")
+	}
+	for i := 0; i < 32446+16*0x0800+512; i++ {
+		m.(Clocked).Step()
+	}
+	if v := m.Read(0xA000); v&0x01 != 0 {
+		t.Errorf("Expected the capture to be finished")
+	}
+
+	m.Write(0x4000, 0x00)
+	for addr := uint16(0xA100); addr < 0xA100+0x0E00; addr += 2 {
+		if lo, hi := m.Read(addr), m.Read(addr+1); lo != 0x00 || hi != 0xFF {
+			t.Fatalf("Expected color 2 at %04X but got %02X %02X", addr, lo, hi)
+		}
+	}
+	if v := m.Read(0xA100 + 0x0E00); v != 0x00 {
+		t.Errorf("Expected the ram behind the image to be unchanged but got %02X", v)
+	}
+}
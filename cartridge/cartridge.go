@@ -1,16 +1,54 @@
 package cartridge
 
 import (
+	"bytes"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"io/ioutil"
-	"strings"
+
+	"github.com/boombuler/goboy2/savestate"
 )
 
 type MBC interface {
 	Read(addr uint16) byte
 	Write(addr uint16, value byte)
-	Shutdown()
+	// Flush writes the battery backed ram to the battery, if it was changed.
+	Flush() error
+	Shutdown() error
+	savestate.Stater
+}
+
+// Clocked is implemented by MBCs with hardware which runs with the clock of the gameboy.
+type Clocked interface {
+	// Step advances the hardware by one m-cycle at normal speed.
+	Step()
+}
+
+// BankedROM is implemented by MBCs which switch the banks of the rom.
+type BankedROM interface {
+	// ROMBank returns the index of the 16KB bank of the rom which is mapped at
+	// the given address in 0000-7FFF.
+	ROMBank(addr uint16) int
+}
+
+// BusListener is implemented by MBCs which react to the reads of the cpu. Read
+// itself must not have side effects, since it is also used to inspect the memory.
+type BusListener interface {
+	// CPURead is called after the cpu read from the cartridge rom.
+	CPURead(addr uint16)
+}
+
+// BootListener is implemented by MBCs which are unlocked by the boot rom.
+type BootListener interface {
+	// SkipBoot is called if the emulation starts without the boot rom.
+	SkipBoot()
+}
+
+// Wrapper is implemented by MBCs which wrap the MBC of the cartridge to change its
+// behaviour, like the cheat engine.
+type Wrapper interface {
+	Unwrap() MBC
 }
 
 type Cartridge struct {
@@ -22,9 +60,44 @@ type Cartridge struct {
 	RAMSize  uint
 	Japanese bool
 	Version  byte
+	// CRC32 is the checksum of the complete rom.
+	CRC32 uint32
+	// Header contains all fields of the header and the results of their validation.
+	Header *Header
+}
+
+// Mapper returns the MBC which emulates the hardware of the cartridge. Unlike the
+// embedded MBC it is never wrapped, so it can be checked for optional capabilities.
+func (c *Cartridge) Mapper() MBC {
+	m := c.MBC
+	for {
+		w, ok := m.(Wrapper)
+		if !ok {
+			return m
+		}
+		m = w.Unwrap()
+	}
+}
+
+// ROMBank returns the index of the 16KB bank of the rom which is mapped at the
+// given address in 0000-7FFF.
+func (c *Cartridge) ROMBank(addr uint16) int {
+	if b, ok := c.Mapper().(BankedROM); ok {
+		return b.ROMBank(addr)
+	}
+	return int(addr / rombankSize)
+}
+
+// SkipBoot brings the cartridge to the state after the boot rom finished.
+func (c *Cartridge) SkipBoot() {
+	if l, ok := c.Mapper().(BootListener); ok {
+		l.SkipBoot()
+	}
 }
 
-var mbcFactories = map[byte]func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error){
+type mapperFactory func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error)
+
+var mbcFactories = map[byte]mapperFactory{
 	0x00: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
 		// ROM Only
 		return createMBC0(c, data, false, nil)
@@ -39,7 +112,11 @@ var mbcFactories = map[byte]func(c *Cartridge, data []byte, bf BatteryFactory) (
 	},
 	0x03: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
 		// MBC1+RAM+BAT
-		return createMBC1(c, data, bf())
+		bat, err := bf.open()
+		if err != nil {
+			return nil, err
+		}
+		return createMBC1(c, data, bat)
 	},
 	0x05: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
 		// MBC2
@@ -47,7 +124,11 @@ var mbcFactories = map[byte]func(c *Cartridge, data []byte, bf BatteryFactory) (
 	},
 	0x06: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
 		// MBC2+BAT
-		return createMBC2(c, data, bf())
+		bat, err := bf.open()
+		if err != nil {
+			return nil, err
+		}
+		return createMBC2(c, data, bat)
 	},
 	0x08: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
 		// ROM+RAM
@@ -55,18 +136,43 @@ var mbcFactories = map[byte]func(c *Cartridge, data []byte, bf BatteryFactory) (
 	},
 	0x09: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
 		// ROM+RAM+BAT
-		return createMBC0(c, data, true, bf())
+		bat, err := bf.open()
+		if err != nil {
+			return nil, err
+		}
+		return createMBC0(c, data, true, bat)
+	},
+	0x0B: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
+		// MMM01
+		return createMMM01(c, data, nil)
+	},
+	0x0C: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
+		// MMM01+RAM
+		return createMMM01(c, data, nil)
+	},
+	0x0D: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
+		// MMM01+RAM+BAT
+		bat, err := bf.open()
+		if err != nil {
+			return nil, err
+		}
+		return createMMM01(c, data, bat)
 	},
-	// 0x0B MMM01
-	// 0x0C MMM01+RAM
-	// 0x0D MMM01+RAM+BAT
 	0x0F: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
 		// MBC3+Timer+BAT
-		return createMBC3(c, data, true, bf())
+		bat, err := bf.open()
+		if err != nil {
+			return nil, err
+		}
+		return createMBC3(c, data, true, bat)
 	},
 	0x10: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
 		// MBC3+Timer+RAM+BAT
-		return createMBC3(c, data, true, bf())
+		bat, err := bf.open()
+		if err != nil {
+			return nil, err
+		}
+		return createMBC3(c, data, true, bat)
 	},
 	0x11: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
 		// MBC3
@@ -78,79 +184,147 @@ var mbcFactories = map[byte]func(c *Cartridge, data []byte, bf BatteryFactory) (
 	},
 	0x13: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
 		// MBC3+RAM+BAT
-		return createMBC3(c, data, false, bf())
+		bat, err := bf.open()
+		if err != nil {
+			return nil, err
+		}
+		return createMBC3(c, data, false, bat)
 	},
 	// 0x15 MBC4
 	// 0x16 MBC4+RAM
 	// 0x17 MBC4+RAM+BAT
 	0x19: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
 		// 0x19 MBC5
-		return createMBC5(c, data, nil)
+		return createMBC5(c, data, false, nil)
 	},
 	0x1A: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
 		// 0x1A MBC5+RAM
-		return createMBC5(c, data, nil)
+		return createMBC5(c, data, false, nil)
 	},
 	0x1B: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
 		// 0x1B MBC5+RAM+BAT
-		return createMBC5(c, data, bf())
+		bat, err := bf.open()
+		if err != nil {
+			return nil, err
+		}
+		return createMBC5(c, data, false, bat)
 	},
 	0x1C: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
 		// 0x1C MBC5+RUMBLE
-		return createMBC5(c, data, nil)
+		return createMBC5(c, data, true, nil)
 	},
 	0x1D: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
 		// 0x1D MBC5+RUMBLE+RAM
-		return createMBC5(c, data, nil)
+		return createMBC5(c, data, true, nil)
 	},
 	0x1E: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
 		// 0x1E MBC5+RUMBLE+RAM+BAT
-		return createMBC5(c, data, bf())
+		bat, err := bf.open()
+		if err != nil {
+			return nil, err
+		}
+		return createMBC5(c, data, true, bat)
+	},
+	0x22: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
+		// MBC7+SENSOR+RUMBLE+RAM+BAT
+		bat, err := bf.open()
+		if err != nil {
+			return nil, err
+		}
+		return createMBC7(c, data, bat)
+	},
+	0xFC: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
+		// POCKET CAMERA
+		bat, err := bf.open()
+		if err != nil {
+			return nil, err
+		}
+		return createCamera(c, data, bat)
 	},
-	// 0xFC POCKET CAMERA
 	// 0xFD BANDAI TAMA5
-	// 0xFE HuC3
-	// 0xFF HuC1+RAM+BAT
+	0xFE: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
+		// HuC3
+		bat, err := bf.open()
+		if err != nil {
+			return nil, err
+		}
+		return createHuC3(c, data, bat)
+	},
+	0xFF: func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
+		// HuC1+RAM+BAT
+		bat, err := bf.open()
+		if err != nil {
+			return nil, err
+		}
+		return createHuC1(c, data, bat)
+	},
 }
 
+// Load reads the rom and creates the cartridge with the mapper of its header.
 func Load(reader io.Reader, bf BatteryFactory) (*Cartridge, error) {
+	return LoadMapper(reader, "", bf)
+}
+
+// LoadMapper is like Load, but uses the mapper with the given name (see MapperNames)
+// instead of the cartridge type of the header. If the name is empty, the mappers of
+// unlicensed cartridges are detected by the content of the rom.
+func LoadMapper(reader io.Reader, mapper string, bf BatteryFactory) (*Cartridge, error) {
 	rom, err := ioutil.ReadAll(reader)
 	if err != nil {
 		return nil, err
 	}
-	if len(rom) < 0x8000 {
-		return nil, fmt.Errorf("Invalid ROM")
+	h, err := ParseHeader(rom)
+	if err != nil {
+		return nil, err
 	}
 	c := new(Cartridge)
-	c.Title = strings.TrimRight(string(rom[0x0134:0x0142]), "\x00")
-	c.GBC = (rom[0x0143] == 0x80) || (rom[0x0143] == 0xC0)
-	c.SGB = rom[0x0146] == 0x03 && rom[0x014B] == 0x33
-	c.ROMSize = 0x8000 << rom[0x0148]
-
-	switch rom[0x0149] {
-	case 0x00:
-		c.RAMSize = 0x000000
-	case 0x01:
-		c.RAMSize = 0x000800
-	case 0x02:
-		c.RAMSize = 0x002000
-	case 0x03:
-		c.RAMSize = 0x008000
-	case 0x04:
-		c.RAMSize = 0x020000
-	default:
-		return nil, fmt.Errorf("Unsupported RAM size: %v", rom[0x0149])
+	c.Header = h
+	c.Title = h.Title
+	c.GBC = h.GBC
+	c.SGB = h.SGB
+	c.ROMSize = h.ROMSize
+	c.RAMSize = h.RAMSize
+	c.Japanese = h.Japanese
+	c.Version = h.Version
+	c.CRC32 = crc32.ChecksumIEEE(rom)
+
+	if mapper == "" {
+		mapper = detectMapper(rom, h)
 	}
-	c.Japanese = (rom[0x014A] == 0x00)
-	c.Version = rom[0x014C]
-	mbcFactory, ok := mbcFactories[rom[0x0147]]
-	if !ok {
-		return nil, fmt.Errorf("MBC type not supported: %02x", rom[0x0147])
+	var factory mapperFactory
+	if mapper != "" {
+		if factory = mappers[mapper]; factory == nil {
+			return nil, fmt.Errorf("Unknown mapper: %s", mapper)
+		}
+		// the header of unlicensed cartridges is not reliable.
+		c.ROMSize = uint(len(rom) / rombankSize * rombankSize)
 	} else {
-		c.MBC, err = mbcFactory(c, rom, bf)
-		if err != nil {
-			return nil, err
+		if _, ok := ramSizes[h.RAMSizeCode]; !ok {
+			return nil, fmt.Errorf("Unsupported RAM size: %v", h.RAMSizeCode)
+		}
+		if h.ROMSizeCode > 0x08 {
+			return nil, fmt.Errorf("Unsupported ROM size: %v", h.ROMSizeCode)
+		}
+		if !h.MMM01 && uint(len(rom)) < h.ROMSize {
+			// the missing banks of an underdumped rom read as open bus. The
+			// size mismatch is one of the problems of the header.
+			rom = append(rom, bytes.Repeat([]byte{0xFF}, int(h.ROMSize)-len(rom))...)
+		}
+		if factory = mbcFactories[h.CartridgeType]; factory == nil {
+			return nil, fmt.Errorf("MBC type not supported: %02x", h.CartridgeType)
 		}
 	}
+	if c.MBC, err = factory(c, rom, bf); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
+
+// headerChecksum computes the checksum of the cartridge header.
+func headerChecksum(header []byte) byte {
+	var x byte
+	for _, b := range header[0x0134:0x014D] {
+		x = x - b - 1
+	}
+	return x
+}
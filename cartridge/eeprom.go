@@ -0,0 +1,203 @@
+package cartridge
+
+import (
+	"encoding/binary"
+
+	"github.com/boombuler/goboy2/savestate"
+)
+
+const (
+	eepromWords = 128
+	// eepromCmdBits is the number of bits of a command: the start bit, two opcode
+	// bits and 8 address bits.
+	eepromCmdBits = 11
+	// eepromDataBits is the number of bits of a command with data.
+	eepromDataBits = eepromCmdBits + 16
+
+	eepromCS  = 0x80
+	eepromCLK = 0x40
+	eepromDI  = 0x02
+	eepromDO  = 0x01
+)
+
+// eeprom emulates the 93LC56 serial EEPROM of the MBC7 in 16bit mode. The bits
+// are shifted in on the rising edge of the clock while the chip is selected.
+type eeprom struct {
+	data     [eepromWords]uint16
+	writable bool
+	pins     byte
+
+	cmd  uint32
+	bits int
+
+	// reading is set while the data of a read command is shifted out.
+	reading bool
+	addr    byte
+	out     uint16
+	outBits int
+}
+
+// Read returns the state of the pins.
+func (e *eeprom) Read() byte {
+	return e.pins
+}
+
+// Write sets the input pins and returns true if the data was changed.
+func (e *eeprom) Write(value byte) bool {
+	prev := e.pins
+	e.pins = value&(eepromCS|eepromCLK|eepromDI) | prev&eepromDO
+	if value&eepromCS == 0 {
+		e.reset()
+		return false
+	}
+	if prev&eepromCLK != 0 || value&eepromCLK == 0 {
+		return false
+	}
+
+	if e.reading {
+		e.shiftOut()
+		return false
+	}
+	di := uint32(value&eepromDI) >> 1
+	if e.bits == 0 && di == 0 {
+		// wait for the start bit
+		return false
+	}
+	e.cmd = e.cmd<<1 | di
+	e.bits++
+	return e.execute()
+}
+
+func (e *eeprom) reset() {
+	e.cmd, e.bits = 0, 0
+	e.reading = false
+}
+
+func (e *eeprom) setDO(on bool) {
+	if on {
+		e.pins |= eepromDO
+	} else {
+		e.pins &^= eepromDO
+	}
+}
+
+func (e *eeprom) shiftOut() {
+	if e.outBits == 0 {
+		// sequential read of the next word
+		e.addr = (e.addr + 1) % eepromWords
+		e.out, e.outBits = e.data[e.addr], 16
+	}
+	e.setDO(e.out&0x8000 != 0)
+	e.out <<= 1
+	e.outBits--
+}
+
+// execute runs the received command as soon as it is complete.
+func (e *eeprom) execute() bool {
+	if e.bits < eepromCmdBits {
+		return false
+	}
+	// the command is followed by the data if there is any.
+	hdr := e.cmd >> uint(e.bits-eepromCmdBits)
+	op := (hdr >> 8) & 0x03
+	sub := (hdr >> 6) & 0x03
+	addr := byte(hdr) % eepromWords
+	switch {
+	case op == 0x02: // READ
+		e.reading = true
+		e.addr, e.out, e.outBits = addr, e.data[addr], 16
+		// a dummy zero bit precedes the data
+		e.setDO(false)
+		e.cmd, e.bits = 0, 0
+		return false
+	case (op == 0x01 || op == 0x00 && sub == 0x01) && e.bits < eepromDataBits:
+		// WRITE and WRAL need the data
+		return false
+	}
+
+	changed := false
+	switch op {
+	case 0x00:
+		switch sub {
+		case 0x00: // EWDS
+			e.writable = false
+		case 0x01: // WRAL
+			changed = e.fill(uint16(e.cmd))
+		case 0x02: // ERAL
+			changed = e.fill(0xFFFF)
+		case 0x03: // EWEN
+			e.writable = true
+		}
+	case 0x01: // WRITE
+		if e.writable {
+			e.data[addr] = uint16(e.cmd)
+			changed = true
+		}
+	case 0x03: // ERASE
+		if e.writable {
+			e.data[addr] = 0xFFFF
+			changed = true
+		}
+	}
+	// writes are finished instantly, so the chip is always ready.
+	e.setDO(true)
+	e.cmd, e.bits = 0, 0
+	return changed
+}
+
+func (e *eeprom) fill(v uint16) bool {
+	if !e.writable {
+		return false
+	}
+	for i := range e.data {
+		e.data[i] = v
+	}
+	return true
+}
+
+// dump returns the words of the eeprom in little endian byte order.
+func (e *eeprom) dump() []byte {
+	res := make([]byte, 2*eepromWords)
+	for i, w := range e.data {
+		binary.LittleEndian.PutUint16(res[2*i:], w)
+	}
+	return res
+}
+
+func (e *eeprom) restore(data []byte) {
+	for i := range e.data {
+		if len(data) >= 2*(i+1) {
+			e.data[i] = binary.LittleEndian.Uint16(data[2*i:])
+		}
+	}
+}
+
+func (e *eeprom) saveState(w *savestate.Writer) {
+	w.Bytes(e.dump())
+	w.Bool(e.writable)
+	w.U8(e.pins)
+	w.U32(e.cmd)
+	w.Int(e.bits)
+	w.Bool(e.reading)
+	w.U8(e.addr)
+	w.U16(e.out)
+	w.Int(e.outBits)
+}
+
+func (e *eeprom) loadState(r *savestate.Reader) {
+	data := make([]byte, 2*eepromWords)
+	r.Bytes(data)
+	e.restore(data)
+	e.writable = r.Bool()
+	e.pins = r.U8()
+	e.cmd = r.U32()
+	e.bits = r.Int()
+	e.reading = r.Bool()
+	e.addr = r.U8() % eepromWords
+	e.out = r.U16()
+	e.outBits = r.Int()
+	if e.bits < 0 || e.bits >= eepromDataBits || e.outBits < 0 || e.outBits > 16 {
+		r.Failf("savestate: invalid eeprom state")
+		e.reset()
+	}
+}
@@ -0,0 +1,222 @@
+package cartridge
+
+import (
+	"bytes"
+	"fmt"
+)
+
+var nintendoLogo = []byte{
+	0xCE, 0xED, 0x66, 0x66, 0xCC, 0x0D, 0x00, 0x0B, 0x03, 0x73, 0x00, 0x83, 0x00, 0x0C, 0x00, 0x0D,
+	0x00, 0x08, 0x11, 0x1F, 0x88, 0x89, 0x00, 0x0E, 0xDC, 0xCC, 0x6E, 0xE6, 0xDD, 0xDD, 0xD9, 0x99,
+	0xBB, 0xBB, 0x67, 0x63, 0x6E, 0x0E, 0xEC, 0xCC, 0xDD, 0xDC, 0x99, 0x9F, 0xBB, 0xB9, 0x33, 0x3E,
+}
+
+var ramSizes = map[byte]uint{
+	0x00: 0x000000,
+	0x01: 0x000800,
+	0x02: 0x002000,
+	0x03: 0x008000,
+	0x04: 0x020000,
+	0x05: 0x010000,
+}
+
+// CartridgeTypes contains the names of the cartridge types.
+var CartridgeTypes = map[byte]string{
+	0x00: "ROM ONLY",
+	0x01: "MBC1",
+	0x02: "MBC1+RAM",
+	0x03: "MBC1+RAM+BATTERY",
+	0x05: "MBC2",
+	0x06: "MBC2+BATTERY",
+	0x08: "ROM+RAM",
+	0x09: "ROM+RAM+BATTERY",
+	0x0B: "MMM01",
+	0x0C: "MMM01+RAM",
+	0x0D: "MMM01+RAM+BATTERY",
+	0x0F: "MBC3+TIMER+BATTERY",
+	0x10: "MBC3+TIMER+RAM+BATTERY",
+	0x11: "MBC3",
+	0x12: "MBC3+RAM",
+	0x13: "MBC3+RAM+BATTERY",
+	0x19: "MBC5",
+	0x1A: "MBC5+RAM",
+	0x1B: "MBC5+RAM+BATTERY",
+	0x1C: "MBC5+RUMBLE",
+	0x1D: "MBC5+RUMBLE+RAM",
+	0x1E: "MBC5+RUMBLE+RAM+BATTERY",
+	0x20: "MBC6",
+	0x22: "MBC7+SENSOR+RUMBLE+RAM+BATTERY",
+	0xFC: "POCKET CAMERA",
+	0xFD: "BANDAI TAMA5",
+	0xFE: "HuC3",
+	0xFF: "HuC1+RAM+BATTERY",
+}
+
+// Licensees contains the names of the most common licensees. The old one byte codes
+// are formatted as two hex digits, the new codes are the two ascii characters.
+var Licensees = map[string]string{
+	"00": "None",
+	"01": "Nintendo",
+	"08": "Capcom",
+	"09": "Hot-B",
+	"0A": "Jaleco",
+	"13": "Electronic Arts",
+	"18": "Hudson Soft",
+	"20": "KSS",
+	"28": "Kemco",
+	"30": "Infogrames",
+	"31": "Nintendo",
+	"34": "Konami",
+	"41": "Ubisoft",
+	"51": "Acclaim",
+	"52": "Activision",
+	"56": "LJN",
+	"5D": "Midway",
+	"69": "Electronic Arts",
+	"70": "Infogrames",
+	"78": "THQ",
+	"79": "Accolade",
+	"8B": "Bullet-Proof Software",
+	"A4": "Konami",
+	"AF": "Namco",
+	"B0": "Acclaim",
+	"B4": "Enix",
+	"C0": "Taito",
+	"C3": "Squaresoft",
+	"D9": "Banpresto",
+	"DA": "Tomy",
+	"E9": "Natsume",
+	"EB": "Atlus",
+}
+
+// Header contains the fields of the cartridge header and the results of their validation.
+type Header struct {
+	Title          string `json:"title"`
+	Manufacturer   string `json:"manufacturer,omitempty"`
+	CGBFlag        byte   `json:"cgbFlag"`
+	GBC            bool   `json:"gbc"`
+	Licensee       string `json:"licensee"`
+	LicenseeName   string `json:"licenseeName,omitempty"`
+	SGB            bool   `json:"sgb"`
+	CartridgeType  byte   `json:"cartridgeType"`
+	CartridgeName  string `json:"cartridgeName,omitempty"`
+	ROMSizeCode    byte   `json:"romSizeCode"`
+	ROMSize        uint   `json:"romSize"`
+	RAMSizeCode    byte   `json:"ramSizeCode"`
+	RAMSize        uint   `json:"ramSize"`
+	Japanese       bool   `json:"japanese"`
+	Version        byte   `json:"version"`
+	HeaderChecksum byte   `json:"headerChecksum"`
+	GlobalChecksum uint16 `json:"globalChecksum"`
+	// MMM01 is set if the header was found at the end of the rom.
+	MMM01 bool `json:"mmm01,omitempty"`
+
+	LogoValid           bool `json:"logoValid"`
+	HeaderChecksumValid bool `json:"headerChecksumValid"`
+	GlobalChecksumValid bool `json:"globalChecksumValid"`
+	// Problems lists everything which is wrong with the header.
+	Problems []string `json:"problems,omitempty"`
+}
+
+// Valid returns true if no problems were found.
+func (h *Header) Valid() bool {
+	return len(h.Problems) == 0
+}
+
+func (h *Header) problem(format string, args ...interface{}) {
+	h.Problems = append(h.Problems, fmt.Sprintf(format, args...))
+}
+
+// ParseHeader reads and validates the header of the given rom.
+func ParseHeader(rom []byte) (*Header, error) {
+	if len(rom) < 0x8000 {
+		return nil, fmt.Errorf("Invalid ROM")
+	}
+	h := new(Header)
+	data := rom
+	if isMMM01(rom) {
+		// the start of a MMM01 rom contains the header of the first game. The
+		// header of the cartridge is part of the menu at the end of the rom.
+		data = rom[len(rom)-mmm01HeaderOffset:]
+		h.MMM01 = true
+	}
+
+	h.CGBFlag = data[0x0143]
+	h.GBC = h.CGBFlag == 0x80 || h.CGBFlag == 0xC0
+	title := data[0x0134:0x0144]
+	if h.CGBFlag&0x80 != 0 {
+		// newer cartridges use the end of the title for the manufacturer code and the cgb flag.
+		title = data[0x0134:0x013F]
+		if m := data[0x013F:0x0143]; isPrintable(m) {
+			h.Manufacturer = string(m)
+		}
+	}
+	if i := bytes.IndexByte(title, 0); i >= 0 {
+		title = title[:i]
+	}
+	h.Title = string(title)
+
+	if old := data[0x014B]; old == 0x33 {
+		h.Licensee = string(data[0x0144:0x0146])
+	} else {
+		h.Licensee = fmt.Sprintf("%02X", old)
+	}
+	h.LicenseeName = Licensees[h.Licensee]
+	h.SGB = data[0x0146] == 0x03 && data[0x014B] == 0x33
+	h.CartridgeType = data[0x0147]
+	h.CartridgeName = CartridgeTypes[h.CartridgeType]
+	h.ROMSizeCode = data[0x0148]
+	h.ROMSize = 0x8000 << h.ROMSizeCode
+	h.RAMSizeCode = data[0x0149]
+	h.Japanese = data[0x014A] == 0x00
+	h.Version = data[0x014C]
+	h.HeaderChecksum = data[0x014D]
+	h.GlobalChecksum = uint16(data[0x014E])<<8 | uint16(data[0x014F])
+
+	h.LogoValid = bytes.Equal(data[logoStart:logoEnd], nintendoLogo)
+	if !h.LogoValid {
+		h.problem("the nintendo logo is invalid")
+	}
+	if h.ROMSizeCode > 0x08 {
+		h.problem("unknown rom size %02X", h.ROMSizeCode)
+	} else if !h.MMM01 && uint(len(rom)) != h.ROMSize {
+		h.problem("the rom has %d bytes but the header specifies %d bytes", len(rom), h.ROMSize)
+	}
+	if size, ok := ramSizes[h.RAMSizeCode]; ok {
+		h.RAMSize = size
+	} else {
+		h.problem("unknown ram size %02X", h.RAMSizeCode)
+	}
+	if data[0x014A] > 0x01 {
+		h.problem("unknown destination code %02X", data[0x014A])
+	}
+	if h.CartridgeName == "" {
+		h.problem("unknown cartridge type %02X", h.CartridgeType)
+	}
+
+	h.HeaderChecksumValid = headerChecksum(data) == h.HeaderChecksum
+	if !h.HeaderChecksumValid {
+		h.problem("the header checksum %02X does not match %02X", h.HeaderChecksum, headerChecksum(data))
+	}
+	var sum uint16
+	base := len(rom) - len(data)
+	for i, b := range rom {
+		if i != base+0x014E && i != base+0x014F {
+			sum += uint16(b)
+		}
+	}
+	h.GlobalChecksumValid = sum == h.GlobalChecksum
+	if !h.GlobalChecksumValid {
+		h.problem("the global checksum %04X does not match %04X", h.GlobalChecksum, sum)
+	}
+	return h, nil
+}
+
+func isPrintable(data []byte) bool {
+	for _, b := range data {
+		if b < 0x20 || b > 0x7E {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package cartridge
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func validROM() []byte {
+	rom := make([]byte, 0x8000)
+	copy(rom[logoStart:], nintendoLogo)
+	copy(rom[0x0134:], "TESTGAME")
+	rom[0x014A] = 0x01
+	rom[0x014B] = 0x01
+	rom[0x014D] = headerChecksum(rom)
+	var sum uint16
+	for _, b := range rom {
+		sum += uint16(b)
+	}
+	rom[0x014E], rom[0x014F] = byte(sum>>8), byte(sum)
+	return rom
+}
+
+func TestParseHeader(t *testing.T) {
+	h, err := ParseHeader(validROM())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.Valid() || h.Title != "TESTGAME" || h.LicenseeName != "Nintendo" || h.CartridgeName != "ROM ONLY" {
+		t.Errorf("Unexpected header %+v", h)
+	}
+
+	testCases := []struct {
+		Name    string
+		Change  func(rom []byte)
+		Problem string
+	}{
+		{"logo", func(rom []byte) { rom[logoStart] = 0 }, "logo"},
+		{"header checksum", func(rom []byte) { rom[0x014D]++ }, "header checksum"},
+		{"global checksum", func(rom []byte) { rom[0x0200] = 0xFF }, "global checksum"},
+		{"rom size", func(rom []byte) { rom[0x0148] = 0x01 }, "bytes"},
+		{"destination", func(rom []byte) { rom[0x014A] = 0x05 }, "destination"},
+	}
+	for _, tc := range testCases {
+		rom := validROM()
+		tc.Change(rom)
+		h, err := ParseHeader(rom)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, p := range h.Problems {
+			found = found || strings.Contains(p, tc.Problem)
+		}
+		if !found {
+			t.Errorf("%s: Expected a problem containing %q but got %v", tc.Name, tc.Problem, h.Problems)
+		}
+	}
+}
+
+func TestLoadUnderdumpedROM(t *testing.T) {
+	rom := validROM()
+	rom[0x0147] = 0x01 // MBC1
+	rom[0x0148] = 0x01 // 64KB
+	rom[0x014D] = headerChecksum(rom)
+
+	c, err := Load(bytes.NewReader(rom), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Header.Valid() {
+		t.Error("Expected the size of the rom to be reported")
+	}
+	c.Write(0x2000, 0x03)
+	if v := c.Read(0x4000); v != 0xFF {
+		t.Errorf("Expected the missing bank to read as open bus but got %02X", v)
+	}
+}
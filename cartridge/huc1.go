@@ -0,0 +1,95 @@
+package cartridge
+
+import (
+	"github.com/boombuler/goboy2/savestate"
+)
+
+type huc1 struct {
+	batteryRAM
+	infrared
+	rombanks  []rombank
+	rambanks  []rambank
+	activerom int
+	activeram int
+	irMode    bool
+}
+
+func createHuC1(c *Cartridge, data []byte, bat Battery) (MBC, error) {
+	m := new(huc1)
+	for rs := c.ROMSize; rs > 0; rs -= rombankSize {
+		m.rombanks = append(m.rombanks, rombank(data[c.ROMSize-rs:c.ROMSize-rs+rombankSize]))
+	}
+	m.rambanks = make([]rambank, c.RAMSize/rambankSize)
+	if len(m.rambanks) > 0 {
+		m.battery = bat
+	}
+	m.activerom = 1
+	m.transfer = nullInfrared{}
+	m.dump = func() []byte { return dumpRAMBanks(m.rambanks) }
+	if err := m.load(func(data []byte) { restoreRAMBanks(m.rambanks, data) }); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *huc1) Read(addr uint16) byte {
+	switch {
+	case addr < rombankSize:
+		return m.rombanks[0].Read(addr)
+	case addr < 2*rombankSize:
+		return m.rombanks[m.activerom%len(m.rombanks)].Read(addr)
+	case addr >= 0xA000 && addr < 0xC000:
+		if m.irMode {
+			return m.readIR()
+		} else if len(m.rambanks) > 0 {
+			return m.rambanks[m.activeram%len(m.rambanks)][addr-0xA000]
+		}
+	}
+	return 0xFF
+}
+
+func (m *huc1) ROMBank(addr uint16) int {
+	if addr < rombankSize {
+		return 0
+	}
+	return m.activerom % len(m.rombanks)
+}
+
+func (m *huc1) Write(addr uint16, value byte) {
+	switch {
+	case addr < 0x2000:
+		// the ram can't be disabled. The register only switches between ram and infrared.
+		m.irMode = value&0x0F == 0x0E
+	case addr < 0x4000:
+		m.activerom = int(value & 0x3F)
+		if m.activerom == 0 {
+			m.activerom = 1
+		}
+	case addr < 0x6000:
+		m.activeram = int(value & 0x03)
+	case addr >= 0xA000 && addr < 0xC000:
+		if m.irMode {
+			m.writeIR(value)
+		} else if len(m.rambanks) > 0 {
+			m.rambanks[m.activeram%len(m.rambanks)][addr-0xA000] = value
+			m.dirty = true
+		}
+	}
+}
+
+func (m *huc1) SaveState(w *savestate.Writer) {
+	w.Int(m.activerom)
+	w.Int(m.activeram)
+	w.Bool(m.irMode)
+	m.infrared.saveState(w)
+	saveRAMBanks(w, m.rambanks)
+}
+
+func (m *huc1) LoadState(r *savestate.Reader) {
+	m.activerom = r.Int() & 0x3F
+	m.activeram = r.Int() & 0x03
+	m.irMode = r.Bool()
+	m.infrared.loadState(r)
+	loadRAMBanks(r, m.rambanks)
+	m.dirty = true
+}
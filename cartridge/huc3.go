@@ -0,0 +1,142 @@
+package cartridge
+
+import (
+	"bytes"
+
+	"github.com/boombuler/goboy2/savestate"
+)
+
+// modes of the HuC3 which are selected by writing to 0x0000-0x1FFF
+const (
+	huc3ModeRAMRead   = 0x00
+	huc3ModeRAM       = 0x0A
+	huc3ModeCommand   = 0x0B
+	huc3ModeResponse  = 0x0C
+	huc3ModeSemaphore = 0x0D
+	huc3ModeIR        = 0x0E
+)
+
+type huc3 struct {
+	batteryRAM
+	infrared
+	rombanks  []rombank
+	rambanks  []rambank
+	activerom int
+	activeram int
+	mode      byte
+	clock     *huc3Clock
+}
+
+func createHuC3(c *Cartridge, data []byte, bat Battery) (MBC, error) {
+	m := new(huc3)
+	for rs := c.ROMSize; rs > 0; rs -= rombankSize {
+		m.rombanks = append(m.rombanks, rombank(data[c.ROMSize-rs:c.ROMSize-rs+rombankSize]))
+	}
+	m.rambanks = make([]rambank, c.RAMSize/rambankSize)
+	m.activerom = 1
+	m.clock = newHuC3Clock()
+	m.transfer = nullInfrared{}
+	m.battery = bat
+	m.dump = m.dumpRAM
+	if err := m.load(m.restoreRAM); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *huc3) Read(addr uint16) byte {
+	switch {
+	case addr < rombankSize:
+		return m.rombanks[0].Read(addr)
+	case addr < 2*rombankSize:
+		return m.rombanks[m.activerom%len(m.rombanks)].Read(addr)
+	case addr >= 0xA000 && addr < 0xC000:
+		switch m.mode {
+		case huc3ModeRAMRead, huc3ModeRAM:
+			if len(m.rambanks) > 0 {
+				return m.rambanks[m.activeram%len(m.rambanks)][addr-0xA000]
+			}
+		case huc3ModeResponse:
+			return m.clock.ReadResponse()
+		case huc3ModeSemaphore:
+			// commands are executed immediately, so the clock is always ready.
+			return 0x01
+		case huc3ModeIR:
+			return m.readIR()
+		}
+	}
+	return 0xFF
+}
+
+func (m *huc3) ROMBank(addr uint16) int {
+	if addr < rombankSize {
+		return 0
+	}
+	return m.activerom % len(m.rombanks)
+}
+
+func (m *huc3) Write(addr uint16, value byte) {
+	switch {
+	case addr < 0x2000:
+		m.mode = value & 0x0F
+	case addr < 0x4000:
+		m.activerom = int(value & 0x7F)
+		if m.activerom == 0 {
+			m.activerom = 1
+		}
+	case addr < 0x6000:
+		m.activeram = int(value & 0x03)
+	case addr >= 0xA000 && addr < 0xC000:
+		switch m.mode {
+		case huc3ModeRAM:
+			if len(m.rambanks) > 0 {
+				m.rambanks[m.activeram%len(m.rambanks)][addr-0xA000] = value
+				m.dirty = true
+			}
+		case huc3ModeCommand:
+			m.clock.WriteCommand(value)
+		case huc3ModeSemaphore:
+			// clearing the lowest bit executes the command.
+			if value&0x01 == 0 {
+				m.clock.Execute()
+				m.dirty = true
+			}
+		case huc3ModeIR:
+			m.writeIR(value)
+		}
+	}
+}
+
+func (m *huc3) Step() {
+	m.clock.Step()
+}
+
+// dumpRAM returns the ram followed by the clock data.
+func (m *huc3) dumpRAM() []byte {
+	buf := bytes.NewBuffer(dumpRAMBanks(m.rambanks))
+	m.clock.Dump(buf)
+	return buf.Bytes()
+}
+
+func (m *huc3) restoreRAM(data []byte) {
+	m.clock.Load(restoreRAMBanks(m.rambanks, data))
+}
+
+func (m *huc3) SaveState(w *savestate.Writer) {
+	w.Int(m.activerom)
+	w.Int(m.activeram)
+	w.U8(m.mode)
+	m.infrared.saveState(w)
+	saveRAMBanks(w, m.rambanks)
+	m.clock.SaveState(w)
+}
+
+func (m *huc3) LoadState(r *savestate.Reader) {
+	m.activerom = r.Int() & 0x7F
+	m.activeram = r.Int() & 0x03
+	m.mode = r.U8() & 0x0F
+	m.infrared.loadState(r)
+	loadRAMBanks(r, m.rambanks)
+	m.clock.LoadState(r)
+	m.dirty = true
+}
@@ -0,0 +1,99 @@
+package cartridge
+
+import (
+	"testing"
+	"time"
+)
+
+type testInfrared struct {
+	led   bool
+	light bool
+}
+
+func (ir *testInfrared) SetLED(on bool) { ir.led = on }
+func (ir *testInfrared) Light() bool    { return ir.light }
+
+func huc3Command(m MBC, cmd byte) byte {
+	m.Write(0x0000, huc3ModeCommand)
+	m.Write(0xA000, cmd)
+	m.Write(0x0000, huc3ModeSemaphore)
+	m.Write(0xA000, 0xFE)
+	m.Write(0x0000, huc3ModeResponse)
+	return m.Read(0xA000)
+}
+
+func TestHuC3Clock(t *testing.T) {
+	c := &Cartridge{ROMSize: 0x8000, RAMSize: 0x2000}
+	bat := new(MemoryBattery)
+	m, err := createHuC3(c, make([]byte, 0x8000), bat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc := m.(*huc3)
+	now := time.Unix(1587211200, 0)
+	hc.clock.now = func() time.Time { return now }
+
+	// set the clock to day 2 at 23:59
+	huc3Command(m, 0x40)
+	huc3Command(m, 0x50)
+	for _, n := range []byte{0xF, 0x9, 0x5, 0x2, 0x0, 0x0, 0x0} {
+		huc3Command(m, 0x30|n)
+	}
+	huc3Command(m, 0x61)
+
+	for i := 0; i < huc3CyclesPerMinute; i++ {
+		hc.Step()
+	}
+	huc3Command(m, 0x60)
+	huc3Command(m, 0x40)
+	var got []byte
+	for i := 0; i < 7; i++ {
+		got = append(got, huc3Command(m, 0x10)&0x0F)
+	}
+	if exp := []byte{0, 0, 0, 3, 0, 0, 0}; string(got) != string(exp) {
+		t.Errorf("Expected %v but got %v", exp, got)
+	}
+
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(25 * time.Hour)
+	m2, err := createHuC3(c, make([]byte, 0x8000), bat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc2 := m2.(*huc3)
+	hc2.clock.now = func() time.Time { return now }
+	data, _ := bat.Load()
+	hc2.restoreRAM(data)
+	if hc2.clock.days != 4 || hc2.clock.minutes != 60 {
+		t.Errorf("Expected day 4 at 01:00 but got day %d minute %d", hc2.clock.days, hc2.clock.minutes)
+	}
+}
+
+func TestHuC1Infrared(t *testing.T) {
+	c := &Cartridge{ROMSize: 0x8000, RAMSize: 0x2000}
+	m, err := createHuC1(c, make([]byte, 0x8000), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.MBC = m
+	ir := new(testInfrared)
+	if !c.SetInfrared(ir) {
+		t.Fatal("Expected HuC1 to have an infrared port")
+	}
+	m.Write(0x0000, 0x0E)
+	m.Write(0xA000, 0x01)
+	if !ir.led {
+		t.Errorf("Expected the LED to be switched on")
+	}
+	ir.light = true
+	if v := m.Read(0xA000); v != 0xC1 {
+		t.Errorf("Expected C1 but got %02X", v)
+	}
+	m.Write(0x0000, 0x0A)
+	m.Write(0xA000, 0x42)
+	if v := m.Read(0xA000); v != 0x42 {
+		t.Errorf("Expected 42 from the ram but got %02X", v)
+	}
+}
@@ -0,0 +1,184 @@
+package cartridge
+
+import (
+	"encoding/binary"
+	"io"
+	"log"
+	"time"
+
+	"github.com/boombuler/goboy2/savestate"
+)
+
+const (
+	// huc3FooterSize is the size of the clock data stored behind the cartridge ram.
+	huc3FooterSize = 17
+
+	minutesPerDay       = 24 * 60
+	huc3CyclesPerMinute = 60 * rtcCyclesPerSecond
+
+	huc3CmdRead      = 0x1
+	huc3CmdWrite     = 0x3
+	huc3CmdAddrLow   = 0x4
+	huc3CmdAddrHigh  = 0x5
+	huc3CmdExtended  = 0x6
+	huc3ExtLatch     = 0x0
+	huc3ExtSet       = 0x1
+	huc3ExtStatus    = 0x2
+	huc3AlarmMinutes = 0x58
+	huc3AlarmDays    = 0x5B
+	huc3AlarmEnabled = 0x5F
+)
+
+// huc3Clock is the real time clock of the HuC3. It is controlled by commands
+// which access a memory of 256 nibbles. The clock itself only counts the minutes
+// of the day and the days. Extended commands copy it from and to the memory.
+type huc3Clock struct {
+	minutes uint16
+	days    uint16
+	cycles  uint32
+
+	mem      [0x100]byte
+	addr     byte
+	cmd      byte
+	response byte
+
+	// now returns the current wall clock time. It is only used to advance
+	// the clock by the time the emulator was not running.
+	now func() time.Time
+}
+
+func newHuC3Clock() *huc3Clock {
+	return &huc3Clock{now: time.Now}
+}
+
+// Step advances the clock by one m-cycle at normal speed.
+func (c *huc3Clock) Step() {
+	if c.cycles++; c.cycles >= huc3CyclesPerMinute {
+		c.cycles = 0
+		c.addMinutes(1)
+	}
+}
+
+func (c *huc3Clock) addMinutes(min int64) {
+	total := int64(c.minutes) + min
+	c.minutes = uint16(total % minutesPerDay)
+	c.days += uint16(total / minutesPerDay)
+}
+
+// WriteCommand sets the command which is executed by the next Execute.
+func (c *huc3Clock) WriteCommand(value byte) {
+	c.cmd = value & 0x7F
+}
+
+// ReadResponse returns the executed command with the result in the lower nibble.
+func (c *huc3Clock) ReadResponse() byte {
+	return 0x80 | c.cmd&0x70 | c.response&0x0F
+}
+
+// getNibbles reads a little endian value with the given number of nibbles from the memory.
+func (c *huc3Clock) getNibbles(addr, cnt int) uint16 {
+	var v uint16
+	for i := cnt - 1; i >= 0; i-- {
+		v = v<<4 | uint16(c.mem[addr+i]&0x0F)
+	}
+	return v
+}
+
+// setNibbles writes a little endian value with the given number of nibbles to the memory.
+func (c *huc3Clock) setNibbles(addr, cnt int, v uint16) {
+	for i := 0; i < cnt; i++ {
+		c.mem[addr+i] = byte(v & 0x0F)
+		v >>= 4
+	}
+}
+
+func (c *huc3Clock) Execute() {
+	arg := c.cmd & 0x0F
+	switch c.cmd >> 4 {
+	case huc3CmdRead:
+		c.response = c.mem[c.addr]
+		c.addr++
+	case huc3CmdWrite:
+		c.mem[c.addr] = arg
+		c.addr++
+	case huc3CmdAddrLow:
+		c.addr = c.addr&0xF0 | arg
+	case huc3CmdAddrHigh:
+		c.addr = c.addr&0x0F | arg<<4
+	case huc3CmdExtended:
+		switch arg {
+		case huc3ExtLatch:
+			c.setNibbles(0, 3, c.minutes)
+			c.setNibbles(3, 4, c.days)
+		case huc3ExtSet:
+			c.minutes = c.getNibbles(0, 3) % minutesPerDay
+			c.days = c.getNibbles(3, 4)
+			c.cycles = 0
+		case huc3ExtStatus:
+			c.response = 0x01
+		}
+	}
+}
+
+// Dump writes the clock as little endian values: a 64bit unix timestamp, the minute
+// of the day, the day, the minute and day of the alarm and a byte which is 1 if
+// the alarm is enabled.
+func (c *huc3Clock) Dump(w io.Writer) {
+	var buf [huc3FooterSize]byte
+	// the timestamp is moved back by the elapsed part of the current minute.
+	last := c.now().Unix() - int64(c.cycles/rtcCyclesPerSecond)
+	binary.LittleEndian.PutUint64(buf[0:], uint64(last))
+	binary.LittleEndian.PutUint16(buf[8:], c.minutes)
+	binary.LittleEndian.PutUint16(buf[10:], c.days)
+	binary.LittleEndian.PutUint16(buf[12:], c.getNibbles(huc3AlarmMinutes, 3))
+	binary.LittleEndian.PutUint16(buf[14:], c.getNibbles(huc3AlarmDays, 4))
+	buf[16] = c.mem[huc3AlarmEnabled] & 0x01
+	w.Write(buf[:])
+}
+
+// Load restores the clock from the data stored behind the cartridge ram and
+// advances it by the time since it was stored.
+func (c *huc3Clock) Load(data []byte) {
+	if len(data) != huc3FooterSize {
+		if len(data) > 0 {
+			log.Printf("unknown rtc data with %d bytes is ignored", len(data))
+		}
+		return
+	}
+	last := int64(binary.LittleEndian.Uint64(data[0:]))
+	c.minutes = binary.LittleEndian.Uint16(data[8:]) % minutesPerDay
+	c.days = binary.LittleEndian.Uint16(data[10:])
+	c.setNibbles(huc3AlarmMinutes, 3, binary.LittleEndian.Uint16(data[12:]))
+	c.setNibbles(huc3AlarmDays, 4, binary.LittleEndian.Uint16(data[14:]))
+	c.mem[huc3AlarmEnabled] = data[16] & 0x01
+	c.cycles = 0
+
+	if secs := c.now().Unix() - last; secs > 0 {
+		c.addMinutes(secs / 60)
+		c.cycles = uint32(secs%60) * rtcCyclesPerSecond
+	}
+}
+
+func (c *huc3Clock) SaveState(w *savestate.Writer) {
+	w.U16(c.minutes)
+	w.U16(c.days)
+	w.U32(c.cycles)
+	w.Bytes(c.mem[:])
+	w.U8(c.addr)
+	w.U8(c.cmd)
+	w.U8(c.response)
+}
+
+func (c *huc3Clock) LoadState(r *savestate.Reader) {
+	c.minutes = r.U16()
+	c.days = r.U16()
+	c.cycles = r.U32()
+	if c.minutes >= minutesPerDay || c.cycles >= huc3CyclesPerMinute {
+		r.Failf("savestate: invalid huc3 clock state")
+		c.minutes, c.cycles = 0, 0
+	}
+	r.Bytes(c.mem[:])
+	c.addr = r.U8()
+	c.cmd = r.U8() & 0x7F
+	c.response = r.U8()
+}
@@ -0,0 +1,146 @@
+package cartridge
+
+import (
+	"io"
+	"sync/atomic"
+
+	"github.com/boombuler/goboy2/savestate"
+)
+
+// InfraredTransfer connects the infrared port of a cartridge with the outside world.
+type InfraredTransfer interface {
+	// SetLED is called whenever the cartridge switches its LED on or off.
+	SetLED(on bool)
+	// Light returns true if the sensor of the cartridge receives light.
+	Light() bool
+}
+
+// InfraredPort is implemented by MBCs with an infrared port.
+type InfraredPort interface {
+	SetInfrared(t InfraredTransfer)
+}
+
+type nullInfrared struct{}
+
+func (n nullInfrared) SetLED(on bool) {}
+func (n nullInfrared) Light() bool    { return false }
+
+// InfraredLoopback is a transfer whose sensor receives the light of its own
+// LED, like a cartridge in front of a mirror.
+type InfraredLoopback struct {
+	led bool
+}
+
+func (l *InfraredLoopback) SetLED(on bool) { l.led = on }
+func (l *InfraredLoopback) Light() bool    { return l.led }
+
+// InfraredConn exchanges the state of the LED with the cartridge of another
+// emulator over a connection. Every change is sent as a single byte, 1 for on
+// and 0 for off. The emulators do not run in sync, so only transfers which
+// do not depend on the exact timing of the light work.
+type InfraredConn struct {
+	conn  io.ReadWriteCloser
+	out   chan byte
+	light int32
+}
+
+// NewInfraredConn starts exchanging the LED over conn. The connection is
+// closed by Close.
+func NewInfraredConn(conn io.ReadWriteCloser) *InfraredConn {
+	ic := &InfraredConn{conn: conn, out: make(chan byte, 256)}
+	go ic.read()
+	go ic.write()
+	return ic
+}
+
+func (ic *InfraredConn) read() {
+	var buf [1]byte
+	for {
+		if _, err := ic.conn.Read(buf[:]); err != nil {
+			atomic.StoreInt32(&ic.light, 0)
+			return
+		}
+		atomic.StoreInt32(&ic.light, int32(buf[0]&0x01))
+	}
+}
+
+func (ic *InfraredConn) write() {
+	for b := range ic.out {
+		if _, err := ic.conn.Write([]byte{b}); err != nil {
+			return
+		}
+	}
+}
+
+// SetLED sends the state of the LED without waiting for the other emulator.
+// The change is dropped if the other emulator does not keep up.
+func (ic *InfraredConn) SetLED(on bool) {
+	var b byte
+	if on {
+		b = 1
+	}
+	select {
+	case ic.out <- b:
+	default:
+	}
+}
+
+// Light returns true if the LED of the other emulator is on.
+func (ic *InfraredConn) Light() bool {
+	return atomic.LoadInt32(&ic.light) != 0
+}
+
+// Close closes the connection. SetLED must not be called afterwards.
+func (ic *InfraredConn) Close() error {
+	close(ic.out)
+	return ic.conn.Close()
+}
+
+// infrared is the IR register of the Hudson mappers.
+type infrared struct {
+	transfer InfraredTransfer
+	led      bool
+}
+
+func (ir *infrared) SetInfrared(t InfraredTransfer) {
+	if t == nil {
+		t = nullInfrared{}
+	}
+	ir.transfer = t
+}
+
+func (ir *infrared) readIR() byte {
+	if ir.transfer.Light() {
+		return 0xC1
+	}
+	return 0xC0
+}
+
+func (ir *infrared) writeIR(value byte) {
+	if led := value&0x01 != 0; led != ir.led {
+		ir.led = led
+		ir.transfer.SetLED(led)
+	}
+}
+
+func (ir *infrared) saveState(w *savestate.Writer) {
+	w.Bool(ir.led)
+}
+
+func (ir *infrared) loadState(r *savestate.Reader) {
+	if r.Bool() {
+		ir.writeIR(0x01)
+	} else {
+		ir.writeIR(0x00)
+	}
+}
+
+// SetInfrared connects the infrared port of the cartridge with the given transfer.
+// It returns false if the cartridge has no infrared port.
+func (c *Cartridge) SetInfrared(t InfraredTransfer) bool {
+	if p, ok := c.Mapper().(InfraredPort); ok {
+		p.SetInfrared(t)
+		return true
+	}
+	return false
+}
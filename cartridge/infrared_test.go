@@ -0,0 +1,53 @@
+package cartridge
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestInfraredConn(t *testing.T) {
+	c1, c2 := net.Pipe()
+	a, b := NewInfraredConn(c1), NewInfraredConn(c2)
+	waitLight := func(ic *InfraredConn, on bool) {
+		t.Helper()
+		for start := time.Now(); ic.Light() != on; time.Sleep(time.Millisecond) {
+			if time.Since(start) > time.Second {
+				t.Fatalf("Expected the light to be %v", on)
+			}
+		}
+	}
+
+	a.SetLED(true)
+	waitLight(b, true)
+	if a.Light() {
+		t.Error("Expected the own LED not to be seen")
+	}
+	b.SetLED(true)
+	waitLight(a, true)
+	a.SetLED(false)
+	waitLight(b, false)
+
+	// the light goes out when the other emulator disconnects
+	b.Close()
+	waitLight(a, false)
+	a.Close()
+}
+
+func TestInfraredLoopback(t *testing.T) {
+	c := &Cartridge{ROMSize: 0x8000, RAMSize: 0x2000}
+	m, err := createHuC1(c, make([]byte, 0x8000), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.MBC = m
+	c.SetInfrared(new(InfraredLoopback))
+	m.Write(0x0000, 0x0E)
+	if v := m.Read(0xA000); v != 0xC0 {
+		t.Errorf("Expected C0 with the LED off but got %02X", v)
+	}
+	m.Write(0xA000, 0x01)
+	if v := m.Read(0xA000); v != 0xC1 {
+		t.Errorf("Expected C1 with the LED on but got %02X", v)
+	}
+}
@@ -2,14 +2,15 @@ package cartridge
 
 import (
 	"fmt"
-	"io"
+
+	"github.com/boombuler/goboy2/savestate"
 )
 
 type mbc0 struct {
-	rom     []rombank
-	ram     rambank
-	hasRam  bool
-	battery BatteryStream
+	rom    []rombank
+	ram    rambank
+	hasRam bool
+	batteryRAM
 }
 
 func createMBC0(c *Cartridge, data []byte, hasRam bool, bat Battery) (MBC, error) {
@@ -23,10 +24,13 @@ func createMBC0(c *Cartridge, data []byte, hasRam bool, bat Battery) (MBC, error
 		},
 		hasRam: hasRam,
 	}
-	if bat != nil {
-		m.battery = bat.Open()
+	if hasRam {
+		m.battery = bat
+	}
+	m.dump = func() []byte { return append([]byte(nil), m.ram[:]...) }
+	if err := m.load(func(data []byte) { copy(m.ram[:], data) }); err != nil {
+		return nil, err
 	}
-	m.loadRAM()
 	return m, nil
 }
 
@@ -41,27 +45,15 @@ func (m *mbc0) Read(addr uint16) byte {
 func (m *mbc0) Write(addr uint16, value byte) {
 	if m.hasRam && addr >= 0xA000 && addr <= 0xBFFF {
 		m.ram[addr-0xA000] = value
+		m.dirty = true
 	}
 }
 
-func (m *mbc0) saveRAM() {
-	if m.battery != nil && m.hasRam {
-		m.battery.Seek(0, io.SeekStart)
-		m.battery.Write(m.ram[:])
-	}
+func (m *mbc0) SaveState(w *savestate.Writer) {
+	w.Bytes(m.ram[:])
 }
 
-func (m *mbc0) Shutdown() {
-	m.saveRAM()
-	if m.battery != nil {
-		m.battery.Close()
-		m.battery = nil
-	}
-}
-
-func (m *mbc0) loadRAM() {
-	if m.battery != nil && m.hasRam {
-		m.battery.Seek(0, io.SeekStart)
-		m.battery.Read(m.ram[:])
-	}
+func (m *mbc0) LoadState(r *savestate.Reader) {
+	r.Bytes(m.ram[:])
+	m.dirty = true
 }
@@ -1,9 +1,13 @@
 package cartridge
 
-import "bytes"
+import (
+	"bytes"
+
+	"github.com/boombuler/goboy2/savestate"
+)
 
 type mbc1 struct {
-	battery  Battery
+	batteryRAM
 	rombanks []rombank
 	rambanks []rambank
 
@@ -21,7 +25,9 @@ const (
 
 func createMBC1(c *Cartridge, data []byte, bat Battery) (MBC, error) {
 	m := new(mbc1)
-	m.battery = bat
+	if c.RAMSize > 0 {
+		m.battery = bat
+	}
 	for rs := c.ROMSize; rs > 0; rs -= rombankSize {
 		m.rombanks = append(m.rombanks, rombank(data[c.ROMSize-rs:c.ROMSize-rs+rombankSize]))
 	}
@@ -55,7 +61,10 @@ func createMBC1(c *Cartridge, data []byte, bat Battery) (MBC, error) {
 	m.bank1 = 1
 	m.bank2 = 0
 	m.mode = 0
-	m.loadRAM()
+	m.dump = func() []byte { return dumpRAMBanks(m.rambanks) }
+	if err := m.load(func(data []byte) { restoreRAMBanks(m.rambanks, data) }); err != nil {
+		return nil, err
+	}
 	return m, nil
 }
 
@@ -79,13 +88,17 @@ func (m *mbc1) Read(addr uint16) byte {
 	return 0xFF
 }
 
+func (m *mbc1) ROMBank(addr uint16) int {
+	if addr < rombankSize {
+		return m.getLoROMBank()
+	}
+	return m.getHiROMBank()
+}
+
 func (m *mbc1) Write(addr uint16, value byte) {
 	switch {
 	case addr >= 0x0000 && addr <= 0x1FFF:
 		m.ramEnabled = value&0x0F == 0x0A
-		if !m.ramEnabled {
-			m.saveRAM()
-		}
 	case addr >= 0x2000 && addr <= 0x3FFF:
 		val := int(value & 0x1F)
 		if val == 0 {
@@ -99,6 +112,7 @@ func (m *mbc1) Write(addr uint16, value byte) {
 	case addr >= 0xA000 && addr <= 0xBFFF:
 		if m.ramEnabled && m.hasRAM() {
 			m.rambanks[m.getRAMBank()][addr-0xA000] = value
+			m.dirty = true
 		}
 	}
 }
@@ -133,26 +147,29 @@ func (m *mbc1) getRAMBank() int {
 	return 0
 }
 
-func (m *mbc1) Shutdown() {
-	m.saveRAM()
+func (m *mbc1) SaveState(w *savestate.Writer) {
+	w.Int(m.bank1)
+	w.Int(m.bank2)
+	w.Bool(m.ramEnabled)
+	w.U8(m.mode)
+	saveRAMBanks(w, m.rambanks)
 }
 
-func (m *mbc1) saveRAM() {
-	if m.battery != nil && m.hasRAM() {
-		w := m.battery.Open()
-		for i := 0; i < len(m.rambanks); i++ {
-			w.Write(m.rambanks[i][:])
-		}
-		w.Close()
-	}
+func (m *mbc1) LoadState(r *savestate.Reader) {
+	m.bank1 = r.Int() & 0x1F
+	m.bank2 = r.Int() & 0x03
+	m.ramEnabled = r.Bool()
+	m.mode = r.U8() & 0x01
+	loadRAMBanks(r, m.rambanks)
+	m.dirty = true
 }
 
-func (m *mbc1) loadRAM() {
-	if m.battery != nil && m.battery.HasData() && m.hasRAM() {
-		r := m.battery.Open()
-		for i := 0; i < len(m.rambanks); i++ {
-			r.Read(m.rambanks[i][:])
-		}
-		r.Close()
+// createMBC1Multicart creates a MBC1 which is wired like a multicart, regardless of its content.
+func createMBC1Multicart(c *Cartridge, data []byte, bat Battery) (MBC, error) {
+	m, err := createMBC1(c, data, bat)
+	if err != nil {
+		return nil, err
 	}
+	m.(*mbc1).multicard = true
+	return m, nil
 }
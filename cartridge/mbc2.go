@@ -2,6 +2,8 @@ package cartridge
 
 import (
 	"fmt"
+
+	"github.com/boombuler/goboy2/savestate"
 )
 
 const mbc2RAMSize = 0x0200
@@ -11,7 +13,7 @@ type mbc2 struct {
 	rambank  [mbc2RAMSize]byte
 	romb     int
 	ramg     bool
-	battery  Battery
+	batteryRAM
 }
 
 func createMBC2(c *Cartridge, data []byte, bat Battery) (MBC, error) {
@@ -25,7 +27,10 @@ func createMBC2(c *Cartridge, data []byte, bat Battery) (MBC, error) {
 	m.romb = 1
 	m.ramg = false
 	m.battery = bat
-	m.loadRAM()
+	m.dump = func() []byte { return append([]byte(nil), m.rambank[:]...) }
+	if err := m.load(func(data []byte) { copy(m.rambank[:], data) }); err != nil {
+		return nil, err
+	}
 	return m, nil
 }
 
@@ -45,13 +50,17 @@ func (m *mbc2) Read(addr uint16) byte {
 
 	return 0xFF
 }
+
+func (m *mbc2) ROMBank(addr uint16) int {
+	if addr < rombankSize {
+		return 0
+	}
+	return m.romb
+}
 func (m *mbc2) Write(addr uint16, value byte) {
 	if addr < 0x4000 {
 		if addr&0x0100 == 0 {
 			m.ramg = value&0x0F == 0x0A
-			if !m.ramg {
-				m.saveRAM()
-			}
 		} else {
 			val := int(value & 0x0F)
 			if val == 0 {
@@ -61,25 +70,19 @@ func (m *mbc2) Write(addr uint16, value byte) {
 		}
 	} else if m.ramg && addr >= 0xA000 && addr < 0xC000 {
 		m.rambank[(addr-0xA000)%mbc2RAMSize] = value
+		m.dirty = true
 	}
 }
 
-func (m *mbc2) Shutdown() {
-	m.saveRAM()
+func (m *mbc2) SaveState(w *savestate.Writer) {
+	w.Int(m.romb)
+	w.Bool(m.ramg)
+	w.Bytes(m.rambank[:])
 }
 
-func (m *mbc2) saveRAM() {
-	if m.battery != nil {
-		w := m.battery.Open()
-		w.Write(m.rambank[:])
-		w.Close()
-	}
-}
-
-func (m *mbc2) loadRAM() {
-	if m.battery != nil && m.battery.HasData() {
-		r := m.battery.Open()
-		r.Read(m.rambank[:])
-		r.Close()
-	}
+func (m *mbc2) LoadState(r *savestate.Reader) {
+	m.romb = r.Int() % len(m.rombanks)
+	m.ramg = r.Bool()
+	r.Bytes(m.rambank[:])
+	m.dirty = true
 }
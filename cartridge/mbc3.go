@@ -1,11 +1,13 @@
 package cartridge
 
 import (
-	"io"
+	"bytes"
+
+	"github.com/boombuler/goboy2/savestate"
 )
 
 type mbc3 struct {
-	battery    BatteryStream
+	batteryRAM
 	rombanks   []rombank
 	activerom  int
 	rambanks   []rambank
@@ -16,9 +18,6 @@ type mbc3 struct {
 
 func createMBC3(c *Cartridge, data []byte, hasTimer bool, bat Battery) (MBC, error) {
 	m := new(mbc3)
-	if bat != nil {
-		m.battery = bat.Open()
-	}
 	for rs := c.ROMSize; rs > 0; rs -= rombankSize {
 		m.rombanks = append(m.rombanks, rombank(data[c.ROMSize-rs:c.ROMSize-rs+rombankSize]))
 	}
@@ -29,7 +28,13 @@ func createMBC3(c *Cartridge, data []byte, hasTimer bool, bat Battery) (MBC, err
 	if hasTimer {
 		m.rtc = newRealTimeClock()
 	}
-	m.loadRAM()
+	if m.hasRam() || m.rtc != nil {
+		m.battery = bat
+	}
+	m.dump = m.dumpRAM
+	if err := m.load(m.restoreRAM); err != nil {
+		return nil, err
+	}
 	return m, nil
 }
 
@@ -56,20 +61,26 @@ func (m *mbc3) Read(addr uint16) byte {
 	return 0x00
 }
 
+func (m *mbc3) ROMBank(addr uint16) int {
+	if addr < rombankSize {
+		return 0
+	}
+	return m.activerom
+}
+
 func (m *mbc3) Write(addr uint16, value byte) {
 	if addr >= 0xA000 && addr < 0xC000 {
 		if m.ramEnabled {
 			if m.activeram <= 0x03 && m.hasRam() {
 				m.rambanks[m.activeram][addr-0xA000] = value
+				m.dirty = true
 			} else if m.rtc != nil {
 				m.rtc.Write(m.activeram, value)
+				m.dirty = true
 			}
 		}
 	} else if addr >= 0x0000 && addr <= 0x1FFF {
 		m.ramEnabled = value&0x0F == 0x0A
-		if !m.ramEnabled {
-			m.saveRAM()
-		}
 	} else if addr >= 0x2000 && addr <= 0x3FFF {
 		m.activerom = int(value & 0x7F)
 		if m.activerom == 0x00 {
@@ -86,34 +97,49 @@ func (m *mbc3) Write(addr uint16, value byte) {
 	}
 }
 
-func (m *mbc3) Shutdown() {
-	m.saveRAM()
-	m.battery.Close()
-	m.battery = nil
+func (m *mbc3) Step() {
+	if m.rtc != nil {
+		m.rtc.Step()
+	}
 }
-func (m *mbc3) saveRAM() {
-	if m.battery != nil && (m.hasRam() || m.rtc != nil) {
-		m.battery.Seek(0, io.SeekStart)
-		if m.hasRam() {
-			for i := 0; i < len(m.rambanks); i++ {
-				m.battery.Write(m.rambanks[i][:])
-			}
-		}
-		if m.rtc != nil {
-			m.rtc.Dump(m.battery)
-		}
+
+// dumpRAM returns the ram followed by the clock data.
+func (m *mbc3) dumpRAM() []byte {
+	buf := bytes.NewBuffer(dumpRAMBanks(m.rambanks))
+	if m.rtc != nil {
+		m.rtc.Dump(buf)
 	}
+	return buf.Bytes()
 }
-func (m *mbc3) loadRAM() {
-	if m.battery != nil && (m.hasRam() || m.rtc != nil) {
-		m.battery.Seek(0, io.SeekStart)
-		if m.hasRam() {
-			for i := 0; i < len(m.rambanks); i++ {
-				m.battery.Read(m.rambanks[i][:])
-			}
-		}
-		if m.rtc != nil {
-			m.rtc.Load(m.battery)
-		}
+
+func (m *mbc3) restoreRAM(data []byte) {
+	data = restoreRAMBanks(m.rambanks, data)
+	if m.rtc != nil {
+		m.rtc.Load(data)
+	}
+}
+
+func (m *mbc3) SaveState(w *savestate.Writer) {
+	w.Int(m.activerom)
+	w.Int(m.activeram)
+	w.Bool(m.ramEnabled)
+	saveRAMBanks(w, m.rambanks)
+	if m.rtc != nil {
+		m.rtc.SaveState(w)
+	}
+}
+
+func (m *mbc3) LoadState(r *savestate.Reader) {
+	m.activerom = r.Int() & 0x7F
+	m.activeram = r.Int()
+	m.ramEnabled = r.Bool()
+	if m.activerom >= len(m.rombanks) || m.activeram < 0 || m.activeram > 0x0C {
+		r.Failf("savestate: invalid mbc3 bank selection")
+		m.activerom, m.activeram = 1, 0
+	}
+	loadRAMBanks(r, m.rambanks)
+	m.dirty = true
+	if m.rtc != nil {
+		m.rtc.LoadState(r)
 	}
 }
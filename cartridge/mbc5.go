@@ -1,24 +1,45 @@
 package cartridge
 
 import (
-	"io"
+	"github.com/boombuler/goboy2/savestate"
 )
 
 type mbc5 struct {
-	battery   BatteryStream
+	batteryRAM
 	rombanks  []rombank
 	activerom int
 
 	rambanks   []rambank
 	activeram  int
 	ramEnabled bool
+
+	// hasRumble is set for cartridges with a rumble motor, which is controlled
+	// by bit 3 of the ram bank register.
+	hasRumble bool
+	rumbleOn  bool
+	rumble    func(on bool)
 }
 
-func createMBC5(c *Cartridge, data []byte, bat Battery) (MBC, error) {
-	m := new(mbc5)
-	if bat != nil {
-		m.battery = bat.Open()
+// RumblePort is implemented by MBCs with a rumble motor.
+type RumblePort interface {
+	// SetRumble sets the function which is called whenever the motor is switched on or off.
+	SetRumble(fn func(on bool))
+}
+
+// SetRumble sets the function which is called whenever the rumble motor of the
+// cartridge is switched on or off. It returns false if the cartridge has no motor.
+func (c *Cartridge) SetRumble(fn func(on bool)) bool {
+	if p, ok := c.Mapper().(RumblePort); ok {
+		p.SetRumble(fn)
+		return true
 	}
+	return false
+}
+
+func createMBC5(c *Cartridge, data []byte, hasRumble bool, bat Battery) (MBC, error) {
+	m := new(mbc5)
+	m.hasRumble = hasRumble
+	m.battery = bat
 	for rs := c.ROMSize; rs > 0; rs -= rombankSize {
 		m.rombanks = append(m.rombanks, rombank(data[c.ROMSize-rs:c.ROMSize-rs+rombankSize]))
 	}
@@ -35,10 +56,28 @@ func createMBC5(c *Cartridge, data []byte, bat Battery) (MBC, error) {
 	}
 
 	m.activeram = 0
-	m.loadRAM()
+	m.dump = func() []byte { return dumpRAMBanks(m.rambanks) }
+	if err := m.load(func(data []byte) { restoreRAMBanks(m.rambanks, data) }); err != nil {
+		return nil, err
+	}
 	return m, nil
 }
 
+func (m *mbc5) SetRumble(fn func(on bool)) {
+	if m.hasRumble {
+		m.rumble = fn
+	}
+}
+
+func (m *mbc5) setRumble(on bool) {
+	if on != m.rumbleOn {
+		m.rumbleOn = on
+		if m.rumble != nil {
+			m.rumble(on)
+		}
+	}
+}
+
 func (m *mbc5) hasRam() bool {
 	return len(m.rambanks) > 0
 }
@@ -59,47 +98,49 @@ func (m *mbc5) Read(addr uint16) byte {
 	return 0xFF
 }
 
+func (m *mbc5) ROMBank(addr uint16) int {
+	if addr < rombankSize {
+		return 0
+	}
+	return m.activerom % len(m.rombanks)
+}
+
 func (m *mbc5) Write(addr uint16, value byte) {
 	switch {
 	case addr >= 0x0000 && addr <= 0x1FFF:
 		m.ramEnabled = value&0x0F == 0x0A
-		if !m.ramEnabled {
-			m.saveRAM()
-		}
 	case addr >= 0x2000 && addr < 0x3000:
 		m.activerom = (m.activerom & 0x100) | int(value)
 	case addr >= 0x3000 && addr < 0x4000:
 		m.activerom = (m.activerom & 0x0ff) | (int(value&1) << 8)
 	case addr >= 0x4000 && addr < 0x6000:
+		if m.hasRumble {
+			m.setRumble(value&0x08 != 0)
+			value &= 0x07
+		}
 		bank := int(value & 0x0f)
 		if bank < len(m.rambanks) {
 			m.activeram = bank
 		}
 	case addr >= 0xA000 && addr < 0xC000 && m.ramEnabled:
 		m.rambanks[m.activeram%len(m.rambanks)][addr-0xA000] = value
+		m.dirty = true
 	}
 }
 
-func (m *mbc5) Shutdown() {
-	m.saveRAM()
-	m.battery.Close()
-	m.battery = nil
+func (m *mbc5) SaveState(w *savestate.Writer) {
+	w.Int(m.activerom)
+	w.Int(m.activeram)
+	w.Bool(m.ramEnabled)
+	w.Bool(m.rumbleOn)
+	saveRAMBanks(w, m.rambanks)
 }
 
-func (m *mbc5) saveRAM() {
-	if m.battery != nil && m.hasRam() {
-		m.battery.Seek(0, io.SeekStart)
-		for i := 0; i < len(m.rambanks); i++ {
-			m.battery.Write(m.rambanks[i][:])
-		}
-	}
-}
-
-func (m *mbc5) loadRAM() {
-	if m.battery != nil && m.hasRam() {
-		m.battery.Seek(0, io.SeekStart)
-		for i := 0; i < len(m.rambanks); i++ {
-			m.battery.Read(m.rambanks[i][:])
-		}
-	}
+func (m *mbc5) LoadState(r *savestate.Reader) {
+	m.activerom = r.Int() & 0x1FF
+	m.activeram = r.Int() & 0x0F
+	m.ramEnabled = r.Bool()
+	m.setRumble(r.Bool() && m.hasRumble)
+	loadRAMBanks(r, m.rambanks)
+	m.dirty = true
 }
@@ -0,0 +1,28 @@
+package cartridge
+
+import "testing"
+
+func TestMBC5Rumble(t *testing.T) {
+	c := &Cartridge{ROMSize: 0x8000, RAMSize: 0x8000}
+	m, err := createMBC5(c, make([]byte, 0x8000), true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.MBC = m
+	var changes []bool
+	if !c.SetRumble(func(on bool) { changes = append(changes, on) }) {
+		t.Fatal("Expected the cartridge to have a rumble motor")
+	}
+
+	m.Write(0x0000, 0x0A)
+	m.Write(0x4000, 0x09)
+	m.Write(0x4000, 0x0A)
+	m.Write(0xA000, 0x42)
+	m.Write(0x4000, 0x02)
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Errorf("Expected the motor to be switched on and off once but got %v", changes)
+	}
+	if v := m.Read(0xA000); v != 0x42 {
+		t.Errorf("Expected the rumble bit not to change the ram bank but got %02X", v)
+	}
+}
@@ -0,0 +1,165 @@
+package cartridge
+
+import (
+	"github.com/boombuler/goboy2/savestate"
+)
+
+const (
+	// accelerometer value for no acceleration and the change per g
+	accelCenter = 0x81D0
+	accelPerG   = 0x70
+)
+
+// Accelerometer provides the tilt of the cartridge for the MBC7.
+type Accelerometer interface {
+	// Tilt returns the acceleration along the x and the y axis in g.
+	Tilt() (x, y float64)
+}
+
+// AccelerometerPort is implemented by MBCs with an accelerometer.
+type AccelerometerPort interface {
+	SetAccelerometer(a Accelerometer)
+}
+
+// SetAccelerometer connects the accelerometer of the cartridge with the given input.
+// It returns false if the cartridge has no accelerometer.
+func (c *Cartridge) SetAccelerometer(a Accelerometer) bool {
+	if p, ok := c.Mapper().(AccelerometerPort); ok {
+		p.SetAccelerometer(a)
+		return true
+	}
+	return false
+}
+
+type mbc7 struct {
+	batteryRAM
+	rombanks  []rombank
+	activerom int
+	// the registers are only accessible if both enable registers are set.
+	ramEnabled1 bool
+	ramEnabled2 bool
+
+	accel   Accelerometer
+	latched bool
+	x, y    uint16
+	eeprom  eeprom
+}
+
+func createMBC7(c *Cartridge, data []byte, bat Battery) (MBC, error) {
+	m := new(mbc7)
+	for rs := c.ROMSize; rs > 0; rs -= rombankSize {
+		m.rombanks = append(m.rombanks, rombank(data[c.ROMSize-rs:c.ROMSize-rs+rombankSize]))
+	}
+	m.activerom = 1
+	m.x, m.y = accelCenter, accelCenter
+	for i := range m.eeprom.data {
+		m.eeprom.data[i] = 0xFFFF
+	}
+	m.battery = bat
+	m.dump = m.eeprom.dump
+	if err := m.load(m.eeprom.restore); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *mbc7) SetAccelerometer(a Accelerometer) {
+	m.accel = a
+}
+
+func (m *mbc7) Read(addr uint16) byte {
+	switch {
+	case addr < rombankSize:
+		return m.rombanks[0].Read(addr)
+	case addr < 2*rombankSize:
+		return m.rombanks[m.activerom%len(m.rombanks)].Read(addr)
+	case addr >= 0xA000 && addr < 0xB000:
+		if !m.ramEnabled1 || !m.ramEnabled2 {
+			break
+		}
+		switch (addr >> 4) & 0x0F {
+		case 0x02:
+			return byte(m.x)
+		case 0x03:
+			return byte(m.x >> 8)
+		case 0x04:
+			return byte(m.y)
+		case 0x05:
+			return byte(m.y >> 8)
+		case 0x06:
+			return 0x00
+		case 0x08:
+			return m.eeprom.Read()
+		}
+	}
+	return 0xFF
+}
+
+func (m *mbc7) ROMBank(addr uint16) int {
+	if addr < rombankSize {
+		return 0
+	}
+	return m.activerom % len(m.rombanks)
+}
+
+func (m *mbc7) Write(addr uint16, value byte) {
+	switch {
+	case addr < 0x2000:
+		m.ramEnabled1 = value == 0x0A
+	case addr < 0x4000:
+		m.activerom = int(value & 0x7F)
+	case addr < 0x6000:
+		m.ramEnabled2 = value == 0x40
+	case addr >= 0xA000 && addr < 0xB000:
+		if !m.ramEnabled1 || !m.ramEnabled2 {
+			return
+		}
+		switch (addr >> 4) & 0x0F {
+		case 0x00:
+			if value == 0x55 {
+				// erase the latched values
+				m.latched = false
+				m.x, m.y = 0x8000, 0x8000
+			}
+		case 0x01:
+			if value == 0xAA && !m.latched {
+				m.latchAccelerometer()
+			}
+		case 0x08:
+			if m.eeprom.Write(value) {
+				m.dirty = true
+			}
+		}
+	}
+}
+
+func (m *mbc7) latchAccelerometer() {
+	m.latched = true
+	var x, y float64
+	if m.accel != nil {
+		x, y = m.accel.Tilt()
+	}
+	m.x = uint16(accelCenter + int(x*accelPerG))
+	m.y = uint16(accelCenter + int(y*accelPerG))
+}
+
+func (m *mbc7) SaveState(w *savestate.Writer) {
+	w.Int(m.activerom)
+	w.Bool(m.ramEnabled1)
+	w.Bool(m.ramEnabled2)
+	w.Bool(m.latched)
+	w.U16(m.x)
+	w.U16(m.y)
+	m.eeprom.saveState(w)
+}
+
+func (m *mbc7) LoadState(r *savestate.Reader) {
+	m.activerom = r.Int() & 0x7F
+	m.ramEnabled1 = r.Bool()
+	m.ramEnabled2 = r.Bool()
+	m.latched = r.Bool()
+	m.x = r.U16()
+	m.y = r.U16()
+	m.eeprom.loadState(r)
+	m.dirty = true
+}
@@ -0,0 +1,83 @@
+package cartridge
+
+import "testing"
+
+type fixedTilt struct{ x, y float64 }
+
+func (f fixedTilt) Tilt() (float64, float64) { return f.x, f.y }
+
+// eepromClock shifts one bit into the eeprom of the MBC7 and returns DO.
+func eepromClock(m MBC, bit uint32) byte {
+	di := byte(bit&1) << 1
+	m.Write(0xA080, eepromCS|di)
+	m.Write(0xA080, eepromCS|eepromCLK|di)
+	return m.Read(0xA080) & eepromDO
+}
+
+func eepromSend(m MBC, value uint32, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		eepromClock(m, value>>uint(i))
+	}
+}
+
+func TestMBC7EEPROM(t *testing.T) {
+	c := &Cartridge{ROMSize: 0x8000}
+	bat := new(MemoryBattery)
+	m, err := createMBC7(c, make([]byte, 0x8000), bat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Write(0x0000, 0x0A)
+	m.Write(0x4000, 0x40)
+
+	// EWEN, then WRITE 0x1234 to address 5
+	eepromSend(m, 0x4C0, 11)
+	m.Write(0xA080, 0x00)
+	eepromSend(m, 0x505, 11)
+	eepromSend(m, 0x1234, 16)
+	m.Write(0xA080, 0x00)
+
+	// READ address 5
+	eepromSend(m, 0x605, 11)
+	var v uint16
+	for i := 0; i < 16; i++ {
+		v = v<<1 | uint16(eepromClock(m, 0))
+	}
+	m.Write(0xA080, 0x00)
+	if v != 0x1234 {
+		t.Errorf("Expected 1234 but got %04X", v)
+	}
+
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	m2, err := createMBC7(c, make([]byte, 0x8000), bat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := m2.(*mbc7).eeprom.data[5]; w != 0x1234 {
+		t.Errorf("Expected the eeprom to be loaded from the battery but got %04X", w)
+	}
+}
+
+func TestMBC7Accelerometer(t *testing.T) {
+	c := &Cartridge{ROMSize: 0x8000}
+	m, err := createMBC7(c, make([]byte, 0x8000), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.MBC = m
+	if !c.SetAccelerometer(fixedTilt{1, -0.5}) {
+		t.Fatal("Expected the MBC7 to accept an accelerometer")
+	}
+	m.Write(0x0000, 0x0A)
+	m.Write(0x4000, 0x40)
+	m.Write(0xA000, 0x55)
+	m.Write(0xA010, 0xAA)
+
+	x := uint16(m.Read(0xA020)) | uint16(m.Read(0xA030))<<8
+	y := uint16(m.Read(0xA040)) | uint16(m.Read(0xA050))<<8
+	if x != accelCenter+accelPerG || y != accelCenter-accelPerG/2 {
+		t.Errorf("Expected %04X/%04X but got %04X/%04X", accelCenter+accelPerG, accelCenter-accelPerG/2, x, y)
+	}
+}
@@ -0,0 +1,175 @@
+package cartridge
+
+import (
+	"github.com/boombuler/goboy2/savestate"
+)
+
+// mmm01HeaderOffset is the offset of the menu from the end of the rom.
+// The header of the cartridge is part of the menu.
+const mmm01HeaderOffset = 2 * rombankSize
+
+type mmm01 struct {
+	batteryRAM
+	rombanks []rombank
+	rambanks []rambank
+
+	// mapped is set as soon as the menu selected a game. Until then the
+	// menu in the last 32KB of the rom is visible and all registers can be
+	// written. Once mapped, only the bits of the game's own mbc are writable.
+	mapped     bool
+	romBank    int  // bits 0-4 are the bank of the game, bits 5-8 select the game
+	ramBank    int  // bits 0-1 are the bank of the game, bits 2-3 select the game
+	romMask    int  // rom bank bits 1-4 which are locked for the game
+	ramMask    int  // ram bank bits 0-1 which are locked for the game
+	mode       byte // the mbc1 mode of the game
+	modeLocked bool
+	ramEnabled bool
+}
+
+// isMMM01 checks if the header in the last 32KB of the rom describes a MMM01 cartridge.
+func isMMM01(rom []byte) bool {
+	if len(rom) < 2*mmm01HeaderOffset || len(rom)%rombankSize != 0 {
+		return false
+	}
+	header := rom[len(rom)-mmm01HeaderOffset:]
+	switch header[0x0147] {
+	case 0x0B, 0x0C, 0x0D:
+		return headerChecksum(header) == header[0x014D]
+	}
+	return false
+}
+
+func createMMM01(c *Cartridge, data []byte, bat Battery) (MBC, error) {
+	m := new(mmm01)
+	for i := 0; i+rombankSize <= len(data); i += rombankSize {
+		m.rombanks = append(m.rombanks, rombank(data[i:i+rombankSize]))
+	}
+	m.rambanks = make([]rambank, c.RAMSize/rambankSize)
+	if len(m.rambanks) > 0 {
+		m.battery = bat
+	}
+	m.dump = func() []byte { return dumpRAMBanks(m.rambanks) }
+	if err := m.load(func(data []byte) { restoreRAMBanks(m.rambanks, data) }); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// romBankBits returns the bits of the rom bank which the game can change.
+func (m *mmm01) romBankBits() int {
+	return 0x1F &^ (m.romMask << 1)
+}
+
+func (m *mmm01) bank0() int {
+	return (m.romBank &^ m.romBankBits()) % len(m.rombanks)
+}
+
+func (m *mmm01) bank1() int {
+	bank := m.romBank
+	if bank&m.romBankBits() == 0 {
+		// like the mbc1 the game can not select its bank 0.
+		bank |= 1
+	}
+	return bank % len(m.rombanks)
+}
+
+func (m *mmm01) ramBankIdx() int {
+	bank := m.ramBank
+	if m.mode == 0 {
+		// like the mbc1 the game only switches ram banks in mode 1.
+		bank &= m.ramMask | 0x0C
+	}
+	return bank % len(m.rambanks)
+}
+
+func (m *mmm01) Read(addr uint16) byte {
+	switch {
+	case addr < 2*rombankSize && !m.mapped:
+		return m.rombanks[len(m.rombanks)-2+int(addr/rombankSize)].Read(addr)
+	case addr < rombankSize:
+		return m.rombanks[m.bank0()].Read(addr)
+	case addr < 2*rombankSize:
+		return m.rombanks[m.bank1()].Read(addr)
+	case addr >= 0xA000 && addr < 0xC000:
+		if m.ramEnabled && len(m.rambanks) > 0 {
+			return m.rambanks[m.ramBankIdx()][addr-0xA000]
+		}
+	}
+	return 0xFF
+}
+
+func (m *mmm01) ROMBank(addr uint16) int {
+	switch {
+	case !m.mapped:
+		return len(m.rombanks) - 2 + int(addr/rombankSize)
+	case addr < rombankSize:
+		return m.bank0()
+	}
+	return m.bank1()
+}
+
+func (m *mmm01) Write(addr uint16, value byte) {
+	v := int(value)
+	switch {
+	case addr < 0x2000:
+		m.ramEnabled = value&0x0F == 0x0A
+		if !m.mapped {
+			m.ramMask = v >> 4 & 0x03
+			// setting bit 6 of the ram enable register starts the selected game.
+			m.mapped = value&0x40 != 0
+		}
+	case addr < 0x4000:
+		if !m.mapped {
+			m.romBank = m.romBank&^0x7F | v&0x7F
+			return
+		}
+		bits := m.romBankBits()
+		m.romBank = m.romBank&^bits | v&bits
+	case addr < 0x6000:
+		if !m.mapped {
+			m.ramBank = v & 0x0F
+			m.romBank = m.romBank&0x7F | v&0x30<<3
+			m.modeLocked = value&0x40 != 0
+			return
+		}
+		bits := 0x03 &^ m.ramMask
+		m.ramBank = m.ramBank&^bits | v&bits
+	case addr < 0x8000:
+		if !m.mapped {
+			m.romMask = v >> 2 & 0x0F
+		}
+		if !m.modeLocked {
+			m.mode = value & 0x01
+		}
+	case addr >= 0xA000 && addr < 0xC000:
+		if m.ramEnabled && len(m.rambanks) > 0 {
+			m.rambanks[m.ramBankIdx()][addr-0xA000] = value
+			m.dirty = true
+		}
+	}
+}
+
+func (m *mmm01) SaveState(w *savestate.Writer) {
+	w.Bool(m.mapped)
+	w.Int(m.romBank)
+	w.Int(m.ramBank)
+	w.Int(m.romMask)
+	w.Int(m.ramMask)
+	w.U8(m.mode)
+	w.Bool(m.modeLocked)
+	w.Bool(m.ramEnabled)
+	saveRAMBanks(w, m.rambanks)
+}
+
+func (m *mmm01) LoadState(r *savestate.Reader) {
+	m.mapped = r.Bool()
+	m.romBank = r.Int() & 0x1FF
+	m.ramBank = r.Int() & 0x0F
+	m.romMask = r.Int() & 0x0F
+	m.ramMask = r.Int() & 0x03
+	m.mode = r.U8() & 0x01
+	m.modeLocked = r.Bool()
+	m.ramEnabled = r.Bool()
+	loadRAMBanks(r, m.rambanks)
+	m.dirty = true
+}
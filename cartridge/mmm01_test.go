@@ -0,0 +1,134 @@
+package cartridge
+
+import (
+	"bytes"
+	"testing"
+)
+
+// newMMM01 creates a 128KB multicart with a 64KB game in the banks 0-3,
+// a 32KB game in the banks 4-5 and the menu in the banks 6-7.
+func newMMM01(t *testing.T) *Cartridge {
+	rom := make([]byte, 8*rombankSize)
+	for i := 0; i < 8; i++ {
+		rom[i*rombankSize] = byte(i)
+		rom[i*rombankSize+1] = byte(i)
+	}
+	// the first game claims to be a MBC1 cartridge
+	rom[0x0147] = 0x01
+	menu := rom[len(rom)-mmm01HeaderOffset:]
+	copy(menu[0x0134:], "MENU")
+	menu[0x0147] = 0x0D
+	menu[0x0149] = 0x03
+	menu[0x014D] = headerChecksum(menu)
+
+	c, err := Load(bytes.NewReader(rom), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestMMM01(t *testing.T) {
+	c := newMMM01(t)
+	if c.Title != "MENU" {
+		t.Errorf("Expected the header of the menu but got %q", c.Title)
+	}
+	if b0, b1 := c.Read(0x0000), c.Read(0x4000); b0 != 6 || b1 != 7 {
+		t.Errorf("Expected the menu in banks 6 and 7 but got %d and %d", b0, b1)
+	}
+
+	// select the 64KB game by locking the rom bank bits 2-4 and start it
+	c.Write(0x2000, 0x00)
+	c.Write(0x6000, 0x0E<<2)
+	c.Write(0x0000, 0x40)
+	if b0, b1 := c.Read(0x0000), c.Read(0x4000); b0 != 0 || b1 != 1 {
+		t.Errorf("Expected the game in banks 0 and 1 but got %d and %d", b0, b1)
+	}
+	c.Write(0x2000, 0x03)
+	if b := c.Read(0x4000); b != 3 {
+		t.Errorf("Expected bank 3 of the game but got %d", b)
+	}
+	// the game can not leave its 64KB
+	c.Write(0x2000, 0x06)
+	if b := c.Read(0x4000); b != 2 {
+		t.Errorf("Expected bank 6 of the game to wrap to bank 2 but got %d", b)
+	}
+	c.Write(0x2000, 0x04)
+	if b := c.Read(0x4000); b != 1 {
+		t.Errorf("Expected bank 4 of the game to select bank 1 but got %d", b)
+	}
+	// the mask is locked once the game was started
+	c.Write(0x6000, 0x00)
+	c.Write(0x2000, 0x06)
+	if b := c.Read(0x4000); b != 2 {
+		t.Errorf("Expected the rom mask to be locked but got bank %d", b)
+	}
+}
+
+func TestMMM01SmallGame(t *testing.T) {
+	c := newMMM01(t)
+	// select the 32KB game by locking the rom bank bits 1-4 and ram bank 2
+	// for it.
+	c.Write(0x2000, 0x04)
+	c.Write(0x4000, 0x02)
+	c.Write(0x6000, 0x0F<<2)
+	c.Write(0x0000, 0x70)
+	if b0, b1 := c.Read(0x0000), c.Read(0x4000); b0 != 4 || b1 != 5 {
+		t.Errorf("Expected the game in banks 4 and 5 but got %d and %d", b0, b1)
+	}
+	c.Write(0x2000, 0x1E)
+	if b0, b1 := c.Read(0x0000), c.Read(0x4000); b0 != 4 || b1 != 5 {
+		t.Errorf("Expected the banks of the game to be locked but got %d and %d", b0, b1)
+	}
+
+	c.Write(0x0000, 0x0A)
+	c.Write(0x6000, 0x01)
+	c.Write(0xA000, 0x42)
+	c.Write(0x4000, 0x03)
+	if v := c.Read(0xA000); v != 0x42 {
+		t.Errorf("Expected the ram bank to be locked but read %02X", v)
+	}
+	c.Write(0x0000, 0x00)
+	c.Write(0x0000, 0x4A)
+	c.Write(0x4000, 0x00)
+	if v := c.Read(0xA000); v != 0x42 {
+		t.Errorf("Expected the game to stay mapped but read %02X", v)
+	}
+	if v := c.Mapper().(*mmm01).rambanks[2][0]; v != 0x42 {
+		t.Errorf("Expected the game to use ram bank 2 but it contains %02X", v)
+	}
+}
+
+func TestMMM01EnableRAMInMenu(t *testing.T) {
+	rom := make([]byte, 8*rombankSize)
+	for i := 0; i < 8; i++ {
+		rom[i*rombankSize] = byte(i)
+	}
+	menu := rom[len(rom)-mmm01HeaderOffset:]
+	menu[0x0147] = 0x0C
+	menu[0x0149] = 0x02
+	menu[0x014D] = headerChecksum(menu)
+
+	c, err := Load(bytes.NewReader(rom), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// enabling the ram without bit 6 keeps the menu mapped
+	c.Write(0x0000, 0x0A)
+	if b := c.Read(0x0000); b != 6 {
+		t.Errorf("Expected the menu to stay mapped but got bank %d", b)
+	}
+	c.Write(0xA000, 0x42)
+	if v := c.Read(0xA000); v != 0x42 {
+		t.Errorf("Expected the ram to be enabled in the menu but read %02X", v)
+	}
+	c.Write(0x2000, 0x04)
+	c.Write(0x6000, 0x0F<<2)
+	c.Write(0x0000, 0x40)
+	if b := c.Read(0x0000); b != 4 {
+		t.Errorf("Expected the game at bank 4 but got %d", b)
+	}
+	if v := c.Read(0xA000); v != 0xFF {
+		t.Errorf("Expected the ram to be disabled but read %02X", v)
+	}
+}
@@ -0,0 +1,57 @@
+package cartridge
+
+import (
+	"github.com/boombuler/goboy2/savestate"
+)
+
+// rocket is the mapper of the unlicensed Rocket Games cartridges. It switches the
+// 16KB bank at 0x4000 with an 8bit register at 0x2000-0x3FFF. The cartridges have
+// no ram.
+type rocket struct {
+	batteryRAM
+	rombanks []rombank
+	bank     int
+}
+
+func createRocket(c *Cartridge, data []byte) (MBC, error) {
+	m := new(rocket)
+	for i := 0; i+rombankSize <= len(data); i += rombankSize {
+		m.rombanks = append(m.rombanks, rombank(data[i:i+rombankSize]))
+	}
+	m.bank = 1
+	return m, nil
+}
+
+func (m *rocket) Read(addr uint16) byte {
+	switch {
+	case addr < rombankSize:
+		return m.rombanks[0].Read(addr)
+	case addr < 2*rombankSize:
+		return m.rombanks[m.bank%len(m.rombanks)].Read(addr)
+	}
+	return 0xFF
+}
+
+func (m *rocket) ROMBank(addr uint16) int {
+	if addr < rombankSize {
+		return 0
+	}
+	return m.bank % len(m.rombanks)
+}
+
+func (m *rocket) Write(addr uint16, value byte) {
+	if addr >= 0x2000 && addr < 0x4000 {
+		m.bank = int(value)
+		if m.bank == 0 {
+			m.bank = 1
+		}
+	}
+}
+
+func (m *rocket) SaveState(w *savestate.Writer) {
+	w.Int(m.bank)
+}
+
+func (m *rocket) LoadState(r *savestate.Reader) {
+	m.bank = r.Int() & 0xFF
+}
@@ -1,5 +1,7 @@
 package cartridge
 
+import "github.com/boombuler/goboy2/savestate"
+
 const (
 	rombankSize = 0x4000
 	rambankSize = 0x2000
@@ -12,3 +14,37 @@ func (m rombank) Read(addr uint16) byte {
 }
 
 type rambank [rambankSize]byte
+
+// dumpRAMBanks returns the content of all banks.
+func dumpRAMBanks(banks []rambank) []byte {
+	res := make([]byte, 0, len(banks)*rambankSize)
+	for i := range banks {
+		res = append(res, banks[i][:]...)
+	}
+	return res
+}
+
+// restoreRAMBanks fills the banks with the given data and returns the data that was left.
+func restoreRAMBanks(banks []rambank, data []byte) []byte {
+	for i := 0; i < len(banks) && len(data) > 0; i++ {
+		data = data[copy(banks[i][:], data):]
+	}
+	return data
+}
+
+func saveRAMBanks(w *savestate.Writer, banks []rambank) {
+	w.Int(len(banks))
+	for i := range banks {
+		w.Bytes(banks[i][:])
+	}
+}
+
+func loadRAMBanks(r *savestate.Reader, banks []rambank) {
+	if cnt := r.Int(); cnt != len(banks) {
+		r.Failf("savestate: ram bank count %d does not match the cartridge", cnt)
+		return
+	}
+	for i := range banks {
+		r.Bytes(banks[i][:])
+	}
+}
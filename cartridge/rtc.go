@@ -1,69 +1,116 @@
 package cartridge
 
 import (
+	"encoding/binary"
 	"io"
-	"sync"
+	"log"
 	"time"
+
+	"github.com/boombuler/goboy2/savestate"
+)
+
+// rtcCyclesPerSecond is the number of normal speed m-cycles per second.
+const rtcCyclesPerSecond = 4194304 / 4
+
+const (
+	// rtcFooterSize is the size of the clock data stored behind the cartridge ram.
+	rtcFooterSize = 48
+	// legacyRTCSize is the size of the clock data written by older versions.
+	legacyRTCSize = 14
+
+	rtcMaxDays = 512
+	secsPerDay = 24 * 60 * 60
+	rtcDHDay   = 0x01
+	rtcDHHalt  = 0x40
+	rtcDHCarry = 0x80
 )
 
 type rtcTime struct {
-	s byte
-	m byte
-	h byte
-	d uint16
+	s     byte
+	m     byte
+	h     byte
+	d     uint16
+	carry bool
+}
+
+// inc advances the time by one second. The counters only carry when they reach
+// their regular limit; invalid values overflow at the bit width of the register.
+func (t *rtcTime) inc() {
+	if t.s = (t.s + 1) & 0x3F; t.s != 60 {
+		return
+	}
+	t.s = 0
+	if t.m = (t.m + 1) & 0x3F; t.m != 60 {
+		return
+	}
+	t.m = 0
+	if t.h = (t.h + 1) & 0x1F; t.h != 24 {
+		return
+	}
+	t.h = 0
+	if t.d++; t.d == rtcMaxDays {
+		t.d = 0
+		t.carry = true
+	}
+}
+
+// add advances the time by the given number of seconds.
+func (t *rtcTime) add(secs int64) {
+	// step until the time is valid, so the remaining seconds can be added arithmetically.
+	for secs > 0 && (t.s >= 60 || t.m >= 60 || t.h >= 24) {
+		t.inc()
+		secs--
+	}
+	if secs <= 0 {
+		return
+	}
+	total := int64(t.s) + int64(t.m)*60 + int64(t.h)*60*60 + int64(t.d)*secsPerDay + secs
+	t.s = byte(total % 60)
+	t.m = byte(total / 60 % 60)
+	t.h = byte(total / (60 * 60) % 24)
+	days := total / secsPerDay
+	if days >= rtcMaxDays {
+		t.carry = true
+	}
+	t.d = uint16(days % rtcMaxDays)
 }
 
 type rtc struct {
-	t  *rtcTime
+	t  rtcTime
 	lt *rtcTime
 
 	latch  byte
 	halt   bool
-	ticker *time.Ticker
-	mt     *sync.Mutex
-}
+	cycles uint32
 
-func (r *rtc) tick() {
-	r.mt.Lock()
-	defer r.mt.Unlock()
-	if !r.halt {
-		if r.t.s++; r.t.s >= 60 {
-			r.t.s = 0
-			r.t.m++
-		}
-		if r.t.m >= 60 {
-			r.t.m = 0
-			r.t.h++
-		}
-		if r.t.h >= 24 {
-			r.t.h = 0
-			r.t.d++
-		}
-	}
+	// now returns the current wall clock time. It is only used to advance
+	// the clock by the time the emulator was not running.
+	now func() time.Time
 }
 
 func newRealTimeClock() *rtc {
 	res := new(rtc)
-	res.mt = new(sync.Mutex)
-	res.t = new(rtcTime)
-	res.ticker = time.NewTicker(time.Second)
 	res.halt = true
-	go func() {
-		for _ = range res.ticker.C {
-			res.tick()
-		}
-	}()
-
+	res.now = time.Now
 	return res
 }
 
+// Step advances the clock by one m-cycle at normal speed.
+func (r *rtc) Step() {
+	if r.halt {
+		return
+	}
+	if r.cycles++; r.cycles >= rtcCyclesPerSecond {
+		r.cycles = 0
+		r.t.inc()
+	}
+}
+
 func (r *rtc) WriteLatch(val byte) {
 	if val == 0x01 && r.latch == 0x00 {
 		if r.lt == nil {
-			r.mt.Lock()
-			defer r.mt.Unlock()
 			r.lt = new(rtcTime)
-			*r.lt = *r.t
+			*r.lt = r.t
 		} else {
 			r.lt = nil
 		}
@@ -72,10 +119,7 @@ func (r *rtc) WriteLatch(val byte) {
 }
 
 func (r *rtc) Read(bank int) byte {
-	r.mt.Lock()
-	defer r.mt.Unlock()
-
-	t := *r.t
+	t := r.t
 	if r.lt != nil {
 		t = *r.lt
 	}
@@ -90,12 +134,12 @@ func (r *rtc) Read(bank int) byte {
 	case 0x0B:
 		return byte(t.d & 0xFF)
 	case 0x0C:
-		value := byte(t.d>>8) & 0x01
+		value := byte(t.d>>8) & rtcDHDay
 		if r.halt {
-			value |= 0x40
+			value |= rtcDHHalt
 		}
-		if t.d>>9 != 0x00 {
-			value |= 0x80
+		if t.carry {
+			value |= rtcDHCarry
 		}
 		return value
 	}
@@ -103,84 +147,143 @@ func (r *rtc) Read(bank int) byte {
 }
 
 func (r *rtc) Write(bank int, value byte) {
-	r.mt.Lock()
-	defer r.mt.Unlock()
-
 	switch bank {
 	case 0x08:
-		r.t.s = value
+		r.t.s = value & 0x3F
+		// writing the seconds resets the sub-second counter
+		r.cycles = 0
 	case 0x09:
-		r.t.m = value
+		r.t.m = value & 0x3F
 	case 0x0A:
-		r.t.h = value
+		r.t.h = value & 0x1F
 	case 0x0B:
-		r.t.d = (r.t.d & 0xFF00) | uint16(value)
+		r.t.d = (r.t.d & 0x100) | uint16(value)
 	case 0x0C:
-		r.halt = value&0x40 == 0x40
-		if value&0x80 == 0x00 {
-			r.t.d = r.t.d & 0x01FF
+		r.halt = value&rtcDHHalt != 0
+		r.t.carry = value&rtcDHCarry != 0
+		r.t.d = (r.t.d & 0xFF) | uint16(value&rtcDHDay)<<8
+	}
+}
+
+// registers returns the values of the clock registers for the given time.
+func (r *rtc) registers(t *rtcTime) [5]byte {
+	dh := byte(t.d>>8) & rtcDHDay
+	if r.halt {
+		dh |= rtcDHHalt
+	}
+	if t.carry {
+		dh |= rtcDHCarry
+	}
+	return [5]byte{t.s, t.m, t.h, byte(t.d), dh}
+}
+
+// Dump writes the clock in the format used by VBA-M and BGB: the current and the
+// latched registers as 32bit values followed by a 64bit unix timestamp.
+func (r *rtc) Dump(w io.Writer) {
+	var buf [rtcFooterSize]byte
+	lt := &r.t
+	if r.lt != nil {
+		lt = r.lt
+	}
+	for i, v := range r.registers(&r.t) {
+		binary.LittleEndian.PutUint32(buf[i*4:], uint32(v))
+	}
+	for i, v := range r.registers(lt) {
+		binary.LittleEndian.PutUint32(buf[20+i*4:], uint32(v))
+	}
+	binary.LittleEndian.PutUint64(buf[40:], uint64(r.now().Unix()))
+	w.Write(buf[:])
+}
+
+// Load restores the clock from the data stored behind the cartridge ram. The
+// format is detected by the size of the data. Besides the VBA-M / BGB format with
+// 64bit or 32bit timestamps, the format of older goboy2 versions is supported.
+func (r *rtc) Load(data []byte) {
+	var last int64
+	switch len(data) {
+	case rtcFooterSize:
+		last = int64(binary.LittleEndian.Uint64(data[40:]))
+	case rtcFooterSize - 4:
+		last = int64(binary.LittleEndian.Uint32(data[40:]))
+	case legacyRTCSize:
+		last = int64(binary.BigEndian.Uint64(data))
+		d := binary.LittleEndian.Uint16(data[11:])
+		r.t = rtcTime{
+			s:     data[8] & 0x3F,
+			m:     data[9] & 0x3F,
+			h:     data[10] & 0x1F,
+			d:     d % rtcMaxDays,
+			carry: d >= rtcMaxDays,
 		}
-		if value&0x01 == 0x01 {
-			r.t.d |= 0x0100
-		} else {
-			r.t.d &= 0xFEFF
+		r.halt = data[13] == 0x01
+	default:
+		if len(data) > 0 {
+			log.Printf("unknown rtc data with %d bytes is ignored", len(data))
+		}
+		return
+	}
+	if len(data) != legacyRTCSize {
+		var regs [5]byte
+		for i := range regs {
+			regs[i] = byte(binary.LittleEndian.Uint32(data[i*4:]))
+		}
+		r.t = rtcTime{
+			s:     regs[0] & 0x3F,
+			m:     regs[1] & 0x3F,
+			h:     regs[2] & 0x1F,
+			d:     uint16(regs[3]) | uint16(regs[4]&rtcDHDay)<<8,
+			carry: regs[4]&rtcDHCarry != 0,
+		}
+		r.halt = regs[4]&rtcDHHalt != 0
+	}
+	r.lt = nil
+	r.cycles = 0
+
+	if !r.halt {
+		// the clock kept running while the emulator was closed.
+		if secs := r.now().Unix() - last; secs > 0 {
+			r.t.add(secs)
 		}
 	}
 }
 
-func (r *rtc) Dump(w io.Writer) {
-	r.mt.Lock()
-	defer r.mt.Unlock()
-	dt := time.Now().Unix()
-	w.Write([]byte{
-		byte(dt >> 56),
-		byte(dt >> 48),
-		byte(dt >> 40),
-		byte(dt >> 32),
-		byte(dt >> 24),
-		byte(dt >> 16),
-		byte(dt >> 8),
-		byte(dt >> 0),
-	})
-
-	w.Write([]byte{
-		r.t.s, r.t.m, r.t.h,
-		byte(r.t.d & 0xFF), byte(r.t.d >> 8),
-	})
-	if r.halt {
-		w.Write([]byte{0x01})
+func (t *rtcTime) saveState(w *savestate.Writer) {
+	w.Bytes([]byte{t.s, t.m, t.h})
+	w.U16(t.d)
+	w.Bool(t.carry)
+}
+
+func (t *rtcTime) loadState(r *savestate.Reader) {
+	var hms [3]byte
+	r.Bytes(hms[:])
+	t.s, t.m, t.h = hms[0]&0x3F, hms[1]&0x3F, hms[2]&0x1F
+	t.d = r.U16() % rtcMaxDays
+	t.carry = r.Bool()
+}
+
+func (r *rtc) SaveState(w *savestate.Writer) {
+	r.t.saveState(w)
+	w.Bool(r.lt != nil)
+	if r.lt != nil {
+		r.lt.saveState(w)
+	}
+	w.U8(r.latch)
+	w.Bool(r.halt)
+	w.U32(r.cycles)
+}
+
+func (r *rtc) LoadState(rd *savestate.Reader) {
+	r.t.loadState(rd)
+	if rd.Bool() {
+		r.lt = new(rtcTime)
+		r.lt.loadState(rd)
 	} else {
-		w.Write([]byte{0x00})
-	}
-}
-
-func (rtc *rtc) Load(r io.Reader) {
-	rtc.mt.Lock()
-	defer rtc.mt.Unlock()
-	dtBuf := make([]byte, 8)
-	r.Read(dtBuf)
-	dtUnix := int64(0)
-	for _, v := range dtBuf {
-		dtUnix = dtUnix << 8
-		dtUnix = dtUnix | int64(v)
-	}
-	lastTickDat := time.Unix(dtUnix, 0)
-	valBuf := make([]byte, 6)
-	r.Read(valBuf)
-	rtc.t.s = valBuf[0]
-	rtc.t.m = valBuf[1]
-	rtc.t.h = valBuf[2]
-	rtc.t.d = uint16(valBuf[3]) | (uint16(valBuf[4]) << 8)
-
-	rtc.halt = valBuf[5] == 0x01
-	if !rtc.halt {
-		dtSec := int64(time.Now().Sub(lastTickDat).Seconds())
-		time := dtSec + int64(rtc.t.s)
-		rtc.t.s = byte(time % 60)
-		time = (time / 60) + int64(rtc.t.m)
-		rtc.t.m = byte(time % 60)
-		time = (time / 60) + int64(rtc.t.h)
-		rtc.t.h = byte(time % 24)
-		rtc.t.d = uint16((time / 24) + int64(rtc.t.d))
+		r.lt = nil
+	}
+	r.latch = rd.U8()
+	r.halt = rd.Bool()
+	if r.cycles = rd.U32(); r.cycles >= rtcCyclesPerSecond {
+		rd.Failf("savestate: invalid rtc state")
+		r.cycles = 0
 	}
 }
@@ -0,0 +1,170 @@
+package cartridge
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func stepSeconds(r *rtc, secs int) {
+	for i := 0; i < secs*rtcCyclesPerSecond; i++ {
+		r.Step()
+	}
+}
+
+func TestRTCCycles(t *testing.T) {
+	r := newRealTimeClock()
+	r.Write(0x0C, 0x00) // start the clock
+
+	for i := 0; i < rtcCyclesPerSecond-1; i++ {
+		r.Step()
+	}
+	if s := r.Read(0x08); s != 0 {
+		t.Errorf("Expected 0 seconds before the second is complete but got %d", s)
+	}
+	r.Step()
+	if s := r.Read(0x08); s != 1 {
+		t.Errorf("Expected 1 second but got %d", s)
+	}
+
+	r.Write(0x0C, 0x40) // halt
+	stepSeconds(r, 2)
+	if s := r.Read(0x08); s != 1 {
+		t.Errorf("Expected halted clock to stay at 1 second but got %d", s)
+	}
+}
+
+func TestRTCSecondsWriteResetsSubSecond(t *testing.T) {
+	r := newRealTimeClock()
+	r.Write(0x0C, 0x00)
+
+	for i := 0; i < rtcCyclesPerSecond/2; i++ {
+		r.Step()
+	}
+	r.Write(0x08, 10)
+	for i := 0; i < rtcCyclesPerSecond-1; i++ {
+		r.Step()
+	}
+	if s := r.Read(0x08); s != 10 {
+		t.Errorf("Expected 10 seconds but got %d", s)
+	}
+	r.Step()
+	if s := r.Read(0x08); s != 11 {
+		t.Errorf("Expected 11 seconds but got %d", s)
+	}
+}
+
+func TestRTCDayCarry(t *testing.T) {
+	r := newRealTimeClock()
+	r.Write(0x08, 59)
+	r.Write(0x09, 59)
+	r.Write(0x0A, 23)
+	r.Write(0x0B, 0xFF)
+	r.Write(0x0C, 0x01) // day 511, running
+	stepSeconds(r, 1)
+
+	for bank, exp := range map[int]byte{0x08: 0, 0x09: 0, 0x0A: 0, 0x0B: 0, 0x0C: 0x80} {
+		if v := r.Read(bank); v != exp {
+			t.Errorf("Expected %02X in register %02X but got %02X", exp, bank, v)
+		}
+	}
+
+	stepSeconds(r, 1)
+	if v := r.Read(0x0C); v != 0x80 {
+		t.Errorf("Expected the carry to stay set but got %02X", v)
+	}
+	r.Write(0x0C, 0x00)
+	if v := r.Read(0x0C); v != 0x00 {
+		t.Errorf("Expected the carry to be cleared but got %02X", v)
+	}
+}
+
+func TestRTCInvalidValues(t *testing.T) {
+	r := newRealTimeClock()
+	r.Write(0x08, 63)
+	r.Write(0x09, 5)
+	r.Write(0x0C, 0x00)
+	stepSeconds(r, 1)
+	if s, m := r.Read(0x08), r.Read(0x09); s != 0 || m != 5 {
+		t.Errorf("Expected 63 seconds to overflow without carry but got %d:%d", m, s)
+	}
+}
+
+func TestRTCLatch(t *testing.T) {
+	r := newRealTimeClock()
+	r.Write(0x0C, 0x00)
+	r.WriteLatch(0x00)
+	r.WriteLatch(0x01)
+	stepSeconds(r, 1)
+	if s := r.Read(0x08); s != 0 {
+		t.Errorf("Expected latched value 0 but got %d", s)
+	}
+	r.WriteLatch(0x00)
+	r.WriteLatch(0x01)
+	if s := r.Read(0x08); s != 1 {
+		t.Errorf("Expected 1 second after unlatching but got %d", s)
+	}
+}
+
+func TestRTCLoadAdvancesTime(t *testing.T) {
+	now := time.Date(2020, 4, 18, 12, 0, 0, 0, time.UTC)
+
+	r := newRealTimeClock()
+	r.now = func() time.Time { return now }
+	r.Write(0x0A, 22)
+	r.Write(0x0B, 0xFF)
+	r.Write(0x0C, 0x01)
+	buf := new(bytes.Buffer)
+	r.Dump(buf)
+
+	now = now.Add(2*time.Hour + 30*time.Second)
+	r2 := newRealTimeClock()
+	r2.now = func() time.Time { return now }
+	r2.Load(buf.Bytes())
+
+	for bank, exp := range map[int]byte{0x08: 30, 0x09: 0, 0x0A: 0, 0x0B: 0, 0x0C: 0x80} {
+		if v := r2.Read(bank); v != exp {
+			t.Errorf("Expected %02X in register %02X but got %02X", exp, bank, v)
+		}
+	}
+}
+
+func TestRTCLoadFormats(t *testing.T) {
+	now := time.Unix(1587211200, 0)
+	testCases := []struct {
+		Name string
+		Data []byte
+	}{
+		{"VBA-M 64bit", []byte{
+			10, 0, 0, 0, 20, 0, 0, 0, 5, 0, 0, 0, 0x2C, 0, 0, 0, 0x41, 0, 0, 0,
+			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			0xC0, 0xeb, 0x9a, 0x5e, 0, 0, 0, 0,
+		}},
+		{"VBA-M 32bit", []byte{
+			10, 0, 0, 0, 20, 0, 0, 0, 5, 0, 0, 0, 0x2C, 0, 0, 0, 0x41, 0, 0, 0,
+			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			0xC0, 0xeb, 0x9a, 0x5e,
+		}},
+		{"legacy", []byte{
+			0, 0, 0, 0, 0x5e, 0x9a, 0xeb, 0xC0,
+			10, 20, 5, 0x2C, 0x01, 0x01,
+		}},
+	}
+
+	for _, tc := range testCases {
+		r := newRealTimeClock()
+		r.now = func() time.Time { return now }
+		r.Load(tc.Data)
+		for bank, exp := range map[int]byte{0x08: 10, 0x09: 20, 0x0A: 5, 0x0B: 0x2C, 0x0C: 0x41} {
+			if v := r.Read(bank); v != exp {
+				t.Errorf("%s: Expected %02X in register %02X but got %02X", tc.Name, exp, bank, v)
+			}
+		}
+
+		buf := new(bytes.Buffer)
+		r.Dump(buf)
+		if buf.Len() != rtcFooterSize {
+			t.Errorf("%s: Expected %d bytes of rtc data but got %d", tc.Name, rtcFooterSize, buf.Len())
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package cartridge
+
+import (
+	"github.com/boombuler/goboy2/savestate"
+)
+
+// sachenHeaderReads is the number of reads from the header area until the
+// cartridge switches to the next lock stage.
+const sachenHeaderReads = 0x31
+
+// sachen is the mapper of the unlicensed Sachen cartridges. The header area of
+// these roms is stored with scrambled address lines. Until the boot rom has read
+// the logo, the reads of the header are redirected to a copy of the Nintendo logo.
+// Without a boot rom the cartridge starts unlocked.
+//
+// The MMC2 is used for GBC games. It has an additional first lock stage in which
+// the header reads are passed through.
+type sachen struct {
+	batteryRAM
+	rombanks []rombank
+
+	baseBank int
+	mask     int
+	bank     int
+	// stages is the number of remaining lock stages, reads counts the header
+	// reads of the current stage.
+	stages int
+	reads  int
+	mmc2   bool
+}
+
+func createSachen(c *Cartridge, data []byte, mmc2 bool) (MBC, error) {
+	m := new(sachen)
+	for i := 0; i+rombankSize <= len(data); i += rombankSize {
+		m.rombanks = append(m.rombanks, rombank(data[i:i+rombankSize]))
+	}
+	m.bank = 1
+	m.mmc2 = mmc2
+	m.stages = 1
+	if mmc2 {
+		m.stages = 2
+	}
+	return m, nil
+}
+
+// unscrambleSachen swaps the address lines A0 with A6 and A1 with A4.
+func unscrambleSachen(addr uint16) uint16 {
+	res := addr & 0xFFAC
+	res |= (addr & 0x40) >> 6
+	res |= (addr & 0x10) >> 3
+	res |= (addr & 0x02) << 3
+	res |= (addr & 0x01) << 6
+	return res
+}
+
+func (m *sachen) Read(addr uint16) byte {
+	if addr&0xFF00 == 0x0100 {
+		if m.stages == 1 {
+			// the last stage redirects the reads to the logo copy.
+			addr |= 0x80
+		}
+		addr = unscrambleSachen(addr)
+	}
+
+	switch {
+	case addr < rombankSize:
+		return m.rombanks[(m.baseBank&m.mask)%len(m.rombanks)].Read(addr)
+	case addr < 2*rombankSize:
+		bank := m.bank&^m.mask | m.baseBank&m.mask
+		return m.rombanks[bank%len(m.rombanks)].Read(addr)
+	}
+	return 0xFF
+}
+
+// CPURead counts the reads of the boot rom from the header area.
+func (m *sachen) CPURead(addr uint16) {
+	if addr&0xFF00 == 0x0100 && m.stages > 0 {
+		if m.reads++; m.reads == sachenHeaderReads {
+			m.stages--
+			m.reads = 0
+		}
+	}
+}
+
+// SkipBoot unlocks the cartridge, since no boot rom checks the logo.
+func (m *sachen) SkipBoot() {
+	m.stages = 0
+	m.reads = 0
+}
+
+func (m *sachen) ROMBank(addr uint16) int {
+	if addr < rombankSize {
+		return (m.baseBank & m.mask) % len(m.rombanks)
+	}
+	return (m.bank&^m.mask | m.baseBank&m.mask) % len(m.rombanks)
+}
+
+func (m *sachen) Write(addr uint16, value byte) {
+	// the base bank and the mask can only be changed while the bank bits 4 and 5 are set.
+	unlocked := m.bank&0x30 == 0x30
+	switch {
+	case addr < 0x2000:
+		if unlocked {
+			m.baseBank = int(value)
+		}
+	case addr < 0x4000:
+		if value == 0 {
+			value = 1
+		}
+		m.bank = int(value)
+	case addr < 0x6000:
+		if unlocked {
+			m.mask = int(value)
+		}
+	}
+}
+
+func (m *sachen) SaveState(w *savestate.Writer) {
+	w.Int(m.baseBank)
+	w.Int(m.mask)
+	w.Int(m.bank)
+	w.Int(m.stages)
+	w.Int(m.reads)
+}
+
+func (m *sachen) LoadState(r *savestate.Reader) {
+	m.baseBank = r.Int() & 0xFF
+	m.mask = r.Int() & 0xFF
+	m.bank = r.Int() & 0xFF
+	m.stages = r.Int()
+	m.reads = r.Int()
+	if m.stages < 0 || m.stages > 2 || m.reads < 0 || m.reads >= sachenHeaderReads {
+		r.Failf("savestate: invalid sachen state")
+		m.stages, m.reads = 0, 0
+	}
+}
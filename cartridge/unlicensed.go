@@ -0,0 +1,134 @@
+package cartridge
+
+import (
+	"bytes"
+	"sort"
+)
+
+// mbc1MulticartGameSize is the size of a single game on a MBC1 multicart.
+const mbc1MulticartGameSize = 16 * rombankSize
+
+// mappers contains the mappers which can be selected by name. They override the
+// cartridge type of the header.
+var mappers = map[string]mapperFactory{
+	"rom":    mbcFactories[0x09],
+	"mbc1":   mbcFactories[0x03],
+	"mbc2":   mbcFactories[0x06],
+	"mbc3":   mbcFactories[0x10],
+	"mbc5":   mbcFactories[0x1B],
+	"mbc7":   mbcFactories[0x22],
+	"mmm01":  mbcFactories[0x0D],
+	"huc1":   mbcFactories[0xFF],
+	"huc3":   mbcFactories[0xFE],
+	"camera": mbcFactories[0xFC],
+	"mbc1m": func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
+		bat, err := bf.open()
+		if err != nil {
+			return nil, err
+		}
+		return createMBC1Multicart(c, data, bat)
+	},
+	"wisdomtree": func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
+		return createWisdomTree(c, data)
+	},
+	"sachen1": func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
+		return createSachen(c, data, false)
+	},
+	"sachen2": func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
+		return createSachen(c, data, true)
+	},
+	"rocket": func(c *Cartridge, data []byte, bf BatteryFactory) (MBC, error) {
+		return createRocket(c, data)
+	},
+}
+
+// MapperNames returns the names of the mappers which can be passed to LoadMapper.
+func MapperNames() []string {
+	var res []string
+	for name := range mappers {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// detectMapper returns the name of the mapper of unlicensed cartridges, which can't
+// be detected by their header. It returns an empty string for all other cartridges.
+func detectMapper(rom []byte, h *Header) string {
+	switch {
+	case isWisdomTree(rom):
+		return "wisdomtree"
+	case !h.LogoValid && isSachen(rom):
+		if rom[unscrambleSachen(0x0143)]&0x80 != 0 {
+			return "sachen2"
+		}
+		return "sachen1"
+	case isMBC1Multicart(rom, h):
+		return "mbc1m"
+	case isRocket(rom, h):
+		return "rocket"
+	}
+	return ""
+}
+
+func isWisdomTree(rom []byte) bool {
+	title := rom[0x0134:0x0144]
+	return len(rom) > wisdomTreeBankSize &&
+		(bytes.HasPrefix(title, []byte("WISDOM TREE")) || bytes.HasPrefix(title, []byte("WISDOM\x00TREE")))
+}
+
+// isSachen checks if the rom contains the scrambled copy of the logo.
+func isSachen(rom []byte) bool {
+	for i, b := range nintendoLogo {
+		if rom[unscrambleSachen(uint16(logoStart+i)|0x80)] != b {
+			return false
+		}
+	}
+	return true
+}
+
+// isMBC1Multicart detects bootleg multicarts which are wired like a MBC1 multicart
+// but declare the header of the first game.
+func isMBC1Multicart(rom []byte, h *Header) bool {
+	switch h.CartridgeType {
+	case 0x00, 0x01, 0x02, 0x03:
+	default:
+		return false
+	}
+	if uint(len(rom)) <= h.ROMSize || len(rom) < 2*mbc1MulticartGameSize || len(rom)%mbc1MulticartGameSize != 0 {
+		return false
+	}
+	for offset := 0; offset < len(rom); offset += mbc1MulticartGameSize {
+		if !bytes.Equal(rom[offset+logoStart:offset+logoEnd], nintendoLogo) {
+			return false
+		}
+	}
+	return true
+}
+
+// isRocket detects the Rocket Games cartridges, which declare a 32KB rom without
+// a mapper but contain more banks. Overdumps, which repeat the first 32KB or are
+// padded, are not detected.
+func isRocket(rom []byte, h *Header) bool {
+	const size = 2 * rombankSize
+	if h.CartridgeType != 0x00 || h.ROMSizeCode != 0x00 || len(rom) < 2*size || len(rom)%size != 0 {
+		return false
+	}
+	for offset := size; offset < len(rom); offset += size {
+		chunk := rom[offset : offset+size]
+		if !bytes.Equal(chunk, rom[:size]) && !isPadding(chunk) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPadding checks if all bytes of data are 0x00 or all are 0xFF.
+func isPadding(data []byte) bool {
+	for _, b := range data {
+		if b != data[0] {
+			return false
+		}
+	}
+	return len(data) == 0 || data[0] == 0x00 || data[0] == 0xFF
+}
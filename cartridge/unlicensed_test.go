@@ -0,0 +1,112 @@
+package cartridge
+
+import "testing"
+
+func unlicensedROM(size int) []byte {
+	rom := make([]byte, size)
+	copy(rom[logoStart:], nintendoLogo)
+	rom[0x0148] = 0x00
+	return rom
+}
+
+func TestWisdomTree(t *testing.T) {
+	rom := unlicensedROM(4 * wisdomTreeBankSize)
+	copy(rom[0x0134:], "WISDOM TREE")
+	for i := 0; i < 4; i++ {
+		rom[i*wisdomTreeBankSize+0x4000] = byte(i)
+	}
+	h, _ := ParseHeader(rom)
+	if name := detectMapper(rom, h); name != "wisdomtree" {
+		t.Fatalf("Expected wisdomtree but got %q", name)
+	}
+	m, _ := createWisdomTree(nil, rom)
+	m.Write(0x0002, 0xFF)
+	if v := m.Read(0x4000); v != 2 {
+		t.Errorf("Expected bank 2 but got %d", v)
+	}
+}
+
+func TestSachenDetection(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	for i, b := range nintendoLogo {
+		rom[unscrambleSachen(uint16(logoStart+i)|0x80)] = b
+	}
+	h, _ := ParseHeader(rom)
+	if name := detectMapper(rom, h); name != "sachen1" {
+		t.Errorf("Expected sachen1 but got %q", name)
+	}
+	rom[unscrambleSachen(0x0143)] = 0x80
+	if name := detectMapper(rom, h); name != "sachen2" {
+		t.Errorf("Expected sachen2 but got %q", name)
+	}
+}
+
+func TestMBC1MulticartDetection(t *testing.T) {
+	rom := make([]byte, 4*mbc1MulticartGameSize)
+	for offset := 0; offset < len(rom); offset += mbc1MulticartGameSize {
+		copy(rom[offset+logoStart:], nintendoLogo)
+		rom[offset+0x0147] = 0x01
+		rom[offset+0x0148] = 0x04
+	}
+	h, _ := ParseHeader(rom)
+	if name := detectMapper(rom, h); name != "mbc1m" {
+		t.Errorf("Expected mbc1m but got %q", name)
+	}
+	rom = rom[:mbc1MulticartGameSize]
+	h, _ = ParseHeader(rom)
+	if name := detectMapper(rom, h); name != "" {
+		t.Errorf("Expected no mapper for a single game but got %q", name)
+	}
+}
+
+func TestRocketDetection(t *testing.T) {
+	rom := unlicensedROM(4 * rombankSize)
+	copy(rom[2*rombankSize:], rom[:2*rombankSize])
+	h, _ := ParseHeader(rom)
+	if name := detectMapper(rom, h); name != "" {
+		t.Errorf("Expected no mapper for a repeated rom but got %q", name)
+	}
+	for i := 2 * rombankSize; i < len(rom); i++ {
+		rom[i] = 0xFF
+	}
+	if name := detectMapper(rom, h); name != "" {
+		t.Errorf("Expected no mapper for a padded rom but got %q", name)
+	}
+	rom[3*rombankSize] = 3
+	if name := detectMapper(rom, h); name != "rocket" {
+		t.Fatalf("Expected rocket but got %q", name)
+	}
+	m, _ := createRocket(nil, rom)
+	m.Write(0x2000, 0x03)
+	if v := m.Read(0x4000); v != 3 {
+		t.Errorf("Expected bank 3 but got %d", v)
+	}
+}
+
+func TestSachenLock(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	for i, b := range nintendoLogo {
+		rom[unscrambleSachen(uint16(logoStart+i)|0x80)] = b
+	}
+	rom[unscrambleSachen(logoStart)] = 0x42
+	m, _ := createSachen(nil, rom, false)
+	// reading the rom without the cpu does not unlock the cartridge
+	for i := 0; i < 2*sachenHeaderReads; i++ {
+		m.Read(logoStart)
+	}
+	if v := m.Read(logoStart); v != nintendoLogo[0] {
+		t.Fatalf("Expected the logo copy but got %02X", v)
+	}
+	for i := 0; i < sachenHeaderReads; i++ {
+		m.(BusListener).CPURead(logoStart)
+	}
+	if v := m.Read(logoStart); v != 0x42 {
+		t.Errorf("Expected the cartridge to be unlocked but got %02X", v)
+	}
+
+	m, _ = createSachen(nil, rom, true)
+	m.(BootListener).SkipBoot()
+	if v := m.Read(logoStart); v != 0x42 {
+		t.Errorf("Expected the cartridge to start unlocked without boot rom but got %02X", v)
+	}
+}
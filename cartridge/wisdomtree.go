@@ -0,0 +1,47 @@
+package cartridge
+
+import (
+	"github.com/boombuler/goboy2/savestate"
+)
+
+const wisdomTreeBankSize = 2 * rombankSize
+
+// wisdomTree is the mapper of the unlicensed Wisdom Tree games. It switches the
+// complete 32KB of rom. The bank is selected by the lower byte of the address
+// written to in 0x0000-0x3FFF.
+type wisdomTree struct {
+	batteryRAM
+	rom  []byte
+	bank int
+}
+
+func createWisdomTree(c *Cartridge, data []byte) (MBC, error) {
+	return &wisdomTree{rom: data}, nil
+}
+
+func (m *wisdomTree) Read(addr uint16) byte {
+	if addr < wisdomTreeBankSize {
+		if offset := m.bank*wisdomTreeBankSize + int(addr); offset < len(m.rom) {
+			return m.rom[offset]
+		}
+	}
+	return 0xFF
+}
+
+func (m *wisdomTree) ROMBank(addr uint16) int {
+	return m.bank*2 + int(addr/rombankSize)
+}
+
+func (m *wisdomTree) Write(addr uint16, value byte) {
+	if addr < 0x4000 {
+		m.bank = int(addr & 0xFF)
+	}
+}
+
+func (m *wisdomTree) SaveState(w *savestate.Writer) {
+	w.Int(m.bank)
+}
+
+func (m *wisdomTree) LoadState(r *savestate.Reader) {
+	m.bank = r.Int() & 0xFF
+}
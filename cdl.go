@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/boombuler/goboy2/cdl"
+	"github.com/boombuler/goboy2/consts"
+	"github.com/boombuler/goboy2/cpu"
+)
+
+// StartCDL logs the accesses of the cpu to the rom until StopCDL is called. It
+// must be called before Run.
+func (gb *GameBoy) StartCDL(l *cdl.Logger) {
+	gb.cdl = l
+	gb.MMU.AddWatcher(l)
+}
+
+// StopCDL stops the code/data log. It must not be called while Run executes.
+func (gb *GameBoy) StopCDL() {
+	if gb.cdl != nil {
+		gb.MMU.RemoveWatcher(gb.cdl)
+		gb.cdl = nil
+	}
+}
+
+func (gb *GameBoy) logInstruction(i *cpu.Instruction) {
+	// the boot rom hides the start of the cartridge rom.
+	gb.cdl.SetActive(gb.MMU.Peek(consts.AddrBootmodeFlag) != 0)
+	gb.cdl.Instruction(i.PC, i.Length())
+}
+
+// startCDLFile starts the code/data log requested by the command line flags.
+// An existing log is extended. The returned function writes the log and prints
+// the coverage of the rom banks.
+func startCDLFile(gb *GameBoy, romSize int) func() {
+	if *cdlFile == "" {
+		return func() {}
+	}
+	l := cdl.NewLogger(romSize, gb.cart.ROMBank)
+	if f, err := os.Open(*cdlFile); err == nil {
+		err = l.Merge(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("%s: %v", *cdlFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		log.Fatal(err)
+	}
+	gb.StartCDL(l)
+	return func() {
+		gb.StopCDL()
+		f, err := os.Create(*cdlFile)
+		if err == nil {
+			_, err = l.WriteTo(f)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			log.Println("could not write code/data log:", err)
+			return
+		}
+		fmt.Println("rom coverage of", *cdlFile)
+		l.WriteSummary(os.Stdout)
+	}
+}
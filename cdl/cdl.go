@@ -0,0 +1,180 @@
+// Package cdl logs how the bytes of the cartridge rom are used.
+//
+// A code/data log contains one byte for every byte of the rom, in the order of
+// the rom file. Each byte is a combination of the flags Opcode, Operand and
+// Data, bytes which were never accessed are 0. Logs of several runs can be
+// merged, to see which parts of the rom were covered by all of them.
+package cdl
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"text/tabwriter"
+)
+
+// Flag marks how a byte of the rom was accessed.
+type Flag byte
+
+const (
+	// Opcode marks the first byte of an executed instruction.
+	Opcode Flag = 1 << iota
+	// Operand marks the other bytes of an executed instruction.
+	Operand
+	// Data marks a byte which was read by an instruction.
+	Data
+)
+
+const bankSize = 0x4000
+
+// Logger records the accesses of the cpu to the rom. It implements mmu.Watcher.
+type Logger struct {
+	flags   []Flag
+	romBank func(addr uint16) int
+	active  bool
+	// pc and end are the bounds of the current instruction.
+	pc, end int
+}
+
+// NewLogger creates a logger for a rom with the given size. romBank returns
+// the rom bank which is mapped at an address in 0000-7FFF.
+func NewLogger(romSize int, romBank func(addr uint16) int) *Logger {
+	return &Logger{
+		flags:   make([]Flag, romSize),
+		romBank: romBank,
+		active:  true,
+	}
+}
+
+// SetActive enables or disables the logging, for example while the boot rom
+// is mapped.
+func (l *Logger) SetActive(active bool) {
+	l.active = active
+}
+
+// Instruction is called before the cpu fetches the instruction at pc with the
+// given length. The reads of these bytes are logged as opcode and operands.
+func (l *Logger) Instruction(pc uint16, length int) {
+	l.pc, l.end = int(pc), int(pc)+length
+}
+
+func (l *Logger) offset(addr uint16) int {
+	if addr >= 0x8000 {
+		return -1
+	}
+	offset := l.romBank(addr)*bankSize + int(addr%bankSize)
+	if offset >= len(l.flags) {
+		return -1
+	}
+	return offset
+}
+
+// WatchRead implements mmu.Watcher.
+func (l *Logger) WatchRead(addr uint16, value byte) {
+	if !l.active {
+		return
+	}
+	offset := l.offset(addr)
+	if offset < 0 {
+		return
+	}
+	switch a := int(addr); {
+	case a == l.pc:
+		l.flags[offset] |= Opcode
+	case a > l.pc && a < l.end:
+		l.flags[offset] |= Operand
+	default:
+		l.flags[offset] |= Data
+	}
+}
+
+// WatchWrite implements mmu.Watcher. Writes to the rom control the mapper and are not logged.
+func (l *Logger) WatchWrite(addr uint16, value byte) {}
+
+// Flags returns the flags of a byte of the rom file.
+func (l *Logger) Flags(offset int) Flag {
+	return l.flags[offset]
+}
+
+// Merge adds the flags of a log, which was written by WriteTo for the same rom.
+func (l *Logger) Merge(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(data) != len(l.flags) {
+		return fmt.Errorf("the code/data log has %d bytes, but the rom has %d", len(data), len(l.flags))
+	}
+	for i, f := range data {
+		l.flags[i] |= Flag(f)
+	}
+	return nil
+}
+
+// WriteTo writes the log.
+func (l *Logger) WriteTo(w io.Writer) (int64, error) {
+	data := make([]byte, len(l.flags))
+	for i, f := range l.flags {
+		data[i] = byte(f)
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// Coverage counts the bytes of a rom bank by their use. Bytes which were
+// executed and read as data are counted as code.
+type Coverage struct {
+	Bank      int
+	Code      int
+	Data      int
+	Untouched int
+}
+
+// Total returns the number of bytes of the bank.
+func (c Coverage) Total() int {
+	return c.Code + c.Data + c.Untouched
+}
+
+// Percent returns the percentage of bytes which were accessed.
+func (c Coverage) Percent() float64 {
+	if c.Total() == 0 {
+		return 0
+	}
+	return 100 * float64(c.Code+c.Data) / float64(c.Total())
+}
+
+// Coverage returns the coverage of every rom bank.
+func (l *Logger) Coverage() []Coverage {
+	var res []Coverage
+	for i, f := range l.flags {
+		bank := i / bankSize
+		if bank == len(res) {
+			res = append(res, Coverage{Bank: bank})
+		}
+		c := &res[bank]
+		switch {
+		case f&(Opcode|Operand) != 0:
+			c.Code++
+		case f&Data != 0:
+			c.Data++
+		default:
+			c.Untouched++
+		}
+	}
+	return res
+}
+
+// WriteSummary writes a table with the coverage of the rom banks.
+func (l *Logger) WriteSummary(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "bank\tcode\tdata\tuntouched\tcoverage\t")
+	var total Coverage
+	for _, c := range l.Coverage() {
+		fmt.Fprintf(tw, "%02X\t%d\t%d\t%d\t%.1f%%\t\n", c.Bank, c.Code, c.Data, c.Untouched, c.Percent())
+		total.Code += c.Code
+		total.Data += c.Data
+		total.Untouched += c.Untouched
+	}
+	fmt.Fprintf(tw, "total\t%d\t%d\t%d\t%.1f%%\t\n", total.Code, total.Data, total.Untouched, total.Percent())
+	return tw.Flush()
+}
@@ -0,0 +1,92 @@
+package cdl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	bank := 1
+	l := NewLogger(3*bankSize, func(addr uint16) int {
+		if addr < bankSize {
+			return 0
+		}
+		return bank
+	})
+
+	// LD A, ($4000)
+	l.Instruction(0x0150, 3)
+	l.WatchRead(0x0150, 0xFA)
+	l.WatchRead(0x0151, 0x00)
+	l.WatchRead(0x0152, 0x40)
+	l.WatchRead(0x4000, 0x12)
+	l.WatchRead(0xC000, 0x00)
+	bank = 2
+	// JP $4001
+	l.Instruction(0x0153, 3)
+	l.WatchRead(0x0153, 0xC3)
+	l.WatchRead(0x0154, 0x01)
+	l.WatchRead(0x0155, 0x40)
+	l.Instruction(0x4001, 1)
+	l.WatchRead(0x4001, 0x00)
+	l.SetActive(false)
+	l.Instruction(0x0000, 1)
+	l.WatchRead(0x0000, 0x31)
+
+	expected := map[int]Flag{
+		0x0150: Opcode, 0x0151: Operand, 0x0152: Operand, 0x4000: Data,
+		0x0153: Opcode, 0x0154: Operand, 0x0155: Operand, 0x8001: Opcode,
+	}
+	for i := 0; i < 3*bankSize; i++ {
+		if f := l.Flags(i); f != expected[i] {
+			t.Errorf("%05X: got %d, expected %d", i, f, expected[i])
+		}
+	}
+
+	cov := l.Coverage()
+	if len(cov) != 3 {
+		t.Fatalf("got %d banks, expected 3", len(cov))
+	}
+	if c := cov[0]; c.Code != 6 || c.Data != 0 || c.Untouched != bankSize-6 {
+		t.Errorf("bank 0: got %+v", c)
+	}
+	if c := cov[1]; c.Code != 0 || c.Data != 1 || c.Total() != bankSize {
+		t.Errorf("bank 1: got %+v", c)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := l.WriteSummary(buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 || strings.Join(strings.Fields(lines[4]), " ") != "total 7 1 49144 0.0%" {
+		t.Errorf("unexpected summary:\n%s", buf)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	romBank := func(addr uint16) int { return int(addr / bankSize) }
+	a := NewLogger(2*bankSize, romBank)
+	a.Instruction(0x0100, 1)
+	a.WatchRead(0x0100, 0x00)
+	a.WatchRead(0x4000, 0x00)
+
+	b := NewLogger(2*bankSize, romBank)
+	b.Instruction(0x4000, 1)
+	b.WatchRead(0x4000, 0x00)
+
+	buf := new(bytes.Buffer)
+	if _, err := a.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Merge(buf); err != nil {
+		t.Fatal(err)
+	}
+	if b.Flags(0x0100) != Opcode || b.Flags(0x4000) != Opcode|Data {
+		t.Errorf("got %d and %d", b.Flags(0x0100), b.Flags(0x4000))
+	}
+	if err := b.Merge(bytes.NewReader(make([]byte, bankSize))); err == nil {
+		t.Error("merged a log of another size")
+	}
+}
@@ -0,0 +1,216 @@
+// Package cheat applies Game Genie and GameShark codes.
+//
+// Game Genie codes are applied by wrapping the MBC of the cartridge and patching
+// the values read from the rom. GameShark codes are written to the ram once per
+// frame, which has to be triggered by calling WriteRAM at the start of the vblank.
+package cheat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/boombuler/goboy2/cartridge"
+	"github.com/boombuler/goboy2/consts"
+)
+
+// Extension is the file extension of cheat files.
+const Extension = ".cht"
+
+// Cheat is a named list of codes which are enabled together.
+type Cheat struct {
+	Name    string
+	Codes   []Code
+	Enabled bool
+}
+
+// Memory is the memory GameShark codes are written to.
+type Memory interface {
+	Read(addr uint16) byte
+	Write(addr uint16, value byte)
+}
+
+// active contains the codes of all enabled cheats.
+type active struct {
+	genie []Code
+	shark []Code
+}
+
+// Engine applies the codes of the enabled cheats. It can be enabled and disabled
+// from another goroutine than the one running the emulation.
+type Engine struct {
+	mu      sync.Mutex
+	cheats  []*Cheat
+	enabled bool
+	active  atomic.Value // *active
+}
+
+// New creates an enabled engine for the given cheats.
+func New(cheats []*Cheat) *Engine {
+	e := &Engine{cheats: cheats, enabled: true}
+	e.update()
+	return e
+}
+
+// Find returns the cheat file which is stored next to the rom and has the same
+// name as the rom. It returns an empty string if there is none.
+func Find(romFileName string) string {
+	fileName := romFileName[:len(romFileName)-len(filepath.Ext(romFileName))] + Extension
+	if fi, err := os.Stat(fileName); err == nil && !fi.IsDir() {
+		return fileName
+	}
+	return ""
+}
+
+// Load reads the cheats of a cheat file.
+func Load(fileName string) ([]*Cheat, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cheats, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	return cheats, nil
+}
+
+// Read parses a cheat file. Every line contains one or more codes, separated by
+// "+", followed by the name of the cheat. Cheats starting with "-" are disabled,
+// lines starting with "#" are ignored.
+//
+//	# infinite lives
+//	010238CD Lives
+//	-00A-17B-C49+01A-18B-E6E Level select
+func Read(r io.Reader) ([]*Cheat, error) {
+	var res []*Cheat
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		c := &Cheat{Enabled: true}
+		if strings.HasPrefix(line, "-") {
+			c.Enabled = false
+			line = line[1:]
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) == 2 {
+			c.Name = strings.TrimSpace(fields[1])
+		} else {
+			c.Name = fields[0]
+		}
+		for _, s := range strings.Split(fields[0], "+") {
+			code, err := Parse(s)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			c.Codes = append(c.Codes, code)
+		}
+		res = append(res, c)
+	}
+	return res, scanner.Err()
+}
+
+// update rebuilds the list of active codes. The caller must hold the lock.
+func (e *Engine) update() {
+	a := new(active)
+	if e.enabled {
+		for _, c := range e.cheats {
+			if !c.Enabled {
+				continue
+			}
+			for _, code := range c.Codes {
+				if code.Kind == GameGenie {
+					a.genie = append(a.genie, code)
+				} else {
+					a.shark = append(a.shark, code)
+				}
+			}
+		}
+	}
+	e.active.Store(a)
+}
+
+func (e *Engine) codes() *active {
+	return e.active.Load().(*active)
+}
+
+// Enabled returns true if the cheats are applied.
+func (e *Engine) Enabled() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enabled
+}
+
+// SetEnabled enables or disables all cheats.
+func (e *Engine) SetEnabled(enabled bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.enabled = enabled
+	e.update()
+}
+
+// Toggle enables or disables all cheats and returns the new state.
+func (e *Engine) Toggle() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.enabled = !e.enabled
+	e.update()
+	return e.enabled
+}
+
+// SetCheatEnabled enables or disables a single cheat.
+func (e *Engine) SetCheatEnabled(idx int, enabled bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.cheats[idx].Enabled = enabled
+	e.update()
+}
+
+// Wrap returns a MBC which applies the Game Genie codes to the rom of the given MBC.
+func (e *Engine) Wrap(m cartridge.MBC) cartridge.MBC {
+	return &genieMBC{MBC: m, engine: e}
+}
+
+// WriteRAM writes the values of the GameShark codes.
+func (e *Engine) WriteRAM(mem Memory) {
+	for _, code := range e.codes().shark {
+		if code.Bank >= 0x90 && code.Bank <= 0x97 && code.Address >= 0xD000 && code.Address < 0xE000 {
+			bank := mem.Read(consts.AddrSVBK)
+			mem.Write(consts.AddrSVBK, code.Bank&0x07)
+			mem.Write(code.Address, code.Value)
+			mem.Write(consts.AddrSVBK, bank)
+		} else {
+			mem.Write(code.Address, code.Value)
+		}
+	}
+}
+
+type genieMBC struct {
+	cartridge.MBC
+	engine *Engine
+}
+
+func (m *genieMBC) Unwrap() cartridge.MBC {
+	return m.MBC
+}
+
+func (m *genieMBC) Read(addr uint16) byte {
+	value := m.MBC.Read(addr)
+	if addr < 0x8000 {
+		for _, code := range m.engine.codes().genie {
+			if code.Address == addr && (!code.HasCompare || code.Compare == value) {
+				return code.Value
+			}
+		}
+	}
+	return value
+}
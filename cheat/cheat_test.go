@@ -0,0 +1,106 @@
+package cheat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/boombuler/goboy2/cartridge"
+	"github.com/boombuler/goboy2/savestate"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		code string
+		exp  Code
+	}{
+		{"00A-17B", Code{Kind: GameGenie, Address: 0x4A17}},
+		{"3EA-17B-C49", Code{Kind: GameGenie, Value: 0x3E, Address: 0x4A17, Compare: 0xC8, HasCompare: true}},
+		{"010238cd", Code{Kind: GameShark, Bank: 0x01, Value: 0x02, Address: 0xCD38}},
+	}
+	for _, test := range tests {
+		code, err := Parse(test.code)
+		if err != nil {
+			t.Errorf("%s: %v", test.code, err)
+		} else if code != test.exp {
+			t.Errorf("%s: expected %+v but got %+v", test.code, test.exp, code)
+		}
+	}
+	for _, code := range []string{"00A-17", "00A-17B-C4", "0102", "XX0238CD", "00A-177"} {
+		if _, err := Parse(code); err == nil {
+			t.Errorf("Expected %q to be invalid", code)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	cheats, err := Read(strings.NewReader("# comment\n\n010238CD Lives\n-00A-17B+010338CD Level select\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cheats) != 2 {
+		t.Fatalf("Expected 2 cheats but got %d", len(cheats))
+	}
+	if c := cheats[0]; c.Name != "Lives" || !c.Enabled || len(c.Codes) != 1 {
+		t.Errorf("Unexpected first cheat %+v", c)
+	}
+	if c := cheats[1]; c.Name != "Level select" || c.Enabled || len(c.Codes) != 2 {
+		t.Errorf("Unexpected second cheat %+v", c)
+	}
+	if _, err := Read(strings.NewReader("0102 invalid\n")); err == nil {
+		t.Errorf("Expected an error for an invalid code")
+	}
+}
+
+type rom []byte
+
+func (r rom) Read(addr uint16) byte          { return r[addr] }
+func (r rom) Write(addr uint16, value byte)  {}
+func (r rom) Flush() error                   { return nil }
+func (r rom) Shutdown() error                { return nil }
+func (r rom) SaveState(w *savestate.Writer)  {}
+func (r rom) LoadState(r2 *savestate.Reader) {}
+
+func TestGameGenie(t *testing.T) {
+	data := make(rom, 0x8000)
+	data[0x4A17] = 0xC8
+	data[0x4A18] = 0x11
+	withCompare, _ := Parse("3EA-17B-C49")
+	other, _ := Parse("3EA-18B-C49")
+	e := New([]*Cheat{{Codes: []Code{withCompare, other}, Enabled: true}})
+	m := e.Wrap(data)
+
+	if v := m.Read(0x4A17); v != 0x3E {
+		t.Errorf("Expected the patched value but got %02X", v)
+	}
+	if v := m.Read(0x4A18); v != 0x11 {
+		t.Errorf("Expected the compare byte to prevent the patch but got %02X", v)
+	}
+	e.Toggle()
+	if v := m.Read(0x4A17); v != 0xC8 {
+		t.Errorf("Expected the original value of a disabled cheat but got %02X", v)
+	}
+	c := &cartridge.Cartridge{MBC: m}
+	if _, ok := c.Mapper().(rom); !ok {
+		t.Errorf("Expected the cartridge to unwrap the cheat engine")
+	}
+}
+
+type memory map[uint16]byte
+
+func (m memory) Read(addr uint16) byte         { return m[addr] }
+func (m memory) Write(addr uint16, value byte) { m[addr] = value }
+
+func TestGameShark(t *testing.T) {
+	code, _ := Parse("010238CD")
+	e := New([]*Cheat{{Codes: []Code{code}, Enabled: false}})
+	mem := memory{}
+	e.WriteRAM(mem)
+	if _, ok := mem[0xCD38]; ok {
+		t.Errorf("Expected a disabled cheat not to write the ram")
+	}
+	e.SetCheatEnabled(0, true)
+	e.WriteRAM(mem)
+	if v := mem[0xCD38]; v != 0x02 {
+		t.Errorf("Expected 02 to be written but got %02X", v)
+	}
+}
@@ -0,0 +1,94 @@
+package cheat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Kind is the type of a cheat code.
+type Kind int
+
+const (
+	// GameGenie codes patch the value read from the rom.
+	GameGenie Kind = iota
+	// GameShark codes write a value to the ram once per frame.
+	GameShark
+)
+
+func (k Kind) String() string {
+	if k == GameShark {
+		return "GameShark"
+	}
+	return "Game Genie"
+}
+
+// Code is a single decoded cheat code.
+type Code struct {
+	Kind    Kind
+	Address uint16
+	Value   byte
+	// Compare is the value the rom must contain for a Game Genie code to be applied.
+	// It is only used if HasCompare is set.
+	Compare    byte
+	HasCompare bool
+	// Bank is the type byte of a GameShark code. 0x90-0x97 select the working ram
+	// bank of the Game Boy Color, all other values write to the selected bank.
+	Bank byte
+}
+
+// Parse decodes a Game Genie code ("ABC-DEF" or "ABC-DEF-GHI") or a GameShark
+// code ("01VVLLHH").
+func Parse(code string) (Code, error) {
+	s := strings.ToUpper(strings.TrimSpace(code))
+	if strings.ContainsRune(s, '-') {
+		return parseGameGenie(s)
+	}
+	if len(s) != 8 {
+		return Code{}, fmt.Errorf("cheat: invalid code %q", code)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return Code{}, fmt.Errorf("cheat: invalid code %q", code)
+	}
+	return Code{
+		Kind:    GameShark,
+		Bank:    byte(v >> 24),
+		Value:   byte(v >> 16),
+		Address: uint16(v>>8)&0xFF | uint16(v)<<8,
+	}, nil
+}
+
+// parseGameGenie decodes a Game Genie code. For a code ABC-DEF-GHI the digits
+// AB are the new value, FCDE is the address xored with 0xF000 and GI is the
+// compare value, xored with 0xBA and rotated left by two. H is not used.
+func parseGameGenie(code string) (Code, error) {
+	parts := strings.Split(code, "-")
+	if (len(parts) != 2 && len(parts) != 3) || len(parts[0]) != 3 || len(parts[1]) != 3 || (len(parts) == 3 && len(parts[2]) != 3) {
+		return Code{}, fmt.Errorf("cheat: invalid Game Genie code %q", code)
+	}
+	var d []byte
+	for _, p := range parts {
+		for _, r := range p {
+			v, err := strconv.ParseUint(string(r), 16, 8)
+			if err != nil {
+				return Code{}, fmt.Errorf("cheat: invalid Game Genie code %q", code)
+			}
+			d = append(d, byte(v))
+		}
+	}
+	res := Code{
+		Kind:    GameGenie,
+		Value:   d[0]<<4 | d[1],
+		Address: (uint16(d[5]^0xF)<<12 | uint16(d[2])<<8 | uint16(d[3])<<4 | uint16(d[4])),
+	}
+	if res.Address >= 0x8000 {
+		return Code{}, fmt.Errorf("cheat: Game Genie code %q does not patch the rom", code)
+	}
+	if len(d) == 9 {
+		cmp := d[6]<<4 | d[8]
+		res.Compare = (cmp>>2 | cmp<<6) ^ 0xBA
+		res.HasCompare = true
+	}
+	return res, nil
+}
@@ -0,0 +1,98 @@
+package cpu
+
+// maxCalls limits the depth of a CallStack, for programs which do not return.
+const maxCalls = 256
+
+// Call is a call or an interrupt which did not return yet.
+type Call struct {
+	// Caller is the address of the call instruction or the address the interrupt occurred at.
+	Caller     uint16
+	CallerBank int
+	// Target is the address of the called routine or of the interrupt handler.
+	Target uint16
+	// SP is the stack pointer after the return address was pushed.
+	SP        uint16
+	Interrupt bool
+}
+
+// CallStack reconstructs the calls of the program from the instructions and
+// interrupts passed by the hooks of the cpu. The zero value is an empty stack.
+type CallStack struct {
+	calls []Call
+
+	// OnCall is called after a call was added to the stack.
+	OnCall func(c *Call)
+	// OnReturn is called after a call was removed from the stack.
+	OnReturn func(c *Call)
+}
+
+// Calls returns the calls which did not return yet, the outermost call first.
+// The slice is only valid until the next instruction.
+func (s *CallStack) Calls() []Call {
+	return s.calls
+}
+
+// Instruction has to be called by the OnInstruction hook of the cpu.
+func (s *CallStack) Instruction(i *Instruction) {
+	sp := i.Registers.SP
+	// calls whose return address was removed from the stack without a RET.
+	s.unwind(sp)
+	if i.Extended || !condition(i.Opcode, i.Registers.F) {
+		return
+	}
+	switch op := i.Opcode; {
+	case op == 0xCD || op&0xE7 == 0xC4:
+		s.push(Call{
+			Caller: i.PC, CallerBank: i.Bank, SP: sp - 2,
+			Target: uint16(i.Operands[0]) | uint16(i.Operands[1])<<8,
+		})
+	case op&0xC7 == 0xC7:
+		s.push(Call{Caller: i.PC, CallerBank: i.Bank, SP: sp - 2, Target: uint16(op & 0x38)})
+	case op == 0xC9 || op == 0xD9 || op&0xE7 == 0xC0:
+		s.unwind(sp + 1)
+	}
+}
+
+// Interrupt has to be called by the OnInterrupt hook of the cpu.
+func (s *CallStack) Interrupt(i *Interrupt) {
+	s.unwind(i.SP + 2)
+	s.push(Call{Caller: i.Return, CallerBank: i.Bank, SP: i.SP, Target: i.Vector, Interrupt: true})
+}
+
+func (s *CallStack) push(c Call) {
+	if len(s.calls) == maxCalls {
+		return
+	}
+	s.calls = append(s.calls, c)
+	if s.OnCall != nil {
+		s.OnCall(&s.calls[len(s.calls)-1])
+	}
+}
+
+// unwind removes the calls whose return address is below sp.
+func (s *CallStack) unwind(sp uint16) {
+	for n := len(s.calls); n > 0 && sp > s.calls[n-1].SP; n-- {
+		c := s.calls[n-1]
+		s.calls = s.calls[:n-1]
+		if s.OnReturn != nil {
+			s.OnReturn(&c)
+		}
+	}
+}
+
+// condition checks if the condition of a conditional CALL or RET is met. It is
+// true for all other opcodes.
+func condition(op byte, f byte) bool {
+	if op&0xE7 != 0xC0 && op&0xE7 != 0xC4 {
+		return true
+	}
+	switch op >> 3 & 3 {
+	case 0:
+		return flag(f)&zero == 0
+	case 1:
+		return flag(f)&zero != 0
+	case 2:
+		return flag(f)&carry == 0
+	}
+	return flag(f)&carry != 0
+}
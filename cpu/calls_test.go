@@ -0,0 +1,60 @@
+package cpu_test
+
+import (
+	"testing"
+
+	"github.com/boombuler/goboy2/cpu"
+	"github.com/boombuler/goboy2/mmu"
+)
+
+func TestCallStack(t *testing.T) {
+	var s cpu.CallStack
+	calls, returns := 0, 0
+	s.OnCall = func(c *cpu.Call) { calls++ }
+	s.OnReturn = func(c *cpu.Call) { returns++ }
+	instruction := func(pc, sp uint16, f byte, code ...byte) {
+		i := cpu.Instruction{PC: pc, Opcode: code[0], Registers: cpu.Registers{PC: pc, SP: sp, F: f}}
+		i.OperandCount = copy(i.Operands[:], code[1:])
+		s.Instruction(&i)
+	}
+	expect := func(targets ...uint16) {
+		t.Helper()
+		got := s.Calls()
+		if len(got) != len(targets) {
+			t.Fatalf("got calls %+v, expected the targets %04X", got, targets)
+		}
+		for i, c := range got {
+			if c.Target != targets[i] {
+				t.Fatalf("got calls %+v, expected the targets %04X", got, targets)
+			}
+		}
+	}
+
+	instruction(0x0100, 0xFFFE, 0x80, 0xC4, 0x00, 0x02) // CALL NZ, 0200
+	expect()
+	instruction(0x0103, 0xFFFE, 0x80, 0xCC, 0x00, 0x02) // CALL Z, 0200
+	expect(0x0200)
+	if c := s.Calls()[0]; c.Caller != 0x0103 || c.SP != 0xFFFC || c.Interrupt {
+		t.Errorf("unexpected call %+v", c)
+	}
+	instruction(0x0200, 0xFFFC, 0x00, 0xFF) // RST 38
+	expect(0x0200, 0x0038)
+	instruction(0x0038, 0xFFFA, 0x00, 0xD8) // RET C
+	expect(0x0200, 0x0038)
+	s.Interrupt(&cpu.Interrupt{IRQ: mmu.IRQTimer, Vector: 0x0050, Return: 0x0039, SP: 0xFFF8})
+	expect(0x0200, 0x0038, 0x0050)
+	if c := s.Calls()[2]; c.Caller != 0x0039 || !c.Interrupt {
+		t.Errorf("unexpected interrupt %+v", c)
+	}
+	instruction(0x0050, 0xFFF8, 0x00, 0xD9) // RETI
+	expect(0x0200, 0x0038)
+	instruction(0x0039, 0xFFFA, 0x10, 0xD8) // RET C
+	expect(0x0200)
+	// the routine drops its return address.
+	instruction(0x0201, 0xFFFC, 0x00, 0xE1) // POP HL
+	instruction(0x0202, 0xFFFE, 0x00, 0xE9) // JP (HL)
+	expect()
+	if calls != 3 || returns != 3 {
+		t.Errorf("got %d calls and %d returns", calls, returns)
+	}
+}
@@ -1,8 +1,6 @@
 package cpu
 
 import (
-	"fmt"
-
 	"github.com/boombuler/goboy2/consts"
 	"github.com/boombuler/goboy2/mmu"
 )
@@ -52,9 +50,17 @@ type CPU struct {
 	opCodeState *ocState
 	rootOC      opCode
 
-	OnExecOpCode func(opCode string)
-
-	Dump bool
+	cycles      uint64
+	instruction Instruction
+	interrupt   Interrupt
+	halt        Halt
+
+	// OnInstruction is called before the cpu fetches the next instruction.
+	OnInstruction func(i *Instruction)
+	// OnInterrupt is called after the cpu jumped to an interrupt handler.
+	OnInterrupt func(i *Interrupt)
+	// OnHalt is called after a HALT or STOP instruction was executed.
+	OnHalt func(h *Halt)
 }
 
 // New returns a new cpu connected with the given mmu
@@ -83,6 +89,17 @@ func (c *CPU) Init(noBoot bool) {
 	}
 }
 
+// AtInstruction returns true if the next call of Step starts the instruction at
+// the program counter or dispatches an interrupt.
+func (cpu *CPU) AtInstruction() bool {
+	return cpu.curOpCode == nil && !cpu.haltEnabled
+}
+
+// IME returns true if the interrupts are enabled.
+func (cpu *CPU) IME() bool {
+	return cpu.ime
+}
+
 // DoubleSpeed checks if the cpu is running in double speed mode.
 func (cpu *CPU) DoubleSpeed() bool {
 	return cpu.key1 != nil && cpu.key1.dblSpeed
@@ -101,16 +118,7 @@ func (cpu *CPU) hasFlag(f flag) bool {
 }
 
 func (cpu *CPU) nextOpCode(oc opCode, state *ocState) opCode {
-	oc = oc.Next(state)
-	if info, ok := oc.(labeledOpCode); ok {
-		if cpu.Dump {
-			fmt.Printf("%-15s PC: 0x%04X  SP: 0x%04X  A: 0x%02X  B: 0x%02X  C: 0x%02X  D: 0x%02X  E: 0x%02X  H: 0x%02X  L: 0x%02X  %v\n", info.Label(), cpu.pc, cpu.sp, cpu.a, cpu.b, cpu.c, cpu.d, cpu.e, cpu.h, cpu.l, cpu.f)
-		}
-		if fn := cpu.OnExecOpCode; fn != nil {
-			fn(info.Label())
-		}
-	}
-	return oc
+	return oc.Next(state)
 }
 
 func (cpu *CPU) execInstantCodes(oc opCode) opCode {
@@ -136,6 +144,7 @@ func (cpu *CPU) setOPCode(oc opCode) {
 
 // Step Executes the next cpu step
 func (cpu *CPU) Step() {
+	cpu.cycles++
 	if cpu.curOpCode != nil {
 		cpu.stepOpCode()
 		return
@@ -146,6 +155,9 @@ func (cpu *CPU) Step() {
 			return
 		}
 
+		if cpu.OnInstruction != nil {
+			cpu.instructionStarted()
+		}
 		cpu.opCodeState.clear()
 
 		scheduled := cpu.imeScheduled
@@ -157,7 +169,7 @@ func (cpu *CPU) Step() {
 		}
 
 	} else {
-		curIRQFlags := mmu.IRQ(cpu.mmu.Read(consts.AddrIRQEnabled)) & mmu.IRQ(cpu.mmu.Read(consts.AddrIRQFlags))
+		curIRQFlags := mmu.IRQ(cpu.mmu.Peek(consts.AddrIRQEnabled)) & mmu.IRQ(cpu.mmu.Peek(consts.AddrIRQFlags))
 		if (curIRQFlags & mmu.IRQAll) != mmu.IRQNone {
 			cpu.haltEnabled = false
 		}
@@ -166,7 +178,7 @@ func (cpu *CPU) Step() {
 
 func (cpu *CPU) handleInterrupts() bool {
 	if cpu.ime {
-		curIRQFlags := mmu.IRQ(cpu.mmu.Read(consts.AddrIRQEnabled)) & mmu.IRQ(cpu.mmu.Read(consts.AddrIRQFlags))
+		curIRQFlags := mmu.IRQ(cpu.mmu.Peek(consts.AddrIRQEnabled)) & mmu.IRQ(cpu.mmu.Peek(consts.AddrIRQFlags))
 		if (curIRQFlags & mmu.IRQAll) != mmu.IRQNone {
 			cpu.setOPCode(irqHandlerOpCode)
 			return true
@@ -0,0 +1,72 @@
+// Package cputest runs small test programs on a cpu and its memory.
+package cputest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/boombuler/goboy2/cartridge"
+	"github.com/boombuler/goboy2/consts"
+	"github.com/boombuler/goboy2/cpu"
+	"github.com/boombuler/goboy2/mmu"
+)
+
+// Machine is a DMG without ppu and apu.
+type Machine struct {
+	CPU  *cpu.CPU
+	MMU  mmu.MMU
+	Cart *cartridge.Cartridge
+	ROM  []byte
+}
+
+// New creates a machine with a 32KB rom which contains the code of program at
+// the given addresses. The cpu starts at 0x0100.
+func New(t testing.TB, program map[uint16][]byte) *Machine {
+	rom := make([]byte, 0x8000)
+	for addr, code := range program {
+		copy(rom[addr:], code)
+	}
+	cart, err := cartridge.Load(bytes.NewReader(rom), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem := mmu.New(consts.DMG)
+	mem.LoadCartridge(cart)
+	c := cpu.New(mem)
+	mem.Init(true)
+	c.Init(true)
+	return &Machine{c, mem, cart, rom}
+}
+
+// Step emulates a single m-cycle.
+func (m *Machine) Step() {
+	m.CPU.Step()
+	m.MMU.Step()
+}
+
+// Run runs the machine on a new goroutine and calls instruction before every
+// instruction. The returned function closes exit and waits until the machine
+// has stopped.
+func (m *Machine) Run(exit chan struct{}, instruction func()) func() {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if m.CPU.AtInstruction() {
+				instruction()
+				select {
+				case _, _ = <-exit:
+					return
+				default:
+				}
+			}
+			m.Step()
+		}
+	}()
+	// the state of the opcodes is shared by all cpus, so the cpu has to be
+	// stopped at an instruction before the next test starts.
+	return func() {
+		close(exit)
+		<-done
+	}
+}
@@ -0,0 +1,117 @@
+package cpu
+
+import "github.com/boombuler/goboy2/mmu"
+
+// Registers contains the values of the registers.
+type Registers struct {
+	PC, SP                 uint16
+	A, B, C, D, E, F, H, L byte
+}
+
+// Registers returns the current register values.
+func (cpu *CPU) Registers() Registers {
+	return Registers{
+		PC: cpu.pc, SP: cpu.sp,
+		A: cpu.a, B: cpu.b, C: cpu.c, D: cpu.d, E: cpu.e, F: byte(cpu.f), H: cpu.h, L: cpu.l,
+	}
+}
+
+// Cycles returns the number of m-cycles the cpu executed since it was created.
+func (cpu *CPU) Cycles() uint64 {
+	return cpu.cycles
+}
+
+// Instruction describes an instruction before the cpu executes it.
+type Instruction struct {
+	// Opcode is the first byte of the instruction, or the byte after the
+	// prefix 0xCB for extended instructions.
+	Opcode   byte
+	Extended bool
+	PC       uint16
+	// Bank is the rom bank which is mapped at PC, or -1 if PC is not in the cartridge rom.
+	Bank int
+	// Operands contains the OperandCount bytes following the opcode.
+	Operands     [2]byte
+	OperandCount int
+	// Cycle is the number of m-cycles the cpu executed before the instruction.
+	Cycle     uint64
+	Registers Registers
+}
+
+// Label returns the label of the instruction, like "LD B, B".
+func (i *Instruction) Label() string {
+	if i.Extended {
+		return ExtendedLabel(i.Opcode)
+	}
+	return Label(i.Opcode)
+}
+
+// Length returns the number of bytes of the instruction.
+func (i *Instruction) Length() int {
+	if i.Extended {
+		return 2
+	}
+	return 1 + i.OperandCount
+}
+
+// Interrupt describes the dispatch of an interrupt.
+type Interrupt struct {
+	IRQ mmu.IRQ
+	// Vector is the address of the interrupt handler.
+	Vector uint16
+	// Return is the address of the interrupted instruction.
+	Return uint16
+	// Bank is the rom bank which is mapped at Return, or -1 if Return is not in the cartridge rom.
+	Bank int
+	// SP is the stack pointer after the return address was pushed.
+	SP uint16
+	// Cycle is the number of m-cycles the cpu executed so far.
+	Cycle uint64
+}
+
+// Halt describes a HALT or STOP instruction after it was executed.
+type Halt struct {
+	// Stop is true for STOP and false for HALT.
+	Stop bool
+	// SpeedSwitch is true if STOP switched the speed of the gameboy color.
+	SpeedSwitch bool
+	// Bug is true if HALT did not halt the cpu because of the halt bug.
+	Bug bool
+	PC  uint16
+	// Cycle is the number of m-cycles the cpu executed so far.
+	Cycle uint64
+}
+
+// instructionStarted fills the instruction passed to OnInstruction.
+func (cpu *CPU) instructionStarted() {
+	i := &cpu.instruction
+	pc := cpu.pc
+	i.PC, i.Cycle, i.Registers = pc, cpu.cycles, cpu.Registers()
+	i.Bank = cpu.mmu.ROMBank(pc)
+	i.Opcode = cpu.mmu.Peek(pc)
+	i.Extended = i.Opcode == prefixCB
+	n := Length(i.Opcode) - 1
+	if i.Extended {
+		pc++
+		i.Opcode = cpu.mmu.Peek(pc)
+		n = 0
+	}
+	i.OperandCount = n
+	for j := 0; j < n; j++ {
+		i.Operands[j] = cpu.mmu.Peek(pc + 1 + uint16(j))
+	}
+	cpu.OnInstruction(i)
+}
+
+func (cpu *CPU) interruptDispatched(irq mmu.IRQ, ret uint16) {
+	cpu.interrupt = Interrupt{
+		IRQ: irq, Vector: cpu.pc, Return: ret, Bank: cpu.mmu.ROMBank(ret), SP: cpu.sp, Cycle: cpu.cycles,
+	}
+	cpu.OnInterrupt(&cpu.interrupt)
+}
+
+func (cpu *CPU) halted(stop, speedSwitch, bug bool) {
+	// the pc already points to the next instruction.
+	cpu.halt = Halt{Stop: stop, SpeedSwitch: speedSwitch, Bug: bug, PC: cpu.pc - 1, Cycle: cpu.cycles}
+	cpu.OnHalt(&cpu.halt)
+}
@@ -0,0 +1,92 @@
+package cpu_test
+
+import (
+	"testing"
+
+	"github.com/boombuler/goboy2/consts"
+	"github.com/boombuler/goboy2/cpu"
+	"github.com/boombuler/goboy2/cpu/cputest"
+	"github.com/boombuler/goboy2/mmu"
+)
+
+// runUntil steps the machine until cond is true.
+func runUntil(t *testing.T, m *cputest.Machine, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		m.Step()
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("condition not reached, PC %04X", m.CPU.Registers().PC)
+}
+
+func TestHooks(t *testing.T) {
+	m := cputest.New(t, map[uint16][]byte{
+		0x0040: {0xD9}, // RETI
+		0x0100: {
+			0x3E, 0x05, // LD A, 5
+			0xCB, 0x37, // SWAP A
+			0x76,       // HALT
+			0xFB,       // EI
+			0x00,       // NOP
+			0x00,       // NOP
+			0x18, 0xFE, // JR 0108
+		},
+	})
+	m.MMU.Write(consts.AddrIRQEnabled, 0x00)
+
+	var instructions []cpu.Instruction
+	var halts []cpu.Halt
+	var interrupts []cpu.Interrupt
+	m.CPU.OnInstruction = func(i *cpu.Instruction) { instructions = append(instructions, *i) }
+	m.CPU.OnHalt = func(h *cpu.Halt) { halts = append(halts, *h) }
+	m.CPU.OnInterrupt = func(i *cpu.Interrupt) { interrupts = append(interrupts, *i) }
+
+	runUntil(t, m, func() bool { return len(halts) > 0 })
+	if len(instructions) != 3 {
+		t.Fatalf("got %d instructions before the halt, expected 3", len(instructions))
+	}
+	ld, swap := instructions[0], instructions[1]
+	if ld.PC != 0x0100 || ld.Opcode != 0x3E || ld.Extended || ld.OperandCount != 1 || ld.Operands[0] != 0x05 ||
+		ld.Bank != 0 || ld.Registers.PC != 0x0100 || ld.Label() != "LD A, n" {
+		t.Errorf("unexpected LD: %+v", ld)
+	}
+	if swap.PC != 0x0102 || swap.Opcode != 0x37 || !swap.Extended || swap.OperandCount != 0 ||
+		swap.Registers.A != 0x05 || swap.Cycle <= ld.Cycle || swap.Label() != "SWAP A" {
+		t.Errorf("unexpected SWAP: %+v", swap)
+	}
+	if h := halts[0]; h.PC != 0x0104 || h.Stop || h.Bug {
+		t.Errorf("unexpected halt: %+v", h)
+	}
+
+	// the vblank interrupt is requested by Init
+	m.MMU.Write(consts.AddrIRQEnabled, 0x01)
+	runUntil(t, m, func() bool {
+		return len(interrupts) > 0 && m.CPU.AtInstruction() && m.CPU.Registers().PC != 0x0040
+	})
+	irq := interrupts[0]
+	if irq.IRQ != mmu.IRQVBlank || irq.Vector != 0x0040 || irq.Return < 0x0106 || irq.Return > 0x0108 {
+		t.Errorf("unexpected interrupt: %+v", irq)
+	}
+	if pc := m.CPU.Registers().PC; pc != irq.Return {
+		t.Errorf("returned to %04X, expected %04X", pc, irq.Return)
+	}
+}
+
+func TestHooksDoNotAllocate(t *testing.T) {
+	m := cputest.New(t, map[uint16][]byte{
+		0x0100: {0xCB, 0x37, 0x3E, 0x05, 0x18, 0xFA}, // SWAP A, LD A, 5, JR 0100
+	})
+	count := 0
+	m.CPU.OnInstruction = func(i *cpu.Instruction) { count += i.OperandCount }
+	allocs := testing.AllocsPerRun(1000, func() {
+		m.Step()
+	})
+	if allocs != 0 {
+		t.Errorf("got %v allocations per step", allocs)
+	}
+	if count == 0 {
+		t.Error("the hook was not called")
+	}
+}
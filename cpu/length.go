@@ -0,0 +1,37 @@
+package cpu
+
+import "strings"
+
+// prefixCB is the prefix of the extended opcodes.
+const prefixCB = 0xCB
+
+// lengths contains the length of the instructions by their first byte.
+var lengths = createLengthTable()
+
+func createLengthTable() (res [256]byte) {
+	notAlnum := func(c rune) bool {
+		return !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9')
+	}
+	for op := range res {
+		res[op] = 1
+		// the operands are written as n for a byte, nn for a word and d for a signed byte.
+		for _, f := range strings.FieldsFunc(Label(byte(op)), notAlnum) {
+			switch f {
+			case "n", "d":
+				res[op]++
+			case "nn":
+				res[op] += 2
+			}
+		}
+	}
+	res[prefixCB] = 2
+	// STOP is followed by a padding byte, assemblers write a NOP there.
+	res[0x10] = 2
+	return res
+}
+
+// Length returns the length of the instruction which starts with the opcode.
+// The length of the extended instructions includes the prefix.
+func Length(code byte) int {
+	return int(lengths[code])
+}
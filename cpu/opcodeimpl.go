@@ -19,16 +19,22 @@ func stop() opCode {
 		if !stopAlreadyHandled {
 			// TODO: implement original STOP
 		}
+		if c.OnHalt != nil {
+			c.halted(true, stopAlreadyHandled, false)
+		}
 	}))
 }
 
 func halt() opCode {
 	return opCodeFn(func(c *CPU, s *ocState) {
-		if c.ime || (mmu.IRQ(c.mmu.Read(consts.AddrIRQFlags))&mmu.IRQ(c.mmu.Read(consts.AddrIRQEnabled))&mmu.IRQAll) == mmu.IRQNone {
+		if c.ime || (mmu.IRQ(c.mmu.Peek(consts.AddrIRQFlags))&mmu.IRQ(c.mmu.Peek(consts.AddrIRQEnabled))&mmu.IRQAll) == mmu.IRQNone {
 			c.haltEnabled = true
 		} else {
 			c.haltBug = true
 		}
+		if c.OnHalt != nil {
+			c.halted(false, false, !c.haltEnabled)
+		}
 	})
 }
 
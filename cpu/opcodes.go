@@ -5,8 +5,6 @@ func init() {
 }
 
 func createOpCodeTable() *opCodeTable {
-	extTbl := createExtendedOpCodeTable()
-
 	return &opCodeTable{
 		/* 0x00 */ labeled{"NOP", nop()},
 		/* 0x01 */ labeled{"LD BC, nn", ld(bc.Write(), paramW())},
@@ -23,7 +21,7 @@ func createOpCodeTable() *opCodeTable {
 		/* 0x0C */ labeled{"INC C", incR8(c)},
 		/* 0x0D */ labeled{"DEC C", decR8(c)},
 		/* 0x0E */ labeled{"LD C, n", ld(c.Write(), paramB())},
-		/* 0x0F */ labeled{"RRC A", rrca()},
+		/* 0x0F */ labeled{"RRCA", rrca()},
 		/* 0x10 */ labeled{"STOP", stop()},
 		/* 0x11 */ labeled{"LD DE, nn", ld(de.Write(), paramW())},
 		/* 0x12 */ labeled{"LD (DE), A", ld(de.Deref().Write(), a.Read())},
@@ -46,7 +44,7 @@ func createOpCodeTable() *opCodeTable {
 		/* 0x23 */ labeled{"INC HL", incR16(hl)},
 		/* 0x24 */ labeled{"INC H", incR8(h)},
 		/* 0x25 */ labeled{"DEC H", decR8(h)},
-		/* 0x26 */ labeled{"LD H,n", ld(h.Write(), paramB())},
+		/* 0x26 */ labeled{"LD H, n", ld(h.Write(), paramB())},
 		/* 0x27 */ labeled{"DAA", daa()},
 		/* 0x28 */ labeled{"JR Z, n", jr(zero, false)},
 		/* 0x29 */ labeled{"ADD HL, HL", addR16(hl, hl)},
@@ -71,7 +69,7 @@ func createOpCodeTable() *opCodeTable {
 		/* 0x3C */ labeled{"INC A", incR8(a)},
 		/* 0x3D */ labeled{"DEC A", decR8(a)},
 		/* 0x3E */ labeled{"LD A, n", ld(a.Write(), paramB())},
-		/* 0x3F */ labeled{"CCFx", ccf()},
+		/* 0x3F */ labeled{"CCF", ccf()},
 		/* 0x40 */ labeled{"LD B, B", ld(b.Write(), b.Read())},
 		/* 0x41 */ labeled{"LD B, C", ld(b.Write(), c.Read())},
 		/* 0x42 */ labeled{"LD B, D", ld(b.Write(), d.Read())},
@@ -207,15 +205,15 @@ func createOpCodeTable() *opCodeTable {
 		/* 0xC4 */ labeled{"CALL NZ, nn", call(zero, true)},
 		/* 0xC5 */ labeled{"PUSH BC", push(bc)},
 		/* 0xC6 */ labeled{"ADD A, n", addR8(a, paramB())},
-		/* 0xC7 */ labeled{"RST 0 ", rst(0x00)},
+		/* 0xC7 */ labeled{"RST 00", rst(0x00)},
 		/* 0xC8 */ labeled{"RET Z", ret(zero, false)},
 		/* 0xC9 */ labeled{"RET", pipe(pop(pc), delay{})},
 		/* 0xCA */ labeled{"JP Z, nn", jp(zero, false)},
-		/* 0xCB */ pipe(paramB(), extTbl), // Extended OpCodes
+		/* 0xCB */ pipe(paramB(), extOpCodes), // Extended OpCodes
 		/* 0xCC */ labeled{"CALL Z, nn", call(zero, false)},
 		/* 0xCD */ labeled{"CALL nn", call(flag(0), false)},
 		/* 0xCE */ labeled{"ADC A, n", adcR8(a, paramB())},
-		/* 0xCF */ labeled{"RST 8 ", rst(0x08)},
+		/* 0xCF */ labeled{"RST 08", rst(0x08)},
 		/* 0xD0 */ labeled{"RET NC", ret(carry, true)},
 		/* 0xD1 */ labeled{"POP DE", pop(de)},
 		/* 0xD2 */ labeled{"JP NC, nn", jp(carry, true)},
@@ -267,7 +265,28 @@ func createOpCodeTable() *opCodeTable {
 	}
 }
 
-var opCodes = createOpCodeTable()
+var (
+	opCodes    = createOpCodeTable()
+	extOpCodes = createExtendedOpCodeTable()
+)
+
+// Label returns the label of an opcode, like "LD BC, nn". Operands are written as
+// n for a byte, nn for a word and d for a signed byte. The label of 0xCB is empty,
+// the opcodes following it are returned by ExtendedLabel.
+func Label(code byte) string {
+	if l, ok := opCodes[code].(labeledOpCode); ok {
+		return l.Label()
+	}
+	return ""
+}
+
+// ExtendedLabel returns the label of an opcode following the prefix 0xCB.
+func ExtendedLabel(code byte) string {
+	if l, ok := extOpCodes[code].(labeledOpCode); ok {
+		return l.Label()
+	}
+	return ""
+}
 
 func nextOpCode() opCode {
 	return pipe(paramB(), opCodes)
@@ -552,8 +571,12 @@ var irqHandlerOpCode = pipe(
 	writeByte{ /*hi*/ },
 	opCodeFn(func(c *CPU, state *ocState) {
 		irq := c.mmu.GetCurrentIterrupt()
+		ret := c.pc
 		c.pc = irq.Address()
 		c.ime = false
+		if c.OnInterrupt != nil {
+			c.interruptDispatched(irq, ret)
+		}
 	}),
 	writeByte{ /*lo*/ },
 )
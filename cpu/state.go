@@ -0,0 +1,205 @@
+package cpu
+
+import (
+	"github.com/boombuler/goboy2/savestate"
+)
+
+// The opcode which is currently executed is a path through the opcode graph.
+// All mutable execution state lives in the pipedOpcodes along that path, so
+// the path is stored as the position of every pipe and how its current sub
+// opcode was reached from the pipe's code at that position.
+
+const (
+	ocNone byte = iota
+	ocRoot
+	ocIRQ
+)
+
+const (
+	subNone       byte = iota // the pipe is not running
+	subCode                   // sub is the code at the current position
+	subEntry                  // sub is an entry of the opcode table at the current position
+	subEntryInner             // sub is the opcode wrapped by an entry of the opcode table
+	subJump                   // sub is the jump target of the jump at the current position
+)
+
+func unwrap(oc opCode) opCode {
+	if l, ok := oc.(labeled); ok {
+		return l.opCode
+	}
+	return oc
+}
+
+// SaveState writes the cpu state including the currently executed opcode.
+func (cpu *CPU) SaveState(w *savestate.Writer) {
+	w.U8(cpu.a)
+	w.U8(cpu.b)
+	w.U8(cpu.c)
+	w.U8(cpu.d)
+	w.U8(cpu.e)
+	w.U8(byte(cpu.f))
+	w.U8(cpu.h)
+	w.U8(cpu.l)
+	w.U16(cpu.pc)
+	w.U16(cpu.sp)
+	w.Bool(cpu.ime)
+	w.Bool(cpu.imeScheduled)
+	w.Bool(cpu.haltEnabled)
+	w.Bool(cpu.haltBug)
+	if cpu.key1 != nil {
+		w.Bool(cpu.key1.dblSpeed)
+		w.Bool(cpu.key1.prep)
+	}
+	w.Blob(cpu.opCodeState.buf)
+
+	switch top, _ := cpu.curOpCode.(*pipedOpcode); {
+	case cpu.curOpCode == nil:
+		w.U8(ocNone)
+	case top != nil && cpu.curOpCode == cpu.rootOC:
+		w.U8(ocRoot)
+		savePipe(w, top)
+	case top != nil && cpu.curOpCode == irqHandlerOpCode:
+		w.U8(ocIRQ)
+		savePipe(w, top)
+	default:
+		w.Failf("savestate: unknown opcode in flight")
+	}
+}
+
+func savePipe(w *savestate.Writer, po *pipedOpcode) {
+	w.Int(po.idx)
+	if po.sub == nil {
+		w.U8(subNone)
+		return
+	}
+	code := po.codes[po.idx]
+	switch c := code.(type) {
+	case *opCodeTable:
+		idx, ok := tableIndex(c, po.sub)
+		if !ok {
+			w.Failf("savestate: unknown opcode in flight")
+			return
+		}
+		if _, isEntry := po.sub.(labeled); isEntry || c[idx] == po.sub {
+			w.U8(subEntry)
+		} else {
+			w.U8(subEntryInner)
+		}
+		w.U8(idx)
+	case *jump:
+		w.U8(subJump)
+	default:
+		w.U8(subCode)
+	}
+	if p, ok := unwrap(po.sub).(*pipedOpcode); ok {
+		savePipe(w, p)
+	}
+}
+
+// tableIndex finds the table index of an entry. Only entries wrapping a pipe
+// can be in flight, so the pipe identifies the entry.
+func tableIndex(t *opCodeTable, oc opCode) (byte, bool) {
+	want, _ := unwrap(oc).(*pipedOpcode)
+	for i, e := range t {
+		if p, ok := unwrap(e).(*pipedOpcode); ok && p == want {
+			return byte(i), true
+		}
+	}
+	return 0, false
+}
+
+// LoadState restores a state written by SaveState.
+func (cpu *CPU) LoadState(r *savestate.Reader) {
+	cpu.a = r.U8()
+	cpu.b = r.U8()
+	cpu.c = r.U8()
+	cpu.d = r.U8()
+	cpu.e = r.U8()
+	cpu.f = flag(r.U8())
+	cpu.h = r.U8()
+	cpu.l = r.U8()
+	cpu.pc = r.U16()
+	cpu.sp = r.U16()
+	cpu.ime = r.Bool()
+	cpu.imeScheduled = r.Bool()
+	cpu.haltEnabled = r.Bool()
+	cpu.haltBug = r.Bool()
+	if cpu.key1 != nil {
+		cpu.key1.dblSpeed = r.Bool()
+		cpu.key1.prep = r.Bool()
+	}
+	cpu.opCodeState.buf = append(cpu.opCodeState.buf[:0], r.Blob(-1)...)
+
+	if top, ok := cpu.curOpCode.(*pipedOpcode); ok {
+		resetPipe(top)
+	}
+	cpu.curOpCode = nil
+
+	var top *pipedOpcode
+	switch r.U8() {
+	case ocNone:
+		return
+	case ocRoot:
+		top = cpu.rootOC.(*pipedOpcode)
+	case ocIRQ:
+		top = irqHandlerOpCode.(*pipedOpcode)
+	default:
+		r.Failf("savestate: invalid cpu state")
+		return
+	}
+	if loadPipe(r, top) {
+		cpu.curOpCode = top
+	} else {
+		resetPipe(top)
+	}
+}
+
+func loadPipe(r *savestate.Reader, po *pipedOpcode) bool {
+	po.idx = r.Int()
+	kind := r.U8()
+	if r.Err() != nil || po.idx < 0 || po.idx >= len(po.codes) {
+		r.Failf("savestate: invalid cpu state")
+		return false
+	}
+	code := po.codes[po.idx]
+	switch kind {
+	case subNone:
+		po.sub = nil
+		return true
+	case subCode:
+		po.sub = code
+	case subEntry, subEntryInner:
+		t, ok := code.(*opCodeTable)
+		if !ok {
+			r.Failf("savestate: invalid cpu state")
+			return false
+		}
+		po.sub = t[r.U8()]
+		if kind == subEntryInner {
+			po.sub = unwrap(po.sub)
+		}
+	case subJump:
+		j, ok := code.(*jump)
+		if !ok {
+			r.Failf("savestate: invalid cpu state")
+			return false
+		}
+		po.sub = j.jumpOC
+	default:
+		r.Failf("savestate: invalid cpu state")
+		return false
+	}
+	if p, ok := unwrap(po.sub).(*pipedOpcode); ok {
+		return loadPipe(r, p)
+	}
+	return r.Err() == nil
+}
+
+// resetPipe clears the execution state of the pipe and all pipes it is currently executing.
+func resetPipe(po *pipedOpcode) {
+	if p, ok := unwrap(po.sub).(*pipedOpcode); ok {
+		resetPipe(p)
+	}
+	po.sub = nil
+	po.idx = 0
+}
@@ -0,0 +1,21 @@
+package cpu
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/boombuler/goboy2/consts"
+	"github.com/boombuler/goboy2/mmu"
+	"github.com/boombuler/goboy2/savestate"
+)
+
+func TestSaveUnknownOpcode(t *testing.T) {
+	c := New(mmu.New(consts.DMG))
+	c.curOpCode = opCodeFn(func(cpu *CPU, state *ocState) {})
+
+	w := savestate.NewWriter(ioutil.Discard)
+	c.SaveState(w)
+	if w.Err() == nil {
+		t.Error("saving an unknown opcode did not fail")
+	}
+}
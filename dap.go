@@ -0,0 +1,28 @@
+package main
+
+import (
+	"log"
+	"net"
+	"path/filepath"
+
+	"github.com/boombuler/goboy2/dap"
+)
+
+// serveDAP attaches a debugger and serves the Debug Adapter Protocol on addr
+// until the emulation exits. The assembler sources are searched next to the rom.
+func (gb *GameBoy) serveDAP(addr string, rom *loadedROM) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("waiting for a debug adapter client on", l.Addr())
+	read := func(bank int, addr uint16) byte {
+		return romByte(rom.data, bank, addr)
+	}
+	srv := dap.NewServer(gb.AttachDebugger(), gb.exitChan, read, filepath.Dir(rom.name))
+	go func() {
+		if err := srv.Serve(l); err != nil {
+			log.Println("dap:", err)
+		}
+	}()
+}
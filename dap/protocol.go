@@ -0,0 +1,126 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// request is a message sent by the client.
+type request struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// response answers a request.
+type response struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+// event is a message sent by the server without a request.
+type event struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+// readMessage reads a message with its Content-Length header.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if i := strings.IndexByte(line, ':'); i >= 0 && strings.EqualFold(line[:i], "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(line[i+1:])); err != nil {
+				return nil, fmt.Errorf("invalid content length %q", line[i+1:])
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing content length")
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
+
+// writeMessage writes a message with its Content-Length header.
+func writeMessage(w io.Writer, msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+type source struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+type sourceBreakpoint struct {
+	Line      int    `json:"line"`
+	Condition string `json:"condition,omitempty"`
+}
+
+type functionBreakpoint struct {
+	Name      string `json:"name"`
+	Condition string `json:"condition,omitempty"`
+}
+
+type breakpoint struct {
+	ID       int     `json:"id,omitempty"`
+	Verified bool    `json:"verified"`
+	Message  string  `json:"message,omitempty"`
+	Source   *source `json:"source,omitempty"`
+	Line     int     `json:"line,omitempty"`
+}
+
+type stackFrame struct {
+	ID                          int     `json:"id"`
+	Name                        string  `json:"name"`
+	Source                      *source `json:"source,omitempty"`
+	Line                        int     `json:"line"`
+	Column                      int     `json:"column"`
+	InstructionPointerReference string  `json:"instructionPointerReference,omitempty"`
+}
+
+type scope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	VariablesReference int    `json:"variablesReference"`
+	MemoryReference    string `json:"memoryReference,omitempty"`
+}
+
+type thread struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
@@ -0,0 +1,34 @@
+package dap
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMessages(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := writeMessage(buf, &event{Seq: 1, Type: "event", Event: "initialized"}); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "Content-Length: 46\r\n\r\n" + `{"seq":1,"type":"event","event":"initialized"}`
+	if buf.String() != expected {
+		t.Fatalf("got %q, expected %q", buf.String(), expected)
+	}
+
+	buf.WriteString("content-length: 2\r\nContent-Type: application/json\r\n\r\n{}")
+	r := bufio.NewReader(buf)
+	for _, msg := range []string{expected[22:], "{}"} {
+		data, err := readMessage(r)
+		if err != nil || string(data) != msg {
+			t.Errorf("got %q %v, expected %q", data, err, msg)
+		}
+	}
+
+	for _, invalid := range []string{"\r\n{}", "Content-Length: x\r\n\r\n", "Content-Length: 5\r\n\r\n{}"} {
+		if _, err := readMessage(bufio.NewReader(strings.NewReader(invalid))); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
@@ -0,0 +1,590 @@
+// Package dap serves the Debug Adapter Protocol, so the emulation can be
+// debugged from an editor.
+//
+// The server controls the emulation through a debugger. Breakpoints can be set
+// on the lines of the assembler sources of RGBDS projects, which are mapped to
+// addresses with the symbol file of the rom.
+package dap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/boombuler/goboy2/debugger"
+	"github.com/boombuler/goboy2/symbols"
+)
+
+// threadID is the id of the only thread, the cpu.
+const threadID = 1
+
+// registersReference is the variables reference of the registers scope.
+const registersReference = 1
+
+var errRunning = errors.New("the emulation is running")
+
+// Server accepts the connections of debug adapter clients.
+type Server struct {
+	d    *debugger.Debugger
+	exit <-chan struct{}
+	read func(bank int, addr uint16) byte
+	dirs []string
+}
+
+// NewServer creates a server for the debugger. read returns a byte of a rom
+// bank, dirs are the directories with the assembler sources, unless the client
+// sends other directories.
+func NewServer(d *debugger.Debugger, exit <-chan struct{}, read func(bank int, addr uint16) byte, dirs ...string) *Server {
+	return &Server{d: d, exit: exit, read: read, dirs: dirs}
+}
+
+// Serve accepts connections until the emulation exits. Only one client is
+// served at a time.
+func (s *Server) Serve(l net.Listener) error {
+	go func() {
+		<-s.exit
+		l.Close()
+	}()
+	// the debugger stops before the first instruction, the first client
+	// decides if the emulation continues.
+	select {
+	case <-s.d.Stops():
+	case _, _ = <-s.exit:
+		return nil
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case _, _ = <-s.exit:
+				return nil
+			default:
+				return err
+			}
+		}
+		newSession(s, conn).run()
+		conn.Close()
+	}
+}
+
+// session is the connection to a client.
+type session struct {
+	srv  *Server
+	d    *debugger.Debugger
+	conn io.ReadWriteCloser
+
+	mu  sync.Mutex
+	seq int
+
+	sources     *SourceMap
+	stopOnEntry bool
+	breakpoints map[string][]int
+	functions   []int
+	configured  chan struct{}
+	done        chan struct{}
+}
+
+func newSession(srv *Server, conn io.ReadWriteCloser) *session {
+	return &session{
+		srv:         srv,
+		d:           srv.d,
+		conn:        conn,
+		breakpoints: make(map[string][]int),
+		configured:  make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+func (s *session) send(msg interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	switch m := msg.(type) {
+	case *response:
+		m.Seq, m.Type = s.seq, "response"
+	case *event:
+		m.Seq, m.Type = s.seq, "event"
+	}
+	if err := writeMessage(s.conn, msg); err != nil {
+		log.Println("dap:", err)
+	}
+}
+
+func (s *session) sendEvent(name string, body interface{}) {
+	s.send(&event{Event: name, Body: body})
+}
+
+// run handles the requests of the client until it disconnects.
+func (s *session) run() {
+	defer close(s.done)
+	go s.forwardEvents()
+
+	r := bufio.NewReader(s.conn)
+	for {
+		data, err := readMessage(r)
+		if err != nil {
+			if err != io.EOF {
+				log.Println("dap:", err)
+			}
+			s.cleanup()
+			return
+		}
+		var req request
+		if err := json.Unmarshal(data, &req); err != nil {
+			log.Println("dap: invalid message:", err)
+			continue
+		}
+		if req.Type != "request" {
+			continue
+		}
+
+		body, err := s.handle(req.Command, req.Arguments)
+		resp := &response{RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: body}
+		if err != nil {
+			resp.Message = err.Error()
+		}
+		s.send(resp)
+
+		switch req.Command {
+		case "initialize":
+			s.sendEvent("initialized", nil)
+		case "configurationDone":
+			s.start()
+		case "disconnect":
+			return
+		}
+	}
+}
+
+// start reports the stop of the emulation, or resumes it, after the client
+// sent its configuration. If a previous client resumed the emulation, it is
+// paused for stopOnEntry.
+func (s *session) start() {
+	switch {
+	case !s.d.Stopped():
+		if s.stopOnEntry {
+			s.d.Pause()
+		}
+	case s.stopOnEntry:
+		s.sendEvent("stopped", map[string]interface{}{"reason": "entry", "threadId": threadID, "allThreadsStopped": true})
+	default:
+		s.d.Continue()
+	}
+	close(s.configured)
+}
+
+// forwardEvents sends the stops of the debugger after the configuration is
+// done, and the end of the emulation.
+func (s *session) forwardEvents() {
+	configured := s.configured
+	var stops <-chan debugger.Stop
+	for {
+		select {
+		case <-s.done:
+			return
+		case _, _ = <-s.srv.exit:
+			s.sendEvent("terminated", nil)
+			s.conn.Close()
+			return
+		case <-configured:
+			stops = s.d.Stops()
+			configured = nil
+		case stop := <-stops:
+			s.sendEvent("stopped", map[string]interface{}{
+				"reason":            stopReason(stop.Reason),
+				"description":       stop.Reason,
+				"threadId":          threadID,
+				"allThreadsStopped": true,
+			})
+		}
+	}
+}
+
+func stopReason(reason string) string {
+	switch {
+	case strings.HasPrefix(reason, "breakpoint"):
+		return "breakpoint"
+	case strings.HasPrefix(reason, "watchpoint"):
+		return "data breakpoint"
+	case reason == "paused":
+		return "pause"
+	}
+	return "step"
+}
+
+// cleanup removes the breakpoints of the session and resumes the emulation.
+func (s *session) cleanup() {
+	for _, ids := range s.breakpoints {
+		s.deleteBreakpoints(ids)
+	}
+	s.deleteBreakpoints(s.functions)
+	s.breakpoints = make(map[string][]int)
+	s.functions = nil
+	s.d.Continue()
+}
+
+func (s *session) deleteBreakpoints(ids []int) {
+	for _, id := range ids {
+		s.d.Delete(id)
+	}
+}
+
+func (s *session) handle(command string, args json.RawMessage) (interface{}, error) {
+	switch command {
+	case "initialize":
+		return map[string]bool{
+			"supportsConfigurationDoneRequest": true,
+			"supportsFunctionBreakpoints":      true,
+			"supportsConditionalBreakpoints":   true,
+			"supportsSetVariable":              true,
+			"supportsReadMemoryRequest":        true,
+			"supportsWriteMemoryRequest":       true,
+			"supportsEvaluateForHovers":        true,
+		}, nil
+	case "launch", "attach":
+		return nil, s.attach(args)
+	case "configurationDone":
+		return nil, nil
+	case "setBreakpoints":
+		return s.setBreakpoints(args)
+	case "setFunctionBreakpoints":
+		return s.setFunctionBreakpoints(args)
+	case "setExceptionBreakpoints":
+		return map[string]interface{}{"breakpoints": []breakpoint{}}, nil
+	case "threads":
+		return map[string]interface{}{"threads": []thread{{threadID, "cpu"}}}, nil
+	case "continue":
+		s.d.Continue()
+		return map[string]bool{"allThreadsContinued": true}, nil
+	case "next":
+		s.d.StepOver()
+		return nil, nil
+	case "stepIn":
+		s.d.Step(1)
+		return nil, nil
+	case "stepOut":
+		s.d.StepOut()
+		return nil, nil
+	case "pause":
+		s.d.Pause()
+		return nil, nil
+	case "disconnect":
+		s.cleanup()
+		return nil, nil
+	}
+
+	if !s.d.Stopped() {
+		return nil, errRunning
+	}
+	switch command {
+	case "stackTrace":
+		return s.stackTrace()
+	case "scopes":
+		return map[string]interface{}{"scopes": []scope{{"Registers", registersReference, false}}}, nil
+	case "variables":
+		return s.variables(args)
+	case "setVariable":
+		return s.setVariable(args)
+	case "readMemory":
+		return s.readMemory(args)
+	case "writeMemory":
+		return s.writeMemory(args)
+	case "evaluate":
+		return s.evaluate(args)
+	}
+	return nil, fmt.Errorf("unsupported command %q", command)
+}
+
+func (s *session) attach(args json.RawMessage) error {
+	var a struct {
+		SourceDirs  []string `json:"sourceDirs"`
+		StopOnEntry bool     `json:"stopOnEntry"`
+	}
+	if len(args) > 0 {
+		if err := json.Unmarshal(args, &a); err != nil {
+			return err
+		}
+	}
+	s.stopOnEntry = a.StopOnEntry
+	dirs := a.SourceDirs
+	if len(dirs) == 0 {
+		dirs = s.srv.dirs
+	}
+	var err error
+	s.sources, err = ScanSources(dirs, s.d.Symbols(), s.srv.read)
+	return err
+}
+
+// romBank returns the bank of a breakpoint, which is only used for rom addresses.
+func romBank(bank int, addr uint16) int {
+	if addr >= 0x8000 {
+		return -1
+	}
+	return bank
+}
+
+func (s *session) setBreakpoints(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Source      source             `json:"source"`
+		Breakpoints []sourceBreakpoint `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	s.deleteBreakpoints(s.breakpoints[a.Source.Path])
+	var ids []int
+	res := make([]breakpoint, 0, len(a.Breakpoints))
+	for _, sb := range a.Breakpoints {
+		var bank int
+		var addr uint16
+		ok := false
+		line := sb.Line
+		if s.sources != nil {
+			bank, addr, line, ok = s.sources.Location(a.Source.Path, sb.Line)
+		}
+		if !ok {
+			res = append(res, breakpoint{Message: "no code found at this line"})
+			continue
+		}
+		cond, err := parseCondition(sb.Condition)
+		if err != nil {
+			res = append(res, breakpoint{Message: err.Error()})
+			continue
+		}
+		b := s.d.AddBreakpoint(addr, romBank(bank, addr), cond)
+		ids = append(ids, b.ID)
+		src := a.Source
+		res = append(res, breakpoint{ID: b.ID, Verified: true, Source: &src, Line: line})
+	}
+	s.breakpoints[a.Source.Path] = ids
+	return map[string]interface{}{"breakpoints": res}, nil
+}
+
+func parseCondition(text string) (*debugger.Condition, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	return debugger.ParseCondition(text)
+}
+
+func (s *session) setFunctionBreakpoints(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Breakpoints []functionBreakpoint `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	s.deleteBreakpoints(s.functions)
+	s.functions = nil
+	res := make([]breakpoint, 0, len(a.Breakpoints))
+	for _, fb := range a.Breakpoints {
+		bank, addr := -1, uint16(0)
+		if sym, ok := s.d.Symbols().Lookup(fb.Name); ok {
+			bank, addr = romBank(sym.Bank, sym.Address), sym.Address
+		} else if v, err := debugger.ParseAddress(fb.Name); err == nil {
+			addr = v
+		} else {
+			res = append(res, breakpoint{Message: fmt.Sprintf("unknown label %q", fb.Name)})
+			continue
+		}
+		cond, err := parseCondition(fb.Condition)
+		if err != nil {
+			res = append(res, breakpoint{Message: err.Error()})
+			continue
+		}
+		b := s.d.AddBreakpoint(addr, bank, cond)
+		s.functions = append(s.functions, b.ID)
+		res = append(res, breakpoint{ID: b.ID, Verified: true})
+	}
+	return map[string]interface{}{"breakpoints": res}, nil
+}
+
+// frame creates the stack frame of an address.
+func (s *session) frame(id int, addr uint16, bank int) stackFrame {
+	f := stackFrame{
+		ID:                          id,
+		Name:                        s.d.Symbols().Describe(bank, addr),
+		InstructionPointerReference: fmt.Sprintf("0x%04X", addr),
+	}
+	if f.Name == "" {
+		f.Name = f.InstructionPointerReference
+	}
+	if s.sources != nil {
+		if file, line, ok := s.sources.Position(bank, addr); ok {
+			f.Source = &source{Path: file}
+			f.Line, f.Column = line, 1
+		}
+	}
+	return f
+}
+
+func (s *session) stackTrace() (interface{}, error) {
+	pc := s.d.Registers().PC
+	frames := []stackFrame{s.frame(0, pc, s.d.ROMBank(pc))}
+	for i, f := range s.d.CallStack() {
+		frames = append(frames, s.frame(i+1, f.Caller, f.CallerBank))
+	}
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+var registerNames = []string{"A", "F", "B", "C", "D", "E", "H", "L", "AF", "BC", "DE", "HL", "SP", "PC"}
+
+func (s *session) variables(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	vars := []variable{}
+	if a.VariablesReference == registersReference {
+		regs := s.d.Registers()
+		for _, name := range registerNames {
+			v, _ := regs.Get(name)
+			vars = append(vars, registerVariable(name, v))
+		}
+		f := regs.F
+		vars = append(vars, variable{
+			Name:  "flags",
+			Value: fmt.Sprintf("Z:%d N:%d H:%d C:%d", f>>7&1, f>>6&1, f>>5&1, f>>4&1),
+		})
+	}
+	return map[string]interface{}{"variables": vars}, nil
+}
+
+func registerVariable(name string, v int) variable {
+	if len(name) == 1 {
+		return variable{Name: name, Value: fmt.Sprintf("$%02X", v)}
+	}
+	return variable{Name: name, Value: fmt.Sprintf("$%04X", v), MemoryReference: fmt.Sprintf("0x%04X", v)}
+}
+
+func (s *session) setVariable(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	v, err := debugger.ParseNumber(a.Value)
+	if err != nil {
+		return nil, err
+	}
+	regs := s.d.Registers()
+	if !regs.Set(a.Name, v) {
+		return nil, fmt.Errorf("unknown register %q", a.Name)
+	}
+	s.d.SetRegisters(regs)
+	v, _ = regs.Get(a.Name)
+	return map[string]string{"value": registerVariable(a.Name, v).Value}, nil
+}
+
+// memoryArgs are the arguments of readMemory and writeMemory.
+type memoryArgs struct {
+	MemoryReference string `json:"memoryReference"`
+	Offset          int    `json:"offset"`
+	Count           int    `json:"count"`
+	Data            string `json:"data"`
+}
+
+func parseMemoryArgs(args json.RawMessage) (memoryArgs, int, error) {
+	var a memoryArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return a, 0, err
+	}
+	addr, err := debugger.ParseNumber(a.MemoryReference)
+	if err != nil {
+		return a, 0, err
+	}
+	return a, addr + a.Offset, nil
+}
+
+func (s *session) readMemory(args json.RawMessage) (interface{}, error) {
+	a, addr, err := parseMemoryArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	for i := 0; i < a.Count && addr+i >= 0 && addr+i <= 0xFFFF; i++ {
+		data = append(data, s.d.ReadMemory(uint16(addr+i)))
+	}
+	return map[string]interface{}{
+		"address":         fmt.Sprintf("0x%04X", addr),
+		"data":            base64.StdEncoding.EncodeToString(data),
+		"unreadableBytes": a.Count - len(data),
+	}, nil
+}
+
+func (s *session) writeMemory(args json.RawMessage) (interface{}, error) {
+	a, addr, err := parseMemoryArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(a.Data)
+	if err != nil {
+		return nil, err
+	}
+	n := 0
+	for ; n < len(data) && addr+n >= 0 && addr+n <= 0xFFFF; n++ {
+		s.d.WriteMemory(uint16(addr+n), data[n])
+	}
+	return map[string]int{"bytesWritten": n}, nil
+}
+
+// evaluate returns the value of a register, a label or a byte of memory like
+// "[C000]". Other expressions of the debug console are executed as debugger commands.
+func (s *session) evaluate(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Expression string `json:"expression"`
+		Context    string `json:"context"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	expr := strings.TrimSpace(a.Expression)
+	if v, ok := s.d.Registers().Get(expr); ok {
+		return evalResult(registerVariable(strings.ToUpper(expr), v)), nil
+	}
+	if sym, ok := s.d.Symbols().Lookup(expr); ok {
+		return evalResult(labelVariable(sym, s.d.ReadMemory(sym.Address))), nil
+	}
+	if strings.HasPrefix(expr, "[") && strings.HasSuffix(expr, "]") {
+		addr, err := debugger.ParseAddress(expr[1 : len(expr)-1])
+		if err != nil {
+			return nil, err
+		}
+		return evalResult(variable{Value: fmt.Sprintf("$%02X", s.d.ReadMemory(addr))}), nil
+	}
+	if a.Context != "repl" {
+		return nil, fmt.Errorf("can not evaluate %q", expr)
+	}
+	out := new(bytes.Buffer)
+	if err := s.d.Exec(expr, out); err != nil {
+		return nil, err
+	}
+	return evalResult(variable{Value: strings.TrimRight(out.String(), "\n")}), nil
+}
+
+func labelVariable(sym symbols.Symbol, value byte) variable {
+	return variable{
+		Value:           fmt.Sprintf("$%04X [$%02X]", sym.Address, value),
+		MemoryReference: fmt.Sprintf("0x%04X", sym.Address),
+	}
+}
+
+func evalResult(v variable) map[string]interface{} {
+	res := map[string]interface{}{"result": v.Value, "variablesReference": 0}
+	if v.MemoryReference != "" {
+		res["memoryReference"] = v.MemoryReference
+	}
+	return res
+}
@@ -0,0 +1,173 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/boombuler/goboy2/cpu/cputest"
+	"github.com/boombuler/goboy2/debugger"
+)
+
+var program = map[uint16][]byte{
+	0x0100: {
+		0x3E, 0x05, // LD A, 5
+		0xEA, 0x00, 0xC0, // LD (C000), A
+		0xCD, 0x00, 0x02, // CALL 0200
+		0x18, 0xF6, // JR 0100
+	},
+	0x0200: {0xC9}, // RET
+}
+
+// newTestServer runs the program until the returned function is called.
+func newTestServer(t *testing.T) (*Server, func()) {
+	m := cputest.New(t, program)
+	exit := make(chan struct{})
+	d := debugger.New(m.CPU, m.MMU, m.Cart, exit)
+	m.CPU.OnInstruction, m.CPU.OnInterrupt = d.HookInstruction, d.HookInterrupt
+	read := func(bank int, addr uint16) byte { return m.ROM[addr] }
+	return NewServer(d, exit, read), m.Run(exit, d.Instruction)
+}
+
+// testClient sends requests to a session and reads its messages.
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+	seq  int
+}
+
+type testMessage struct {
+	Type    string          `json:"type"`
+	Command string          `json:"command"`
+	Event   string          `json:"event"`
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Body    json.RawMessage `json:"body"`
+}
+
+func (c *testClient) request(command string, args interface{}) {
+	c.t.Helper()
+	c.seq++
+	msg := map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args}
+	if err := writeMessage(c.conn, msg); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// expect reads messages until the response to command or the event, and
+// decodes its body into body.
+func (c *testClient) expect(typ, name string, body interface{}) {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		data, err := readMessage(c.r)
+		if err != nil {
+			c.t.Fatalf("waiting for %s %s: %v", typ, name, err)
+		}
+		var msg testMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.t.Fatal(err)
+		}
+		if msg.Type != typ || (msg.Command != name && msg.Event != name) {
+			continue
+		}
+		if typ == "response" && !msg.Success {
+			c.t.Fatalf("%s failed: %s", name, msg.Message)
+		}
+		if body != nil {
+			if err := json.Unmarshal(msg.Body, body); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return
+	}
+}
+
+func (c *testClient) call(command string, args, body interface{}) {
+	c.t.Helper()
+	c.request(command, args)
+	c.expect("response", command, body)
+}
+
+func (c *testClient) expectStop(reason string, pc string) {
+	c.t.Helper()
+	var stopped struct {
+		Reason string `json:"reason"`
+	}
+	c.expect("event", "stopped", &stopped)
+	if stopped.Reason != reason {
+		c.t.Fatalf("stopped with reason %q, expected %q", stopped.Reason, reason)
+	}
+	var trace struct {
+		StackFrames []stackFrame `json:"stackFrames"`
+	}
+	c.call("stackTrace", map[string]int{"threadId": threadID}, &trace)
+	if len(trace.StackFrames) == 0 || trace.StackFrames[0].InstructionPointerReference != pc {
+		c.t.Fatalf("got the frames %v, expected to stop at %s", trace.StackFrames, pc)
+	}
+}
+
+func TestSession(t *testing.T) {
+	srv, stop := newTestServer(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- srv.Serve(l)
+	}()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := &testClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	c.call("initialize", map[string]string{"adapterID": "goboy2"}, nil)
+	c.expect("event", "initialized", nil)
+	c.call("attach", map[string]interface{}{"stopOnEntry": true}, nil)
+	var bps struct {
+		Breakpoints []breakpoint `json:"breakpoints"`
+	}
+	c.call("setFunctionBreakpoints", map[string]interface{}{
+		"breakpoints": []functionBreakpoint{{Name: "0200"}, {Name: "Missing"}},
+	}, &bps)
+	if len(bps.Breakpoints) != 2 || !bps.Breakpoints[0].Verified || bps.Breakpoints[1].Verified {
+		t.Fatalf("got the breakpoints %v", bps.Breakpoints)
+	}
+	c.call("configurationDone", nil, nil)
+	c.expectStop("entry", "0x0100")
+
+	c.call("continue", map[string]int{"threadId": threadID}, nil)
+	c.expectStop("breakpoint", "0x0200")
+
+	var eval struct {
+		Result string `json:"result"`
+	}
+	c.call("evaluate", map[string]string{"expression": "a"}, &eval)
+	if eval.Result != "$05" {
+		t.Errorf("got %q for A, expected $05", eval.Result)
+	}
+	c.call("writeMemory", map[string]string{"memoryReference": "0xC001", "data": "Kg=="}, nil)
+	var mem struct {
+		Data string `json:"data"`
+	}
+	c.call("readMemory", map[string]interface{}{"memoryReference": "0xC000", "count": 2}, &mem)
+	if mem.Data != "BSo=" {
+		t.Errorf("read %q, expected BSo=", mem.Data)
+	}
+
+	c.call("next", map[string]int{"threadId": threadID}, nil)
+	c.expectStop("step", "0x0108")
+
+	c.call("disconnect", nil, nil)
+	stop()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
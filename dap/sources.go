@@ -0,0 +1,246 @@
+package dap
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/boombuler/goboy2/disasm"
+	"github.com/boombuler/goboy2/symbols"
+)
+
+// sourceExtensions are the file extensions of assembler sources.
+var sourceExtensions = map[string]bool{".asm": true, ".inc": true, ".s": true, ".z80": true, ".sm83": true}
+
+// mnemonics are the instructions of the cpu as they are written in RGBDS sources.
+var mnemonics = map[string]bool{
+	"adc": true, "add": true, "and": true, "bit": true, "call": true, "ccf": true, "cp": true, "cpl": true,
+	"daa": true, "dec": true, "di": true, "ei": true, "halt": true, "inc": true, "jp": true, "jr": true,
+	"ld": true, "ldh": true, "ldi": true, "ldd": true, "ldhl": true, "nop": true, "or": true, "pop": true,
+	"push": true, "res": true, "ret": true, "reti": true, "rl": true, "rla": true, "rlc": true, "rlca": true,
+	"rr": true, "rra": true, "rrc": true, "rrca": true, "rst": true, "sbc": true, "scf": true, "set": true,
+	"sla": true, "sra": true, "srl": true, "stop": true, "sub": true, "swap": true, "xor": true,
+}
+
+// noCode are the directives which do not emit bytes.
+var noCode = map[string]bool{
+	"def": true, "export": true, "global": true, "purge": true, "assert": true, "static_assert": true,
+	"print": true, "println": true, "warn": true, "rsreset": true, "rsset": true, "opt": true,
+	"equ": true, "equs": true, "=": true, "set": true, "rb": true, "rw": true, "rl": true, "redef": true,
+}
+
+// location is an address in a rom bank. The bank of addresses outside of the rom is -1.
+type location struct {
+	bank int
+	addr uint16
+}
+
+func newLocation(bank int, addr uint16) location {
+	if addr >= 0x8000 {
+		bank = -1
+	}
+	return location{bank, addr}
+}
+
+// position is a line of a source file.
+type position struct {
+	file string
+	line int
+}
+
+// SourceMap maps the lines of the assembler sources to addresses.
+//
+// RGBDS does not write line information, so the lines are found by the labels
+// of the symbol file. The instructions following a label are matched with the
+// instructions decoded from the rom, until a line is found which can not be
+// matched, like a macro or data.
+type SourceMap struct {
+	syms   *symbols.Table
+	lines  map[string]map[int]location
+	sorted map[string][]int
+	addrs  map[location]position
+	labels map[string]position
+}
+
+// ScanSources reads the assembler sources in the directories and their
+// subdirectories. read returns a byte of a rom bank.
+func ScanSources(dirs []string, syms *symbols.Table, read func(bank int, addr uint16) byte) (*SourceMap, error) {
+	m := &SourceMap{
+		syms:   syms,
+		lines:  make(map[string]map[int]location),
+		sorted: make(map[string][]int),
+		addrs:  make(map[location]position),
+		labels: make(map[string]position),
+	}
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+			if err != nil || fi.IsDir() || !sourceExtensions[strings.ToLower(filepath.Ext(path))] {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			return m.scan(path, f, read)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// stripComment removes a comment which is not part of a string.
+func stripComment(line string) string {
+	quoted := false
+	for i, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ';' && !quoted:
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func isLabelChar(c rune) bool {
+	return c == '_' || c == '.' || c == '#' || c == '@' || c == '$' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// splitLabel splits a label definition from the rest of a line.
+func splitLabel(line string) (label, rest string) {
+	end := strings.IndexFunc(line, func(c rune) bool { return !isLabelChar(c) })
+	if end < 0 {
+		end = len(line)
+	}
+	switch {
+	case end > 0 && end < len(line) && line[end] == ':':
+		return line[:end], strings.TrimLeft(line[end:], ":")
+	case end > 1 && line[0] == '.':
+		// local labels do not need a colon
+		return line[:end], line[end:]
+	}
+	return "", line
+}
+
+// normalizeMnemonic returns the mnemonic used by the disassembler for the
+// different spellings of loads.
+func normalizeMnemonic(m string) string {
+	switch m = strings.ToUpper(m); m {
+	case "LDI", "LDD", "LDH", "LDHL":
+		return "LD"
+	}
+	return m
+}
+
+func (m *SourceMap) scan(file string, r io.Reader, read func(bank int, addr uint16) byte) error {
+	lines := make(map[int]location)
+	var global string
+	var cur location
+	valid := false
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		label, rest := splitLabel(strings.TrimSpace(stripComment(scanner.Text())))
+		if label != "" {
+			name := label
+			if strings.HasPrefix(label, ".") {
+				name = global + label
+			} else if !strings.Contains(label, ".") {
+				global = label
+			}
+			sym, ok := m.syms.Lookup(name)
+			valid = ok
+			if ok {
+				cur = newLocation(sym.Bank, sym.Address)
+				lines[lineNo] = cur
+				m.labels[name] = position{file, lineNo}
+			}
+		}
+
+		fields := strings.Fields(strings.ToLower(rest))
+		switch {
+		case len(fields) == 0:
+		case mnemonics[fields[0]]:
+			if !valid || cur.addr >= 0x8000 {
+				valid = false
+				continue
+			}
+			loc := cur
+			inst := disasm.Decode(func(a uint16) byte { return read(loc.bank, a) }, loc.addr)
+			if normalizeMnemonic(inst.Mnemonic) != normalizeMnemonic(fields[0]) {
+				valid = false
+				continue
+			}
+			if _, ok := lines[lineNo]; !ok {
+				lines[lineNo] = loc
+			}
+			cur.addr += uint16(inst.Len())
+		case noCode[fields[0]] || (len(fields) > 1 && noCode[fields[1]]):
+		default:
+			valid = false
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+	m.lines[file] = lines
+	sorted := make([]int, 0, len(lines))
+	for l, loc := range lines {
+		sorted = append(sorted, l)
+		// prefer the instruction over the labels in front of it
+		if p, ok := m.addrs[loc]; !ok || (p.file == file && l > p.line) {
+			m.addrs[loc] = position{file, l}
+		}
+	}
+	sort.Ints(sorted)
+	m.sorted[file] = sorted
+	return nil
+}
+
+func cleanPath(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return filepath.Clean(file)
+}
+
+// Location returns the address of a source line. If the line has no address,
+// the next line with an address is used. It returns the used line.
+func (m *SourceMap) Location(file string, line int) (bank int, addr uint16, actual int, ok bool) {
+	file = cleanPath(file)
+	sorted := m.sorted[file]
+	i := sort.SearchInts(sorted, line)
+	if i == len(sorted) {
+		return 0, 0, 0, false
+	}
+	loc := m.lines[file][sorted[i]]
+	return loc.bank, loc.addr, sorted[i], true
+}
+
+// Position returns the source line of an address. Addresses without a line are
+// mapped to the line of the nearest label before them.
+func (m *SourceMap) Position(bank int, addr uint16) (file string, line int, ok bool) {
+	if p, ok := m.addrs[newLocation(bank, addr)]; ok {
+		return p.file, p.line, true
+	}
+	label := m.syms.Describe(bank, addr)
+	if i := strings.LastIndexByte(label, '+'); i >= 0 {
+		label = label[:i]
+	}
+	p, ok := m.labels[label]
+	return p.file, p.line, ok
+}
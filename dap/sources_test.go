@@ -0,0 +1,114 @@
+package dap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boombuler/goboy2/symbols"
+)
+
+const testSource = `SECTION "Start", ROM0[$0150]
+Start:
+	di            ; disable interrupts
+	ld a, 5
+.loop
+	inc a
+	jr .loop
+	db 1, 2
+SECTION "Far", ROMX
+Far::
+	nop
+	stop
+	ret
+`
+
+const testSymbols = `00:0150 Start
+00:0153 Start.loop
+01:4000 Far
+`
+
+var testROM = map[int]map[uint16][]byte{
+	0: {0x0150: {0xF3, 0x3E, 0x05, 0x3C, 0x18, 0xFD, 0x01, 0x02}},
+	1: {0x4000: {0x00, 0x10, 0x00, 0xC9}},
+}
+
+func readTestROM(bank int, addr uint16) byte {
+	for start, data := range testROM[bank] {
+		if addr >= start && int(addr-start) < len(data) {
+			return data[addr-start]
+		}
+	}
+	return 0xFF
+}
+
+func TestSourceMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goboy2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "main.asm")
+	if err := ioutil.WriteFile(file, []byte(testSource), 0666); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "readme.txt"), []byte("Start:\n\tdi\n"), 0666)
+
+	syms, err := symbols.Read(strings.NewReader(testSymbols))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := ScanSources([]string{dir}, syms, readTestROM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locations := []struct {
+		line, actual int
+		bank         int
+		addr         uint16
+	}{
+		{1, 2, 0, 0x0150},
+		{3, 3, 0, 0x0150},
+		{4, 4, 0, 0x0151},
+		{5, 5, 0, 0x0153},
+		{7, 7, 0, 0x0154},
+		{8, 10, 1, 0x4000},
+		{12, 12, 1, 0x4001},
+		{13, 13, 1, 0x4003},
+	}
+	for _, l := range locations {
+		bank, addr, actual, ok := m.Location(file, l.line)
+		if !ok || bank != l.bank || addr != l.addr || actual != l.actual {
+			t.Errorf("line %d: got %02X:%04X at line %d (%v), expected %02X:%04X at line %d",
+				l.line, bank, addr, actual, ok, l.bank, l.addr, l.actual)
+		}
+	}
+	if _, _, _, ok := m.Location(file, 14); ok {
+		t.Error("found code after the last line")
+	}
+
+	positions := []struct {
+		bank int
+		addr uint16
+		line int
+	}{
+		{0, 0x0150, 3},
+		{0, 0x0153, 6},
+		{0, 0x0154, 7},
+		{0, 0x0157, 5}, // data is mapped to the label in front of it
+		{1, 0x4001, 12},
+		{1, 0x4003, 13},
+	}
+	for _, p := range positions {
+		f, line, ok := m.Position(p.bank, p.addr)
+		if !ok || f != file || line != p.line {
+			t.Errorf("%02X:%04X: got %s:%d (%v), expected line %d", p.bank, p.addr, f, line, ok, p.line)
+		}
+	}
+	if _, _, ok := m.Position(2, 0x4000); ok {
+		t.Error("found a line for an unknown bank")
+	}
+}
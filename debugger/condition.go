@@ -0,0 +1,196 @@
+package debugger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Registers contains the values of the cpu registers.
+type Registers struct {
+	PC, SP                 uint16
+	A, B, C, D, E, F, H, L byte
+}
+
+// Get returns the value of a 8 or 16 bit register by its name.
+func (r Registers) Get(name string) (int, bool) {
+	switch strings.ToUpper(name) {
+	case "A":
+		return int(r.A), true
+	case "B":
+		return int(r.B), true
+	case "C":
+		return int(r.C), true
+	case "D":
+		return int(r.D), true
+	case "E":
+		return int(r.E), true
+	case "F":
+		return int(r.F), true
+	case "H":
+		return int(r.H), true
+	case "L":
+		return int(r.L), true
+	case "AF":
+		return int(r.A)<<8 | int(r.F), true
+	case "BC":
+		return int(r.B)<<8 | int(r.C), true
+	case "DE":
+		return int(r.D)<<8 | int(r.E), true
+	case "HL":
+		return int(r.H)<<8 | int(r.L), true
+	case "SP":
+		return int(r.SP), true
+	case "PC":
+		return int(r.PC), true
+	}
+	return 0, false
+}
+
+// Set changes the value of a 8 or 16 bit register by its name.
+func (r *Registers) Set(name string, value int) bool {
+	hi, lo := byte(value>>8), byte(value)
+	switch strings.ToUpper(name) {
+	case "A":
+		r.A = lo
+	case "B":
+		r.B = lo
+	case "C":
+		r.C = lo
+	case "D":
+		r.D = lo
+	case "E":
+		r.E = lo
+	case "F":
+		r.F = lo & 0xF0
+	case "H":
+		r.H = lo
+	case "L":
+		r.L = lo
+	case "AF":
+		r.A, r.F = hi, lo&0xF0
+	case "BC":
+		r.B, r.C = hi, lo
+	case "DE":
+		r.D, r.E = hi, lo
+	case "HL":
+		r.H, r.L = hi, lo
+	case "SP":
+		r.SP = uint16(value)
+	case "PC":
+		r.PC = uint16(value)
+	default:
+		return false
+	}
+	return true
+}
+
+// operand is a register, a byte of memory or a constant.
+type operand struct {
+	reg   string
+	mem   bool
+	value int
+}
+
+func (o operand) eval(regs Registers, read func(addr uint16) byte) int {
+	switch {
+	case o.reg != "":
+		v, _ := regs.Get(o.reg)
+		return v
+	case o.mem:
+		return int(read(uint16(o.value)))
+	}
+	return o.value
+}
+
+var comparisons = map[string]func(a, b int) bool{
+	"==": func(a, b int) bool { return a == b },
+	"!=": func(a, b int) bool { return a != b },
+	"<":  func(a, b int) bool { return a < b },
+	">":  func(a, b int) bool { return a > b },
+	"<=": func(a, b int) bool { return a <= b },
+	">=": func(a, b int) bool { return a >= b },
+}
+
+type term struct {
+	left, right operand
+	cmp         func(a, b int) bool
+}
+
+// Condition is a list of comparisons which all have to be true. Each comparison
+// compares registers, bytes of memory in brackets and numbers, like
+// "A == 0x10 && [C000] != 0 && HL >= 0xD000".
+type Condition struct {
+	text  string
+	terms []term
+}
+
+func (c *Condition) String() string {
+	return c.text
+}
+
+// Eval returns true if all comparisons are true. A nil condition is always true.
+func (c *Condition) Eval(regs Registers, read func(addr uint16) byte) bool {
+	if c == nil {
+		return true
+	}
+	for _, t := range c.terms {
+		if !t.cmp(t.left.eval(regs, read), t.right.eval(regs, read)) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseNumber parses a number. Numbers are decimal unless they are prefixed
+// with "0x" or "$".
+func ParseNumber(s string) (int, error) {
+	if strings.HasPrefix(s, "$") {
+		s = "0x" + s[1:]
+	}
+	v, err := strconv.ParseInt(s, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return int(v), nil
+}
+
+func parseOperand(s string) (operand, error) {
+	if _, ok := (Registers{}).Get(s); ok {
+		return operand{reg: strings.ToUpper(s)}, nil
+	}
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		addr, err := strconv.ParseUint(strings.TrimPrefix(s[1:len(s)-1], "$"), 16, 16)
+		if err != nil {
+			return operand{}, fmt.Errorf("invalid address %q", s)
+		}
+		return operand{mem: true, value: int(addr)}, nil
+	}
+	v, err := ParseNumber(s)
+	return operand{value: v}, err
+}
+
+// ParseCondition parses a condition like "A == 0x10 && [C000] != 0".
+func ParseCondition(text string) (*Condition, error) {
+	c := &Condition{text: strings.TrimSpace(text)}
+	for _, part := range strings.Split(text, "&&") {
+		fields := strings.Fields(part)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid condition %q, expected: operand comparison operand", strings.TrimSpace(part))
+		}
+		cmp, ok := comparisons[fields[1]]
+		if !ok {
+			return nil, fmt.Errorf("invalid comparison %q", fields[1])
+		}
+		left, err := parseOperand(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		right, err := parseOperand(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		c.terms = append(c.terms, term{left, right, cmp})
+	}
+	return c, nil
+}
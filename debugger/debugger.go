@@ -0,0 +1,514 @@
+// Package debugger stops the emulation at breakpoints and watchpoints and steps
+// through the program.
+//
+// The emulation calls Instruction before the cpu starts an instruction and
+// FrameDone after every frame. While the debugger is stopped these calls block,
+// so the cpu and the memory can be inspected from another goroutine.
+package debugger
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/boombuler/goboy2/cartridge"
+	"github.com/boombuler/goboy2/cpu"
+	"github.com/boombuler/goboy2/mmu"
+	"github.com/boombuler/goboy2/symbols"
+)
+
+// Breakpoint stops the emulation before the instruction at an address is executed.
+type Breakpoint struct {
+	ID      int
+	Address uint16
+	// Bank is the rom bank the address has to be mapped to, or -1 for any bank.
+	// It is only used for addresses in 0000-7FFF.
+	Bank      int
+	Condition *Condition
+	// Label is the label at the address, or "" if there is none.
+	Label string
+}
+
+func (b *Breakpoint) String() string {
+	res := fmt.Sprintf("#%d break at %s", b.ID, formatAddress(b.Address, b.Bank))
+	if b.Label != "" {
+		res += " " + b.Label
+	}
+	if b.Condition != nil {
+		res += " if " + b.Condition.String()
+	}
+	return res
+}
+
+// Access is the kind of memory access a watchpoint stops at.
+type Access int
+
+const (
+	Read Access = 1 << iota
+	Write
+	ReadWrite = Read | Write
+)
+
+func (a Access) String() string {
+	switch a {
+	case Read:
+		return "read"
+	case Write:
+		return "write"
+	}
+	return "read/write"
+}
+
+// Watchpoint stops the emulation after the memory in Start-End (inclusive)
+// was accessed. The emulation stops before the next instruction.
+type Watchpoint struct {
+	ID         int
+	Start, End uint16
+	Access     Access
+	Condition  *Condition
+	// Label is the label at the start address, or "" if there is none.
+	Label string
+}
+
+func (w *Watchpoint) String() string {
+	res := fmt.Sprintf("#%d watch %s %04X", w.ID, w.Access, w.Start)
+	if w.End != w.Start {
+		res += fmt.Sprintf("-%04X", w.End)
+	}
+	if w.Label != "" {
+		res += " " + w.Label
+	}
+	if w.Condition != nil {
+		res += " if " + w.Condition.String()
+	}
+	return res
+}
+
+func formatAddress(addr uint16, bank int) string {
+	if bank < 0 {
+		return fmt.Sprintf("%04X", addr)
+	}
+	return fmt.Sprintf("%02X:%04X", bank, addr)
+}
+
+// Stop describes why the emulation stopped.
+type Stop struct {
+	Reason string
+	PC     uint16
+	Bank   int
+	// Label is the label of PC, or "" if there is none.
+	Label string
+}
+
+// Frame is an entry of the call stack.
+type Frame struct {
+	// Caller is the address of the call instruction or the address the interrupt occurred at.
+	Caller     uint16
+	CallerBank int
+	// SP is the stack pointer after the return address was pushed.
+	SP        uint16
+	Interrupt bool
+}
+
+type mode int
+
+const (
+	modeRun mode = iota
+	modeStep
+	modeOver
+	modeOut
+	modeFrame
+)
+
+// Debugger controls the emulation. The exported methods except Instruction and
+// FrameDone are safe to call from any goroutine.
+type Debugger struct {
+	cpu  *cpu.CPU
+	mem  mmu.MMU
+	cart *cartridge.Cartridge
+	exit <-chan struct{}
+
+	symbols *symbols.Table
+
+	mu          sync.Mutex
+	breakpoints []*Breakpoint
+	watchpoints []*Watchpoint
+	nextID      int
+
+	stops     chan Stop
+	resume    chan func()
+	pauseFlag int32
+	waiting   int32
+	// watchFlag is set while there are watchpoints.
+	watchFlag int32
+
+	// the following fields are only used by the goroutine of the emulation,
+	// or while it is stopped.
+	mode     mode
+	count    int
+	target   uint16
+	targetSP uint16
+	lastOp   byte
+	calls    cpu.CallStack
+	stopped  bool
+	quiet    bool
+	watchHit string
+	watching bool
+}
+
+// New creates a debugger for the given components. The emulation is stopped
+// before the first instruction. Stops are reported on the channel returned by Stops.
+func New(c *cpu.CPU, mem mmu.MMU, cart *cartridge.Cartridge, exit <-chan struct{}) *Debugger {
+	return &Debugger{
+		cpu:       c,
+		mem:       mem,
+		cart:      cart,
+		exit:      exit,
+		stops:     make(chan Stop, 1),
+		resume:    make(chan func()),
+		pauseFlag: 1,
+	}
+}
+
+// SetSymbols sets the labels which are used for addresses. It must be called before Run.
+func (d *Debugger) SetSymbols(t *symbols.Table) {
+	d.symbols = t
+}
+
+// Symbols returns the labels which are used for addresses, or nil.
+func (d *Debugger) Symbols() *symbols.Table {
+	return d.symbols
+}
+
+// Label returns the label of an address or the nearest label before it, or ""
+// if there is none. The emulation has to be stopped.
+func (d *Debugger) Label(addr uint16) string {
+	return d.symbols.Describe(d.ROMBank(addr), addr)
+}
+
+// Name returns the label at an address, or "" if there is none. The emulation has to be stopped.
+func (d *Debugger) Name(addr uint16) string {
+	return d.symbols.Name(d.ROMBank(addr), addr)
+}
+
+// Stops returns the channel on which the debugger reports that the emulation stopped.
+func (d *Debugger) Stops() <-chan Stop {
+	return d.stops
+}
+
+// Pause stops the emulation before the next instruction.
+func (d *Debugger) Pause() {
+	atomic.StoreInt32(&d.pauseFlag, 1)
+}
+
+// AddBreakpoint adds a breakpoint and returns it.
+func (d *Debugger) AddBreakpoint(addr uint16, bank int, cond *Condition) *Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nextID++
+	b := &Breakpoint{ID: d.nextID, Address: addr, Bank: bank, Condition: cond, Label: d.symbols.Name(bank, addr)}
+	d.breakpoints = append(d.breakpoints, b)
+	return b
+}
+
+// AddWatchpoint adds a watchpoint and returns it.
+func (d *Debugger) AddWatchpoint(start, end uint16, access Access, cond *Condition) *Watchpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nextID++
+	w := &Watchpoint{ID: d.nextID, Start: start, End: end, Access: access, Condition: cond, Label: d.symbols.Name(symbols.AnyBank, start)}
+	d.watchpoints = append(d.watchpoints, w)
+	atomic.StoreInt32(&d.watchFlag, 1)
+	return w
+}
+
+// Delete removes the breakpoint or watchpoint with the given id. It returns
+// false if there is none.
+func (d *Debugger) Delete(id int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, b := range d.breakpoints {
+		if b.ID == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return true
+		}
+	}
+	for i, w := range d.watchpoints {
+		if w.ID == id {
+			d.watchpoints = append(d.watchpoints[:i], d.watchpoints[i+1:]...)
+			if len(d.watchpoints) == 0 {
+				atomic.StoreInt32(&d.watchFlag, 0)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Points returns the descriptions of all breakpoints and watchpoints ordered by their id.
+func (d *Debugger) Points() []fmt.Stringer {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var res []fmt.Stringer
+	ids := make(map[fmt.Stringer]int)
+	for _, b := range d.breakpoints {
+		res = append(res, b)
+		ids[b] = b.ID
+	}
+	for _, w := range d.watchpoints {
+		res = append(res, w)
+		ids[w] = w.ID
+	}
+	sort.Slice(res, func(i, j int) bool { return ids[res[i]] < ids[res[j]] })
+	return res
+}
+
+// Registers returns the current values of the cpu registers. The emulation has to be stopped.
+func (d *Debugger) Registers() Registers {
+	var r Registers
+	r.PC, r.SP, r.A, r.B, r.C, r.D, r.E, r.F, r.H, r.L = d.cpu.GetRegisterValues()
+	return r
+}
+
+// SetRegisters changes the cpu registers. The emulation has to be stopped.
+func (d *Debugger) SetRegisters(r Registers) {
+	d.cpu.SetRegisterValues(r.PC, r.SP, r.A, r.B, r.C, r.D, r.E, r.F, r.H, r.L)
+}
+
+// ReadMemory reads a byte without notifying the watchers of the memory. The emulation has to be stopped.
+func (d *Debugger) ReadMemory(addr uint16) byte {
+	return d.mem.Peek(addr)
+}
+
+// WriteMemory writes a byte without triggering watchpoints. The emulation has to be stopped.
+func (d *Debugger) WriteMemory(addr uint16, value byte) {
+	d.quiet = true
+	defer func() { d.quiet = false }()
+	d.mem.Write(addr, value)
+}
+
+// ROMBank returns the rom bank which is mapped at the given address.
+func (d *Debugger) ROMBank(addr uint16) int {
+	if addr >= 0x8000 {
+		return -1
+	}
+	return d.cart.ROMBank(addr)
+}
+
+// CallStack returns the calls which did not return yet, the innermost call
+// first. The emulation has to be stopped.
+func (d *Debugger) CallStack() []Frame {
+	calls := d.calls.Calls()
+	res := make([]Frame, len(calls))
+	for i, c := range calls {
+		res[len(res)-1-i] = Frame{Caller: c.Caller, CallerBank: c.CallerBank, SP: c.SP, Interrupt: c.Interrupt}
+	}
+	return res
+}
+
+// Continue resumes the emulation until a breakpoint or watchpoint is hit.
+func (d *Debugger) Continue() {
+	d.run(func() { d.mode = modeRun })
+}
+
+// Step executes n instructions.
+func (d *Debugger) Step(n int) {
+	d.run(func() {
+		d.mode = modeStep
+		d.count = n
+	})
+}
+
+// StepOver executes the next instruction. Calls are executed until they returned.
+func (d *Debugger) StepOver() {
+	d.run(func() {
+		regs := d.Registers()
+		if n := callLength(d.ReadMemory(regs.PC)); n > 0 {
+			d.mode, d.target, d.targetSP = modeOver, regs.PC+n, regs.SP
+		} else {
+			d.mode, d.count = modeStep, 1
+		}
+	})
+}
+
+// StepOut runs until the current function returned.
+func (d *Debugger) StepOut() {
+	d.run(func() {
+		d.mode = modeOut
+		d.targetSP = d.Registers().SP
+	})
+}
+
+// RunFrames runs until n frames are completed.
+func (d *Debugger) RunFrames(n int) {
+	d.run(func() {
+		d.mode = modeFrame
+		d.count = n
+	})
+}
+
+// Stopped returns true while the emulation is stopped.
+func (d *Debugger) Stopped() bool {
+	return atomic.LoadInt32(&d.waiting) != 0
+}
+
+// run resumes the emulation after fn prepared the next stop on the goroutine
+// of the emulation. It does nothing if the emulation is not stopped.
+func (d *Debugger) run(fn func()) {
+	if !d.Stopped() {
+		return
+	}
+	select {
+	case d.resume <- fn:
+	case _, _ = <-d.exit:
+	}
+}
+
+// callLength returns the length of a CALL or RST instruction, or 0 for other opcodes.
+func callLength(op byte) uint16 {
+	switch {
+	case op == 0xCD || op&0xE7 == 0xC4:
+		return 3
+	case op&0xC7 == 0xC7:
+		return 1
+	}
+	return 0
+}
+
+// isReturn checks if the opcode is one of the RET instructions.
+func isReturn(op byte) bool {
+	return op == 0xC9 || op == 0xD9 || op&0xE7 == 0xC0
+}
+
+// Instruction is called by the emulation before the cpu starts an instruction.
+func (d *Debugger) Instruction() {
+	regs := d.Registers()
+	var reason string
+	switch {
+	case atomic.SwapInt32(&d.pauseFlag, 0) != 0:
+		reason = "paused"
+	case d.watchHit != "":
+		reason = d.watchHit
+	case d.mode == modeStep:
+		if d.count--; d.count <= 0 {
+			reason = "step"
+		}
+	case d.mode == modeOver:
+		if regs.PC == d.target && regs.SP >= d.targetSP {
+			reason = "step"
+		}
+	case d.mode == modeOut:
+		if isReturn(d.lastOp) && regs.SP > d.targetSP {
+			reason = "returned"
+		}
+	}
+	if reason == "" {
+		reason = d.checkBreakpoints(regs)
+	}
+	d.lastOp = d.ReadMemory(regs.PC)
+	if reason != "" {
+		d.stop(Stop{Reason: reason, PC: regs.PC, Bank: d.ROMBank(regs.PC), Label: d.Label(regs.PC)})
+	}
+	d.updateWatcher()
+}
+
+// updateWatcher watches the memory while there are watchpoints. The watchers of
+// the memory are only changed on the goroutine of the emulation.
+func (d *Debugger) updateWatcher() {
+	want := atomic.LoadInt32(&d.watchFlag) != 0
+	if want == d.watching {
+		return
+	}
+	if want {
+		d.mem.AddWatcher(d)
+	} else {
+		d.mem.RemoveWatcher(d)
+	}
+	d.watching = want
+}
+
+// HookInstruction has to be called by the OnInstruction hook of the cpu to
+// track the call stack.
+func (d *Debugger) HookInstruction(i *cpu.Instruction) {
+	d.calls.Instruction(i)
+}
+
+// HookInterrupt has to be called by the OnInterrupt hook of the cpu to track
+// the call stack.
+func (d *Debugger) HookInterrupt(i *cpu.Interrupt) {
+	d.calls.Interrupt(i)
+}
+
+// FrameDone is called by the emulation after every frame.
+func (d *Debugger) FrameDone() {
+	if d.mode == modeFrame {
+		if d.count--; d.count <= 0 {
+			d.mode = modeStep
+			d.count = 1
+		}
+	}
+}
+
+func (d *Debugger) checkBreakpoints(regs Registers) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, b := range d.breakpoints {
+		if b.Address != regs.PC {
+			continue
+		}
+		if b.Bank >= 0 && b.Address < 0x8000 && d.cart.ROMBank(b.Address) != b.Bank {
+			continue
+		}
+		if b.Condition.Eval(regs, d.ReadMemory) {
+			return fmt.Sprintf("breakpoint #%d", b.ID)
+		}
+	}
+	return ""
+}
+
+// stop blocks the emulation until it is resumed or the emulation exits.
+func (d *Debugger) stop(s Stop) {
+	d.stopped = true
+	d.watchHit = ""
+	d.mode = modeRun
+	atomic.StoreInt32(&d.waiting, 1)
+	select {
+	case d.stops <- s:
+	default:
+	}
+	select {
+	case fn := <-d.resume:
+		atomic.StoreInt32(&d.waiting, 0)
+		fn()
+		d.stopped = false
+	case _, _ = <-d.exit:
+	}
+}
+
+// WatchRead implements mmu.Watcher.
+func (d *Debugger) WatchRead(addr uint16, value byte) {
+	d.watch(Read, addr, value)
+}
+
+// WatchWrite implements mmu.Watcher.
+func (d *Debugger) WatchWrite(addr uint16, value byte) {
+	d.watch(Write, addr, value)
+}
+
+func (d *Debugger) watch(access Access, addr uint16, value byte) {
+	if d.quiet || d.stopped || d.watchHit != "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, w := range d.watchpoints {
+		if w.Access&access == 0 || addr < w.Start || addr > w.End {
+			continue
+		}
+		if w.Condition.Eval(d.Registers(), d.ReadMemory) {
+			d.watchHit = fmt.Sprintf("watchpoint #%d: %s %04X = %02X", w.ID, access, addr, value)
+			return
+		}
+	}
+}
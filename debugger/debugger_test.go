@@ -0,0 +1,212 @@
+package debugger
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/boombuler/goboy2/consts"
+	"github.com/boombuler/goboy2/cpu/cputest"
+	"github.com/boombuler/goboy2/mmu"
+	"github.com/boombuler/goboy2/symbols"
+)
+
+var program = map[uint16][]byte{
+	0x0100: {
+		0x3E, 0x05, // LD A, 5
+		0xEA, 0x00, 0xC0, // LD (C000), A
+		0xCD, 0x00, 0x02, // CALL 0200
+		0x18, 0xF6, // JR 0100
+	},
+	0x0200: {
+		0x3C, // INC A
+		0xC9, // RET
+	},
+}
+
+// newTestDebugger runs the program until the returned function is called.
+// syms may be nil.
+func newTestDebugger(t *testing.T, syms *symbols.Table) (*Debugger, func()) {
+	m := cputest.New(t, program)
+	exit := make(chan struct{})
+	d := New(m.CPU, m.MMU, m.Cart, exit)
+	m.CPU.OnInstruction, m.CPU.OnInterrupt = d.HookInstruction, d.HookInterrupt
+	if syms != nil {
+		d.SetSymbols(syms)
+	}
+	return d, m.Run(exit, d.Instruction)
+}
+
+func expectStop(t *testing.T, d *Debugger, pc uint16, reason string) {
+	t.Helper()
+	select {
+	case s := <-d.Stops():
+		if s.PC != pc || s.Reason != reason {
+			t.Fatalf("Expected to stop at %04X (%s) but stopped at %04X (%s)", pc, reason, s.PC, s.Reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected to stop at %04X (%s)", pc, reason)
+	}
+}
+
+func TestStepping(t *testing.T) {
+	d, stop := newTestDebugger(t, nil)
+	defer stop()
+
+	expectStop(t, d, 0x0100, "paused")
+	d.Step(2)
+	expectStop(t, d, 0x0105, "step")
+	d.StepOver()
+	expectStop(t, d, 0x0108, "step")
+	if a := d.Registers().A; a != 6 {
+		t.Errorf("Expected the call to be executed but A is %d", a)
+	}
+	d.Step(2)
+	expectStop(t, d, 0x0102, "step")
+	d.Step(2)
+	expectStop(t, d, 0x0200, "step")
+	d.StepOut()
+	expectStop(t, d, 0x0108, "returned")
+}
+
+func TestBreakpoints(t *testing.T) {
+	d, stop := newTestDebugger(t, nil)
+	defer stop()
+	expectStop(t, d, 0x0100, "paused")
+
+	cond, err := ParseCondition("A == 6 && [C000] == 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := d.AddBreakpoint(0x0201, -1, cond)
+	d.AddBreakpoint(0x0200, 1, nil)
+	d.Continue()
+	expectStop(t, d, 0x0201, "breakpoint #1")
+	if a := d.Registers().A; a != 6 {
+		t.Errorf("Expected the condition to be true but A is %d", a)
+	}
+	d.Delete(b.ID)
+
+	w := d.AddWatchpoint(0xC000, 0xC001, Write, nil)
+	d.Continue()
+	expectStop(t, d, 0x0105, "watchpoint #3: write C000 = 05")
+	d.Delete(w.ID)
+
+	d.AddBreakpoint(0x0200, 0, nil)
+	d.Continue()
+	expectStop(t, d, 0x0200, "breakpoint #4")
+}
+
+func TestWatchpointsWhileRunning(t *testing.T) {
+	d, stop := newTestDebugger(t, nil)
+	defer stop()
+	expectStop(t, d, 0x0100, "paused")
+
+	d.Continue()
+	for i := 0; i < 100; i++ {
+		w := d.AddWatchpoint(0xD000, 0xD000, ReadWrite, nil)
+		d.Delete(w.ID)
+	}
+	d.AddWatchpoint(0xC000, 0xC000, Write, nil)
+	expectStop(t, d, 0x0105, "watchpoint #101: write C000 = 05")
+}
+
+func TestSymbols(t *testing.T) {
+	syms, err := symbols.Read(bytes.NewBufferString("00:0100 Main\n00:0200 Sub\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, stop := newTestDebugger(t, syms)
+	defer stop()
+	expectStop(t, d, 0x0100, "paused")
+
+	addr, bank, err := d.parseBankAddress("Sub")
+	if err != nil || addr != 0x0200 || bank != 0 {
+		t.Fatalf("Expected Sub to be 00:0200 but got %02X:%04X (%v)", bank, addr, err)
+	}
+	if b := d.AddBreakpoint(addr, bank, nil); b.Label != "Sub" {
+		t.Errorf("Expected the breakpoint to be labeled Sub but got %q", b.Label)
+	}
+	d.Continue()
+	select {
+	case s := <-d.Stops():
+		if s.Label != "Sub" {
+			t.Errorf("Expected to stop at Sub but got %q", s.Label)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected to stop at Sub")
+	}
+	if l := d.Label(0x0105); l != "Main+5" {
+		t.Errorf("Expected Main+5 but got %q", l)
+	}
+}
+
+func TestCallStack(t *testing.T) {
+	d, stop := newTestDebugger(t, nil)
+	defer stop()
+	expectStop(t, d, 0x0100, "paused")
+
+	d.Step(3)
+	expectStop(t, d, 0x0200, "step")
+	frames := d.CallStack()
+	if len(frames) != 1 || frames[0].Caller != 0x0105 || frames[0].Interrupt {
+		t.Fatalf("Expected a call from 0105 but got %+v", frames)
+	}
+	d.Step(2)
+	expectStop(t, d, 0x0108, "step")
+	if frames := d.CallStack(); len(frames) != 0 {
+		t.Errorf("Expected the call to be returned but got %+v", frames)
+	}
+}
+
+func TestCondition(t *testing.T) {
+	regs := Registers{A: 0x10, H: 0xC0, L: 0x01}
+	read := func(addr uint16) byte { return byte(addr) }
+	tests := map[string]bool{
+		"A == 0x10":                true,
+		"a == 16 && HL >= $C000":   true,
+		"[C002] == 2 && A != 0x10": false,
+		"HL < [C0FF]":              false,
+	}
+	for text, exp := range tests {
+		c, err := ParseCondition(text)
+		if err != nil {
+			t.Errorf("%s: %v", text, err)
+		} else if got := c.Eval(regs, read); got != exp {
+			t.Errorf("%s: expected %v but got %v", text, exp, got)
+		}
+	}
+	for _, text := range []string{"A", "A = 1", "X == 1", "A == 1 &&"} {
+		if _, err := ParseCondition(text); err == nil {
+			t.Errorf("Expected %q to be invalid", text)
+		}
+	}
+}
+
+func TestWatchpointOnInterruptFlags(t *testing.T) {
+	m := cputest.New(t, map[uint16][]byte{
+		0x0100: {
+			0xFB,       // EI
+			0x00,       // NOP
+			0x00,       // NOP
+			0x3E, 0x04, // LD A, 4
+			0xE0, 0x0F, // LDH (0F), A
+			0x18, 0xFE, // JR 0107
+		},
+	})
+	exit := make(chan struct{})
+	d := New(m.CPU, m.MMU, m.Cart, exit)
+	stop := m.Run(exit, func() {
+		d.Instruction()
+		// like the interrupt requests of the timer or the ppu.
+		m.MMU.RequestInterrupt(mmu.IRQTimer)
+	})
+	defer stop()
+	expectStop(t, d, 0x0100, "paused")
+
+	// the cpu checks the interrupt flags before every instruction.
+	d.AddWatchpoint(consts.AddrIRQFlags, consts.AddrIRQFlags, ReadWrite, nil)
+	d.AddWatchpoint(consts.AddrIRQEnabled, consts.AddrIRQEnabled, Read, nil)
+	d.Continue()
+	expectStop(t, d, 0x0107, "watchpoint #1: write FF0F = 04")
+}
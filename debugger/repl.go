@@ -0,0 +1,362 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/boombuler/goboy2/disasm"
+)
+
+const replHelp = `commands:
+  c, continue                      run until a breakpoint or watchpoint is hit
+  s, step [n]                      execute n instructions (default 1)
+  n, next                          execute the next instruction, calls are executed until they returned
+  finish                           run until the current function returned
+  frame [n]                        run until the end of n frames (default 1)
+  pause                            stop the running emulation
+  b, break [bank:]addr [if cond]   add a breakpoint, the bank is only checked for addresses in 0000-7FFF
+  watch [r|w|rw] addr[-end] [if cond]
+                                   add a watchpoint for reads, writes or both (default w)
+  d, delete id                     delete a breakpoint or watchpoint
+  i, info                          list the breakpoints and watchpoints
+  bt, backtrace                    print the call stack
+  r, regs                          print the registers
+  set reg value                    change a register
+  x addr [n]                       print n bytes of memory (default 16)
+  write addr value                 change a byte of memory
+  q, quit                          leave the debugger and continue the emulation
+conditions compare registers, bytes of memory and numbers, like: A == 0x10 && [C000] != 0
+addresses are hexadecimal or labels of the symbol file, other numbers are decimal unless they are
+prefixed with 0x or $`
+
+// bytesPerLine is the number of bytes printed per line by the x command.
+const bytesPerLine = 16
+
+// ParseAddress parses a hexadecimal address, optionally prefixed with "$" or "0x".
+func ParseAddress(s string) (uint16, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(s), "0x"), "$")
+	v, err := strconv.ParseUint(s, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q", s)
+	}
+	return uint16(v), nil
+}
+
+// parseBankAddress parses an address with an optional hexadecimal bank like
+// "01:4000", or a label. The bank of labels is only used for rom addresses.
+func (d *Debugger) parseBankAddress(s string) (uint16, int, error) {
+	if sym, ok := d.symbols.Lookup(s); ok {
+		if sym.Address >= 0x8000 {
+			return sym.Address, -1, nil
+		}
+		return sym.Address, sym.Bank, nil
+	}
+	bank := -1
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		v, err := strconv.ParseUint(s[:i], 16, 16)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid bank %q", s[:i])
+		}
+		bank = int(v)
+		s = s[i+1:]
+	}
+	addr, err := ParseAddress(s)
+	return addr, bank, err
+}
+
+// parseAddress parses a hexadecimal address or a label.
+func (d *Debugger) parseAddress(s string) (uint16, error) {
+	if sym, ok := d.symbols.Lookup(s); ok {
+		return sym.Address, nil
+	}
+	return ParseAddress(s)
+}
+
+// splitCondition splits "args... if condition" into the arguments and the condition.
+func splitCondition(args []string) ([]string, *Condition, error) {
+	for i, a := range args {
+		if a == "if" {
+			cond, err := ParseCondition(strings.Join(args[i+1:], " "))
+			return args[:i], cond, err
+		}
+	}
+	return args, nil, nil
+}
+
+// countArg returns the optional count argument.
+func countArg(args []string, def int) (int, error) {
+	if len(args) == 0 {
+		return def, nil
+	}
+	n, err := ParseNumber(args[0])
+	if err == nil && n < 1 {
+		err = fmt.Errorf("the count must be positive")
+	}
+	return n, err
+}
+
+// RunREPL reads debugger commands from in until it is closed, the quit command
+// is entered or the emulation exits.
+func (d *Debugger) RunREPL(in io.Reader, out io.Writer) {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	fmt.Fprintln(out, "debugger, type help for a list of commands")
+	for {
+		select {
+		case _, _ = <-d.exit:
+			return
+		case s := <-d.stops:
+			loc := formatAddress(s.PC, s.Bank)
+			if s.Label != "" {
+				loc += " " + s.Label
+			}
+			fmt.Fprintf(out, "stopped at %s: %s\n", loc, s.Reason)
+			fmt.Fprintf(out, "  %s\n", disasm.Decode(d.ReadMemory, s.PC).Format(d.Name))
+			d.printRegisters(out)
+			fmt.Fprint(out, "> ")
+		case line, ok := <-lines:
+			if !ok {
+				d.quit()
+				return
+			}
+			args := strings.Fields(line)
+			if len(args) > 0 && (args[0] == "q" || args[0] == "quit") {
+				d.quit()
+				return
+			}
+			resumed := false
+			if len(args) > 0 {
+				var err error
+				if resumed, err = d.exec(args, out); err != nil {
+					fmt.Fprintln(out, "error:", err)
+				}
+			}
+			if !resumed && d.Stopped() {
+				// after resuming the prompt is printed with the next stop.
+				fmt.Fprint(out, "> ")
+			}
+		}
+	}
+}
+
+// Exec executes a debugger command like RunREPL and writes its output to out.
+func (d *Debugger) Exec(line string, out io.Writer) error {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return nil
+	}
+	_, err := d.exec(args, out)
+	return err
+}
+
+// quit removes all breakpoints and watchpoints and continues the emulation.
+func (d *Debugger) quit() {
+	for _, p := range d.Points() {
+		switch p := p.(type) {
+		case *Breakpoint:
+			d.Delete(p.ID)
+		case *Watchpoint:
+			d.Delete(p.ID)
+		}
+	}
+	d.Continue()
+}
+
+// exec executes a command. It returns true if the emulation was resumed.
+func (d *Debugger) exec(args []string, out io.Writer) (bool, error) {
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "help", "h":
+		fmt.Fprintln(out, replHelp)
+		return false, nil
+	case "pause":
+		d.Pause()
+		return false, nil
+	case "b", "break":
+		args, cond, err := splitCondition(args)
+		if err != nil {
+			return false, err
+		}
+		if len(args) != 1 {
+			return false, fmt.Errorf("usage: break [bank:]addr [if cond]")
+		}
+		addr, bank, err := d.parseBankAddress(args[0])
+		if err != nil {
+			return false, err
+		}
+		fmt.Fprintln(out, d.AddBreakpoint(addr, bank, cond))
+		return false, nil
+	case "watch":
+		return false, d.execWatch(args, out)
+	case "d", "delete":
+		if len(args) != 1 {
+			return false, fmt.Errorf("usage: delete id")
+		}
+		id, err := ParseNumber(strings.TrimPrefix(args[0], "#"))
+		if err != nil {
+			return false, err
+		}
+		if !d.Delete(id) {
+			return false, fmt.Errorf("there is no breakpoint or watchpoint #%d", id)
+		}
+		return false, nil
+	case "i", "info":
+		for _, p := range d.Points() {
+			fmt.Fprintln(out, p)
+		}
+		return false, nil
+	}
+
+	if !d.Stopped() {
+		return false, fmt.Errorf("the emulation is running, use pause to stop it")
+	}
+	switch cmd {
+	case "c", "continue":
+		d.Continue()
+		return true, nil
+	case "s", "step":
+		n, err := countArg(args, 1)
+		if err != nil {
+			return false, err
+		}
+		d.Step(n)
+		return true, nil
+	case "n", "next":
+		d.StepOver()
+		return true, nil
+	case "finish":
+		d.StepOut()
+		return true, nil
+	case "frame":
+		n, err := countArg(args, 1)
+		if err != nil {
+			return false, err
+		}
+		d.RunFrames(n)
+		return true, nil
+	case "r", "regs":
+		d.printRegisters(out)
+	case "bt", "backtrace":
+		d.printCallStack(out)
+	case "set":
+		if len(args) != 2 {
+			return false, fmt.Errorf("usage: set reg value")
+		}
+		v, err := ParseNumber(args[1])
+		if err != nil {
+			return false, err
+		}
+		regs := d.Registers()
+		if !regs.Set(args[0], v) {
+			return false, fmt.Errorf("unknown register %q", args[0])
+		}
+		d.SetRegisters(regs)
+	case "x":
+		if len(args) < 1 {
+			return false, fmt.Errorf("usage: x addr [n]")
+		}
+		addr, err := d.parseAddress(args[0])
+		if err != nil {
+			return false, err
+		}
+		n, err := countArg(args[1:], bytesPerLine)
+		if err != nil {
+			return false, err
+		}
+		d.printMemory(out, addr, n)
+	case "write":
+		if len(args) != 2 {
+			return false, fmt.Errorf("usage: write addr value")
+		}
+		addr, err := d.parseAddress(args[0])
+		if err != nil {
+			return false, err
+		}
+		v, err := ParseNumber(args[1])
+		if err != nil {
+			return false, err
+		}
+		d.WriteMemory(addr, byte(v))
+	default:
+		return false, fmt.Errorf("unknown command %q, type help for a list of commands", cmd)
+	}
+	return false, nil
+}
+
+func (d *Debugger) execWatch(args []string, out io.Writer) error {
+	args, cond, err := splitCondition(args)
+	if err != nil {
+		return err
+	}
+	access := Write
+	if len(args) == 2 {
+		switch args[0] {
+		case "r":
+			access = Read
+		case "w":
+			access = Write
+		case "rw":
+			access = ReadWrite
+		default:
+			return fmt.Errorf("invalid access %q, expected r, w or rw", args[0])
+		}
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: watch [r|w|rw] addr[-end] [if cond]")
+	}
+	rng := strings.SplitN(args[0], "-", 2)
+	start, err := d.parseAddress(rng[0])
+	if err != nil {
+		return err
+	}
+	end := start
+	if len(rng) == 2 {
+		if end, err = d.parseAddress(rng[1]); err != nil {
+			return err
+		}
+		if end < start {
+			return fmt.Errorf("the end of the range is before the start")
+		}
+	}
+	fmt.Fprintln(out, d.AddWatchpoint(start, end, access, cond))
+	return nil
+}
+
+func (d *Debugger) printRegisters(out io.Writer) {
+	r := d.Registers()
+	fmt.Fprintf(out, "PC: %04X  SP: %04X  AF: %02X%02X  BC: %02X%02X  DE: %02X%02X  HL: %02X%02X  IME: %v\n",
+		r.PC, r.SP, r.A, r.F, r.B, r.C, r.D, r.E, r.H, r.L, d.cpu.IME())
+}
+
+func (d *Debugger) printMemory(out io.Writer, addr uint16, n int) {
+	for i := 0; i < n; i += bytesPerLine {
+		line := fmt.Sprintf("%04X:", addr+uint16(i))
+		for j := i; j < i+bytesPerLine && j < n; j++ {
+			line += fmt.Sprintf(" %02X", d.ReadMemory(addr+uint16(j)))
+		}
+		fmt.Fprintln(out, line)
+	}
+}
+
+func (d *Debugger) printCallStack(out io.Writer) {
+	pc := d.Registers().PC
+	fmt.Fprintln(out, strings.TrimSpace(fmt.Sprintf("#0 %s %s", formatAddress(pc, d.ROMBank(pc)), d.Label(pc))))
+	for i, f := range d.CallStack() {
+		line := strings.TrimSpace(fmt.Sprintf("#%d %s %s", i+1, formatAddress(f.Caller, f.CallerBank), d.symbols.Describe(f.CallerBank, f.Caller)))
+		if f.Interrupt {
+			line += " (interrupt)"
+		}
+		fmt.Fprintln(out, line)
+	}
+}
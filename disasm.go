@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/boombuler/goboy2/disasm"
+	"github.com/boombuler/goboy2/romfile"
+	"github.com/boombuler/goboy2/symbols"
+)
+
+// romBankSize is the size of a rom bank in the rom file.
+const romBankSize = 0x4000
+
+// runDisasm prints the instructions of a rom file. It returns the exit code.
+func runDisasm(args []string) int {
+	fs := flag.NewFlagSet("disasm", flag.ExitOnError)
+	bank := fs.Int("bank", 0, "rom `bank` which is mapped at 4000-7FFF")
+	from := fs.String("from", "", "hexadecimal `address` or label of the first instruction (default 0100, or 4000 for banks > 0)")
+	count := fs.Int("count", 32, "number of instructions")
+	entry := fs.String("entry", "", "`name` of the rom within a zip archive with multiple roms")
+	symFile := fs.String("sym", "", "load the labels from the RGBDS symbol `file` instead of the symbol file next to the rom")
+	fs.Usage = func() {
+		log.Println("Usage:")
+		log.Println(os.Args[0], "disasm [-bank N] [-from X] [-count n] [-entry name] [-sym file] (romfile)")
+		fs.PrintDefaults()
+	}
+	// allow the flags after the rom file.
+	var files []string
+	for fs.Parse(args); fs.NArg() > 0; fs.Parse(args) {
+		files = append(files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(files) != 1 || *bank < 0 || *count < 1 {
+		fs.Usage()
+		return 2
+	}
+
+	rf, err := romfile.Open(files[0], *entry)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	if *symFile == "" {
+		*symFile = symbols.Find(rf.Name)
+	}
+	var syms *symbols.Table
+	if *symFile != "" {
+		if syms, err = symbols.Load(*symFile); err != nil {
+			log.Println(err)
+			return 1
+		}
+	}
+
+	start := uint16(0x0100)
+	if *bank > 0 {
+		start = 0x4000
+	}
+	if sym, ok := syms.Lookup(*from); ok && sym.Address < 0x8000 {
+		start = sym.Address
+		if start >= romBankSize {
+			*bank = sym.Bank
+		}
+	} else if *from != "" {
+		v, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(*from), "0x"), "$"), 16, 16)
+		if err != nil || v >= 0x8000 {
+			log.Printf("invalid address %q, expected a rom address in 0000-7FFF or a label", *from)
+			return 2
+		}
+		start = uint16(v)
+	}
+
+	if err := printDisasm(os.Stdout, rf.Data, syms, *bank, start, *count); err != nil {
+		log.Println(err)
+		return 1
+	}
+	return 0
+}
+
+// romBankOf returns the bank of the rom file which is read at addr while bank
+// is mapped at 4000-7FFF.
+func romBankOf(addr uint16, bank int) int {
+	if addr < romBankSize {
+		return 0
+	}
+	if bank == 0 {
+		// bank 0 can not be mapped to 4000-7FFF by most mappers.
+		return 1
+	}
+	return bank
+}
+
+// romByte returns the byte at addr while bank is mapped at 4000-7FFF.
+func romByte(rom []byte, bank int, addr uint16) byte {
+	if addr >= 0x8000 {
+		return 0xFF
+	}
+	offset := romBankOf(addr, bank)*romBankSize + int(addr%romBankSize)
+	if offset >= len(rom) {
+		return 0xFF
+	}
+	return rom[offset]
+}
+
+// printDisasm prints count instructions starting at addr. Addresses are
+// replaced by the labels of syms, which may be nil.
+func printDisasm(out io.Writer, rom []byte, syms *symbols.Table, bank int, addr uint16, count int) error {
+	read := func(a uint16) byte {
+		return romByte(rom, bank, a)
+	}
+	if romBankOf(0x4000, bank)*romBankSize >= len(rom) && addr >= 0x4000 {
+		return fmt.Errorf("the rom has only %d banks", len(rom)/romBankSize)
+	}
+
+	names := syms.Names(func(a uint16) int { return romBankOf(a, bank) })
+	for i := 0; i < count && addr < 0x8000; i++ {
+		if name := names(addr); name != "" {
+			fmt.Fprintf(out, "%s:\n", name)
+		}
+		inst := disasm.Decode(read, addr)
+		hex := fmt.Sprintf("% X", inst.Bytes)
+		line := fmt.Sprintf("%02X:%04X  %-9s %s", romBankOf(addr, bank), addr, hex, inst.Format(names))
+		switch {
+		case inst.HasTarget && inst.Target < 0x8000:
+			line = fmt.Sprintf("%-36s ; -> %02X:%04X", line, romBankOf(inst.Target, bank), inst.Target)
+		case inst.HasTarget:
+			line = fmt.Sprintf("%-36s ; -> %04X", line, inst.Target)
+		}
+		fmt.Fprintln(out, line)
+		addr += uint16(inst.Len())
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Package disasm decodes the instructions of the gameboy cpu.
+//
+// The mnemonics are the labels of the opcode table of the cpu, the operands
+// are replaced by their values.
+package disasm
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/boombuler/goboy2/cpu"
+)
+
+// prefixCB is the prefix of the extended opcodes.
+const prefixCB = 0xCB
+
+// opSTOP is the opcode of STOP, which is followed by a padding byte.
+const opSTOP = 0x10
+
+// Instruction is a decoded instruction.
+type Instruction struct {
+	Address uint16
+	Bytes   []byte
+	// Mnemonic is the name of the instruction, like "LD".
+	Mnemonic string
+	// Operands contains the operands with their values, like "BC, $C000".
+	Operands string
+	// Target is the address a jump, call or restart continues at. HasTarget
+	// is false if the instruction has no fixed target, like "JP (HL)".
+	Target    uint16
+	HasTarget bool
+	// Call is true for CALL and RST instructions.
+	Call bool
+
+	// addr is the address of a memory or jump operand, addrText its text in Operands.
+	addr     uint16
+	addrText string
+}
+
+// Len returns the length of the instruction in bytes.
+func (i Instruction) Len() int {
+	return len(i.Bytes)
+}
+
+func (i Instruction) String() string {
+	if i.Operands == "" {
+		return i.Mnemonic
+	}
+	return i.Mnemonic + " " + i.Operands
+}
+
+// Format returns the instruction like String, but an address in the operands is
+// replaced by its name. names returns "" for addresses without a name.
+func (i Instruction) Format(names func(addr uint16) string) string {
+	if i.addrText != "" {
+		if name := names(i.addr); name != "" {
+			return i.Mnemonic + " " + strings.Replace(i.Operands, i.addrText, name, 1)
+		}
+	}
+	return i.String()
+}
+
+// placeholder matches the operands in a label which are read from the
+// instruction stream: n is a byte, nn a word and d a signed byte.
+var placeholder = regexp.MustCompile(`\b(nn|n|d)\b`)
+
+// Decode decodes the instruction at addr. read returns the byte at an address.
+func Decode(read func(addr uint16) byte, addr uint16) Instruction {
+	res := Instruction{Address: addr}
+	op := read(addr)
+	res.Bytes = append(res.Bytes, op)
+	label := cpu.Label(op)
+	if op == prefixCB {
+		ext := read(addr + 1)
+		res.Bytes = append(res.Bytes, ext)
+		label = cpu.ExtendedLabel(ext)
+	}
+	if op == opSTOP {
+		res.Bytes = append(res.Bytes, read(addr+1))
+	}
+
+	mnemonic, ops := label, ""
+	if i := strings.IndexByte(label, ' '); i >= 0 {
+		mnemonic, ops = label[:i], label[i+1:]
+	}
+	if label == "INVALID OPCODE" {
+		mnemonic, ops = "DB", fmt.Sprintf("$%02X", op)
+	}
+	res.Mnemonic = mnemonic
+
+	res.Operands = placeholder.ReplaceAllStringFunc(ops, func(p string) string {
+		value := int(read(addr + uint16(len(res.Bytes))))
+		res.Bytes = append(res.Bytes, byte(value))
+		switch {
+		case p == "nn":
+			value |= int(read(addr+uint16(len(res.Bytes)))) << 8
+			res.Bytes = append(res.Bytes, byte(value>>8))
+			if mnemonic == "JP" || mnemonic == "CALL" {
+				res.Target, res.HasTarget = uint16(value), true
+			}
+			res.addr, res.addrText = uint16(value), fmt.Sprintf("$%04X", value)
+			return res.addrText
+		case p == "d":
+			if v := int8(value); v < 0 {
+				return fmt.Sprintf("-$%02X", -int(v))
+			}
+			return fmt.Sprintf("$%02X", value)
+		case mnemonic == "JR":
+			// the offset is relative to the following instruction.
+			res.Target, res.HasTarget = addr+2+uint16(int8(value)), true
+			res.addr, res.addrText = res.Target, fmt.Sprintf("$%04X", res.Target)
+			return res.addrText
+		case mnemonic == "LDH":
+			res.addr, res.addrText = 0xFF00|uint16(value), fmt.Sprintf("$%02X", value)
+			return res.addrText
+		}
+		return fmt.Sprintf("$%02X", value)
+	})
+
+	switch mnemonic {
+	case "RST":
+		v, _ := strconv.ParseUint(strings.TrimSpace(ops), 16, 8)
+		res.Target, res.HasTarget, res.Call = uint16(v), true, true
+		res.Operands = fmt.Sprintf("$%02X", v)
+		res.addr, res.addrText = res.Target, res.Operands
+	case "CALL":
+		res.Call = true
+	}
+	return res
+}
+
+// DecodeBytes decodes the instruction at the start of data, which is located at
+// addr. Missing bytes at the end of data are read as 0.
+func DecodeBytes(data []byte, addr uint16) Instruction {
+	return Decode(func(a uint16) byte {
+		if i := int(a - addr); i < len(data) {
+			return data[i]
+		}
+		return 0
+	}, addr)
+}
@@ -0,0 +1,98 @@
+package disasm
+
+import (
+	"testing"
+
+	"github.com/boombuler/goboy2/cpu"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		addr   uint16
+		data   []byte
+		exp    string
+		len    int
+		target int
+	}{
+		{0x0100, []byte{0x00}, "NOP", 1, -1},
+		{0x0100, []byte{0x10, 0x00}, "STOP", 2, -1},
+		{0x0100, []byte{0x01, 0x00, 0xC0}, "LD BC, $C000", 3, -1},
+		{0x0100, []byte{0x3E, 0x42}, "LD A, $42", 2, -1},
+		{0x0100, []byte{0xEA, 0x34, 0x12}, "LD ($1234), A", 3, -1},
+		{0x0100, []byte{0xE0, 0x40}, "LDH ($40), A", 2, -1},
+		{0x0100, []byte{0xE8, 0xFE}, "ADD SP, -$02", 2, -1},
+		{0x0100, []byte{0xF8, 0x05}, "LDHL SP, $05", 2, -1},
+		{0x0150, []byte{0x18, 0xFE}, "JR $0150", 2, 0x0150},
+		{0x0150, []byte{0x20, 0x10}, "JR NZ, $0162", 2, 0x0162},
+		{0x0100, []byte{0xC3, 0x50, 0x01}, "JP $0150", 3, 0x0150},
+		{0x0100, []byte{0xE9}, "JP (HL)", 1, -1},
+		{0x0100, []byte{0xCD, 0x00, 0x02}, "CALL $0200", 3, 0x0200},
+		{0x0100, []byte{0xDC, 0x00, 0x40}, "CALL C, $4000", 3, 0x4000},
+		{0x0100, []byte{0xC7}, "RST $00", 1, 0x00},
+		{0x0100, []byte{0xFF}, "RST $38", 1, 0x38},
+		{0x0100, []byte{0xCB, 0x7C}, "BIT 7, H", 2, -1},
+		{0x0100, []byte{0x0F}, "RRCA", 1, -1},
+		{0x0100, []byte{0xD3}, "DB $D3", 1, -1},
+	}
+	for _, test := range tests {
+		i := DecodeBytes(test.data, test.addr)
+		if i.String() != test.exp {
+			t.Errorf("% X: got %q, expected %q", test.data, i.String(), test.exp)
+		}
+		if i.Len() != test.len {
+			t.Errorf("% X: got length %d, expected %d", test.data, i.Len(), test.len)
+		}
+		if i.HasTarget != (test.target >= 0) || (i.HasTarget && int(i.Target) != test.target) {
+			t.Errorf("% X: got target %04X (%v), expected %04X", test.data, i.Target, i.HasTarget, test.target)
+		}
+	}
+}
+
+func TestLength(t *testing.T) {
+	// every opcode has to be decoded with a length which matches the operands of its label.
+	for op := 0; op < 0x100; op++ {
+		i := DecodeBytes([]byte{byte(op), 0, 0}, 0)
+		if i.Mnemonic == "" {
+			t.Errorf("%02X: no mnemonic", op)
+		}
+		if i.Len() < 1 || i.Len() > 3 {
+			t.Errorf("%02X: invalid length %d", op, i.Len())
+		}
+		if n := cpu.Length(byte(op)); n != i.Len() {
+			t.Errorf("%02X: cpu.Length returned %d, expected %d", op, n, i.Len())
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	names := func(addr uint16) string {
+		switch addr {
+		case 0x0200:
+			return "Init"
+		case 0x0038:
+			return "Crash"
+		case 0xFF40:
+			return "rLCDC"
+		case 0xC000:
+			return "wPlayerX"
+		}
+		return ""
+	}
+	tests := []struct {
+		data []byte
+		exp  string
+	}{
+		{[]byte{0xCD, 0x00, 0x02}, "CALL Init"},
+		{[]byte{0xC4, 0x00, 0x02}, "CALL NZ, Init"},
+		{[]byte{0xFF}, "RST Crash"},
+		{[]byte{0xE0, 0x40}, "LDH (rLCDC), A"},
+		{[]byte{0xFA, 0x00, 0xC0}, "LD A, (wPlayerX)"},
+		{[]byte{0xC3, 0x00, 0x03}, "JP $0300"},
+		{[]byte{0x3E, 0x40}, "LD A, $40"},
+	}
+	for _, test := range tests {
+		if got := DecodeBytes(test.data, 0x0100).Format(names); got != test.exp {
+			t.Errorf("% X: got %q, expected %q", test.data, got, test.exp)
+		}
+	}
+}
@@ -1,21 +1,36 @@
 package main
 
 import (
+	"log"
+
 	"github.com/boombuler/goboy2/apu"
 	"github.com/boombuler/goboy2/cartridge"
+	"github.com/boombuler/goboy2/cdl"
 	"github.com/boombuler/goboy2/consts"
 	"github.com/boombuler/goboy2/cpu"
+	"github.com/boombuler/goboy2/debugger"
 	"github.com/boombuler/goboy2/input"
 	"github.com/boombuler/goboy2/mmu"
+	"github.com/boombuler/goboy2/movie"
 	"github.com/boombuler/goboy2/ppu"
+	"github.com/boombuler/goboy2/profiler"
+	"github.com/boombuler/goboy2/rewind"
 	"github.com/boombuler/goboy2/serial"
+	"github.com/boombuler/goboy2/symbols"
 	"github.com/boombuler/goboy2/timer"
+	"github.com/boombuler/goboy2/trace"
 )
 
 const compatAuto consts.HardwareCompat = -1
 
+// batteryFlushFrames is the number of frames after which changes of the battery backed ram are saved.
+const batteryFlushFrames = 60
+
 type GameBoy struct {
 	exitChan <-chan struct{}
+	cart     *cartridge.Cartridge
+	clocked  cartridge.Clocked
+	dsTick   bool
 	MMU      mmu.MMU
 	CPU      *cpu.CPU
 	PPU      *ppu.PPU
@@ -23,12 +38,44 @@ type GameBoy struct {
 	Timer    *timer.Timer
 	Input    *input.Keyboard
 	Serial   *serial.Serial
+
+	cycle    uint64
+	buttons  chan struct{}
+	calls    chan func()
+	debugger *debugger.Debugger
+	tracer   *trace.Writer
+	cdl      *cdl.Logger
+	profiler *profiler.Profiler
+	symbols  *symbols.Table
+	recorder *movie.Recorder
+	playback []movie.Event
+
+	// tracedHook is the instruction hook of the cpu which is called by the trace.
+	tracedHook func(i *cpu.Instruction)
+
+	frameCycle     int
+	flushFrames    int
+	rewind         *rewind.Buffer
+	rewindInterval int
+	rewinding      int32
+	frames         int
+	// rewindWait is the number of frames until the next snapshot is restored.
+	rewindWait int
+
+	// heldButtons has a bit for every button the frontend holds, which Run
+	// applies after buttons was signaled. appliedButtons are the applied bits.
+	heldButtons    uint32
+	appliedButtons uint32
 }
 
 // NewGameBoy creates a new gameboy for the given cartridge
 func NewGameBoy(c *cartridge.Cartridge, screen chan<- *ppu.ScreenImage, hw consts.HardwareCompat, exitChan <-chan struct{}) *GameBoy {
 	gb := new(GameBoy)
 	gb.exitChan = exitChan
+	gb.cart = c
+	gb.clocked, _ = c.Mapper().(cartridge.Clocked)
+	gb.buttons = make(chan struct{}, 1)
+	gb.calls = make(chan func())
 
 	if hw == compatAuto {
 		if c.GBC {
@@ -41,6 +88,8 @@ func NewGameBoy(c *cartridge.Cartridge, screen chan<- *ppu.ScreenImage, hw const
 	gb.MMU = mmu.New(hw)
 	gb.APU = apu.New(gb.MMU)
 	gb.CPU = cpu.New(gb.MMU)
+	gb.CPU.OnInstruction = gb.instruction
+	gb.CPU.OnInterrupt = gb.interrupt
 	gb.PPU = ppu.New(gb.MMU, screen, exitChan)
 	gb.Timer = timer.New(gb.MMU)
 	gb.Serial = serial.New(gb.MMU)
@@ -54,27 +103,134 @@ func (gb *GameBoy) Run() {
 	if err := gb.APU.Start(); err != nil {
 		panic(err)
 	}
-	dsTick := false
+	defer gb.APU.Stop()
+	gb.run()
+}
+
+// run emulates until the exit chan is closed.
+func (gb *GameBoy) run() {
+	if gb.tracer != nil {
+		// write the last instructions when the emulation stops, even after a crash.
+		defer func() {
+			if err := gb.StopTrace(); err != nil {
+				log.Println("could not write trace:", err)
+			}
+		}()
+	}
 	for {
 		select {
 		case _, _ = <-gb.exitChan:
-			gb.APU.Stop()
 			return
+		case <-gb.buttons:
+			gb.applyButtons()
 		default:
-			gb.Timer.Prepare()
-			gb.CPU.Step()
-			gb.MMU.Step()
-			gb.Timer.Step()
-			gb.Serial.Step()
-			if !dsTick {
-				gb.APU.Step()
-				gb.PPU.Step()
-				dsTick = gb.CPU.DoubleSpeed()
-			} else {
-				dsTick = false
-			}
+			gb.step()
+		}
+	}
+}
+
+// instruction is the OnInstruction hook of the cpu.
+func (gb *GameBoy) instruction(i *cpu.Instruction) {
+	if gb.debugger != nil {
+		gb.debugger.HookInstruction(i)
+	}
+	if gb.cdl != nil {
+		gb.logInstruction(i)
+	}
+	if gb.profiler != nil {
+		gb.profiler.Instruction(i)
+	}
+}
+
+// interrupt is the OnInterrupt hook of the cpu.
+func (gb *GameBoy) interrupt(i *cpu.Interrupt) {
+	if gb.debugger != nil {
+		gb.debugger.HookInterrupt(i)
+	}
+	if gb.profiler != nil {
+		gb.profiler.Interrupt(i)
+	}
+}
+
+// step emulates a single m-cycle of the gameboy.
+func (gb *GameBoy) step() {
+	if gb.playback != nil {
+		gb.playMovie()
+	}
+	gb.cycle++
+
+	if gb.debugger != nil && gb.CPU.AtInstruction() {
+		gb.debugger.Instruction()
+	}
+	gb.Timer.Prepare()
+	gb.CPU.Step()
+	gb.MMU.Step()
+	gb.Timer.Step()
+	gb.Serial.Step()
+	if !gb.dsTick {
+		gb.APU.Step()
+		gb.PPU.Step()
+		if gb.clocked != nil {
+			gb.clocked.Step()
+		}
+		gb.dsTick = gb.CPU.DoubleSpeed()
+		if gb.frameCycle++; gb.frameCycle == frameCycles {
+			gb.frameCycle = 0
+			gb.frameDone()
+		}
+	} else {
+		gb.dsTick = false
+	}
+}
+
+// frameDone is called by Run after every frame.
+func (gb *GameBoy) frameDone() {
+	if gb.flushFrames++; gb.flushFrames >= batteryFlushFrames {
+		gb.flushFrames = 0
+		if err := gb.cart.Flush(); err != nil {
+			log.Println("could not save battery:", err)
 		}
 	}
+	gb.rewindFrame()
+	if gb.debugger != nil {
+		gb.debugger.FrameDone()
+	}
+	for {
+		select {
+		case fn := <-gb.calls:
+			fn()
+		default:
+			return
+		}
+	}
+}
+
+// AttachDebugger creates a debugger which stops the emulation before the
+// first instruction. It must be called before Run.
+func (gb *GameBoy) AttachDebugger() *debugger.Debugger {
+	gb.debugger = debugger.New(gb.CPU, gb.MMU, gb.cart, gb.exitChan)
+	gb.debugger.SetSymbols(gb.symbols)
+	return gb.debugger
+}
+
+// SetSymbols sets the labels used by the debugger, the trace and the profile.
+// It must be called before AttachDebugger, StartTrace and StartProfile.
+func (gb *GameBoy) SetSymbols(t *symbols.Table) {
+	gb.symbols = t
+}
+
+// Exec runs fn at the end of the next frame on the goroutine of Run and waits
+// until it returned. It returns false if the emulation stopped before fn was
+// executed. It is safe to call while Run executes.
+func (gb *GameBoy) Exec(fn func()) bool {
+	done := make(chan struct{})
+	select {
+	case gb.calls <- func() { fn(); close(done) }:
+	case _, _ = <-gb.exitChan:
+		return false
+	}
+	<-done
+	return true
 }
 
 // InitNoBOOT brings the gameboy to the state after the bootrom finished
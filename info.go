@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/boombuler/goboy2/cartridge"
+	"github.com/boombuler/goboy2/romfile"
+)
+
+// runInfo prints the cartridge headers of the given roms. It returns the exit
+// code, which is 1 if any of the roms could not be read or has an invalid header.
+func runInfo(args []string) int {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the headers as json")
+	entry := fs.String("entry", "", "`name` of the rom within a zip archive with multiple roms")
+	fs.Usage = func() {
+		log.Println("Usage:")
+		log.Println(os.Args[0], "info [-json] [-entry name] (romfile)...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	exitCode := 0
+	for _, file := range fs.Args() {
+		rf, err := romfile.Open(file, *entry)
+		var h *cartridge.Header
+		if err == nil {
+			h, err = cartridge.ParseHeader(rf.Data)
+		}
+		if err != nil || !h.Valid() {
+			exitCode = 1
+		}
+
+		if *asJSON {
+			res := struct {
+				File   string            `json:"file"`
+				Error  string            `json:"error,omitempty"`
+				Header *cartridge.Header `json:"header,omitempty"`
+			}{File: file, Header: h}
+			if err != nil {
+				res.Error = err.Error()
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(res)
+		} else if err != nil {
+			fmt.Printf("%s: %v\n", file, err)
+		} else {
+			fmt.Printf("%s:\n", file)
+			printHeader(os.Stdout, h)
+		}
+	}
+	return exitCode
+}
+
+func validity(valid bool) string {
+	if valid {
+		return "ok"
+	}
+	return "INVALID"
+}
+
+func printHeader(out io.Writer, h *cartridge.Header) {
+	tw := tabwriter.NewWriter(out, 0, 4, 1, ' ', 0)
+	fmt.Fprintf(tw, "  Title:\t%s\n", h.Title)
+	if h.Manufacturer != "" {
+		fmt.Fprintf(tw, "  Manufacturer:\t%s\n", h.Manufacturer)
+	}
+	fmt.Fprintf(tw, "  CGB flag:\t%02X (gbc: %v)\n", h.CGBFlag, h.GBC)
+	fmt.Fprintf(tw, "  SGB:\t%v\n", h.SGB)
+	fmt.Fprintf(tw, "  Licensee:\t%s %s\n", h.Licensee, h.LicenseeName)
+	fmt.Fprintf(tw, "  Cartridge type:\t%02X %s\n", h.CartridgeType, h.CartridgeName)
+	fmt.Fprintf(tw, "  ROM size:\t%02X (%d KB)\n", h.ROMSizeCode, h.ROMSize/1024)
+	fmt.Fprintf(tw, "  RAM size:\t%02X (%d KB)\n", h.RAMSizeCode, h.RAMSize/1024)
+	fmt.Fprintf(tw, "  Japanese:\t%v\n", h.Japanese)
+	fmt.Fprintf(tw, "  Version:\t%d\n", h.Version)
+	if h.MMM01 {
+		fmt.Fprintf(tw, "  MMM01:\theader at the end of the rom\n")
+	}
+	fmt.Fprintf(tw, "  Nintendo logo:\t%s\n", validity(h.LogoValid))
+	fmt.Fprintf(tw, "  Header checksum:\t%02X %s\n", h.HeaderChecksum, validity(h.HeaderChecksumValid))
+	fmt.Fprintf(tw, "  Global checksum:\t%04X %s\n", h.GlobalChecksum, validity(h.GlobalChecksumValid))
+	tw.Flush()
+	for _, p := range h.Problems {
+		fmt.Fprintf(out, "  ! %s\n", p)
+	}
+}
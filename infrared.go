@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/boombuler/goboy2/cartridge"
+)
+
+// openInfrared creates the transfer of the infrared port given by the -infrared
+// option. The returned function closes the transfer.
+func openInfrared(target string) (cartridge.InfraredTransfer, func(), error) {
+	if target == "loopback" {
+		return new(cartridge.InfraredLoopback), func() {}, nil
+	}
+	var conn net.Conn
+	switch mode, addr := splitInfrared(target); mode {
+	case "listen":
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Println("waiting for the infrared port of another emulator on", l.Addr())
+		conn, err = l.Accept()
+		l.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+	case "connect":
+		var err error
+		if conn, err = net.Dial("tcp", addr); err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("invalid infrared target %q, expected loopback, listen:address or connect:address", target)
+	}
+	ic := cartridge.NewInfraredConn(conn)
+	return ic, func() {
+		if err := ic.Close(); err != nil {
+			log.Println("could not close the infrared connection:", err)
+		}
+	}, nil
+}
+
+func splitInfrared(target string) (mode, addr string) {
+	if i := strings.IndexByte(target, ':'); i >= 0 {
+		return target[:i], target[i+1:]
+	}
+	return target, ""
+}
@@ -81,48 +81,60 @@ func (kb *Keyboard) Write(addr uint16, value byte) {
 	}
 }
 
-func (kb *Keyboard) HandleKeyEvent(isPressed bool, key sdl.Keycode) {
+// Button is one of the buttons of the gameboy.
+type Button byte
+
+const (
+	ButtonRight Button = iota
+	ButtonLeft
+	ButtonUp
+	ButtonDown
+	ButtonA
+	ButtonB
+	ButtonSelect
+	ButtonStart
+	ButtonCount
+)
+
+// ButtonForKey returns the button which is mapped to the given key.
+func (kb *Keyboard) ButtonForKey(key sdl.Keycode) (Button, bool) {
+	switch key {
+	case kb.keyMap.Right:
+		return ButtonRight, true
+	case kb.keyMap.Left:
+		return ButtonLeft, true
+	case kb.keyMap.Up:
+		return ButtonUp, true
+	case kb.keyMap.Down:
+		return ButtonDown, true
+	case kb.keyMap.A:
+		return ButtonA, true
+	case kb.keyMap.B:
+		return ButtonB, true
+	case kb.keyMap.Select:
+		return ButtonSelect, true
+	case kb.keyMap.Start:
+		return ButtonStart, true
+	}
+	return 0, false
+}
+
+// SetButton changes the state of a single button.
+func (kb *Keyboard) SetButton(btn Button, isPressed bool) {
 	kb.lock.Lock()
 	defer kb.lock.Unlock()
 
+	col, mask := btn/4, byte(1)<<(btn%4)
 	if isPressed {
-		switch key {
-		case kb.keyMap.Right:
-			kb.keyState[0] &^= 0x1
-		case kb.keyMap.Left:
-			kb.keyState[0] &^= 0x2
-		case kb.keyMap.Up:
-			kb.keyState[0] &^= 0x4
-		case kb.keyMap.Down:
-			kb.keyState[0] &^= 0x8
-		case kb.keyMap.A:
-			kb.keyState[1] &^= 0x1
-		case kb.keyMap.B:
-			kb.keyState[1] &^= 0x2
-		case kb.keyMap.Select:
-			kb.keyState[1] &^= 0x4
-		case kb.keyMap.Start:
-			kb.keyState[1] &^= 0x8
-		}
+		kb.keyState[col] &^= mask
 		kb.mmu.RequestInterrupt(mmu.IRQJoypad)
 	} else {
-		switch key {
-		case kb.keyMap.Right:
-			kb.keyState[0] |= 0x1
-		case kb.keyMap.Left:
-			kb.keyState[0] |= 0x2
-		case kb.keyMap.Up:
-			kb.keyState[0] |= 0x4
-		case kb.keyMap.Down:
-			kb.keyState[0] |= 0x8
-		case kb.keyMap.A:
-			kb.keyState[1] |= 0x1
-		case kb.keyMap.B:
-			kb.keyState[1] |= 0x2
-		case kb.keyMap.Select:
-			kb.keyState[1] |= 0x4
-		case kb.keyMap.Start:
-			kb.keyState[1] |= 0x8
-		}
+		kb.keyState[col] |= mask
+	}
+}
+
+func (kb *Keyboard) HandleKeyEvent(isPressed bool, key sdl.Keycode) {
+	if btn, ok := kb.ButtonForKey(key); ok {
+		kb.SetButton(btn, isPressed)
 	}
 }
@@ -0,0 +1,19 @@
+package input
+
+import "github.com/boombuler/goboy2/savestate"
+
+// SaveState writes the pressed keys and the selected key column.
+func (kb *Keyboard) SaveState(w *savestate.Writer) {
+	kb.lock.Lock()
+	defer kb.lock.Unlock()
+	w.Bytes(kb.keyState[:])
+	w.U8(kb.colSelect)
+}
+
+// LoadState restores a state written by SaveState.
+func (kb *Keyboard) LoadState(r *savestate.Reader) {
+	kb.lock.Lock()
+	defer kb.lock.Unlock()
+	r.Bytes(kb.keyState[:])
+	kb.colSelect = r.U8()
+}
@@ -0,0 +1,81 @@
+package input
+
+import (
+	"sync"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+type TiltKeyMap struct {
+	Up    sdl.Keycode
+	Left  sdl.Keycode
+	Down  sdl.Keycode
+	Right sdl.Keycode
+}
+
+var DefaultTiltKeymap = TiltKeyMap{
+	Up:    sdl.K_KP_8,
+	Left:  sdl.K_KP_4,
+	Down:  sdl.K_KP_2,
+	Right: sdl.K_KP_6,
+}
+
+// Tilt is the input for the accelerometer of tilt controlled cartridges. While a
+// tilt key is held, the cartridge is tilted by 1g in its direction. The tilt can
+// also be set directly with Set, for example by scripts.
+type Tilt struct {
+	lock   sync.Mutex
+	keyMap TiltKeyMap
+	keys   [4]bool
+	x, y   float64
+}
+
+func NewTilt() *Tilt {
+	return &Tilt{keyMap: DefaultTiltKeymap}
+}
+
+// Tilt returns the acceleration along the x and the y axis in g.
+func (t *Tilt) Tilt() (x, y float64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.x, t.y
+}
+
+// Set changes the acceleration along the x and the y axis.
+func (t *Tilt) Set(x, y float64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.x, t.y = x, y
+}
+
+// HandleKeyEvent changes the tilt if the key is one of the tilt keys. It returns
+// false for all other keys.
+func (t *Tilt) HandleKeyEvent(isPressed bool, key sdl.Keycode) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	switch key {
+	case t.keyMap.Up:
+		t.keys[0] = isPressed
+	case t.keyMap.Down:
+		t.keys[1] = isPressed
+	case t.keyMap.Left:
+		t.keys[2] = isPressed
+	case t.keyMap.Right:
+		t.keys[3] = isPressed
+	default:
+		return false
+	}
+	t.x, t.y = axis(t.keys[2], t.keys[3]), axis(t.keys[0], t.keys[1])
+	return true
+}
+
+func axis(neg, pos bool) float64 {
+	switch {
+	case neg && !pos:
+		return -1
+	case pos && !neg:
+		return 1
+	}
+	return 0
+}
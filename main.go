@@ -1,14 +1,25 @@
 package main
 
 import (
+	"bytes"
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime/pprof"
+	"strings"
 
+	"github.com/boombuler/goboy2/camera"
+	"github.com/boombuler/goboy2/cheat"
 	"github.com/boombuler/goboy2/consts"
+	"github.com/boombuler/goboy2/input"
 	"github.com/boombuler/goboy2/mmu"
+	"github.com/boombuler/goboy2/movie"
+	"github.com/boombuler/goboy2/patch"
+	"github.com/boombuler/goboy2/romfile"
+	"github.com/boombuler/goboy2/symbols"
 
 	"github.com/boombuler/goboy2/cartridge"
 	"github.com/boombuler/goboy2/screen"
@@ -19,37 +30,173 @@ import (
 func showUsage() {
 	log.Println("Usage:")
 	log.Println(filepath.Base(os.Args[0]), "(romfile)")
+	log.Println(filepath.Base(os.Args[0]), "info [-json] (romfile)...")
+	log.Println(filepath.Base(os.Args[0]), "disasm [-bank N] [-from X] (romfile)")
 	os.Exit(1)
 }
 
-func loadCatridge() (*cartridge.Cartridge, error) {
+// loadCheats reads the cheats of the rom. It returns nil if there are none.
+func loadCheats(romName string) (*cheat.Engine, error) {
+	fileName := *cheatFile
+	if fileName == "" {
+		fileName = cheat.Find(romName)
+	}
+	if fileName == "" || *mooneye {
+		return nil, nil
+	}
+	cheats, err := cheat.Load(fileName)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("loaded %d cheats from %s", len(cheats), fileName)
+	return cheat.New(cheats), nil
+}
+
+// loadSymbols reads the labels of the rom. It returns nil if there are none.
+func loadSymbols(romName string) (*symbols.Table, error) {
+	fileName := *symFile
+	if fileName == "" {
+		fileName = symbols.Find(romName)
+	}
+	if fileName == "" {
+		return nil, nil
+	}
+	syms, err := symbols.Load(fileName)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("loaded %d labels from %s", syms.Len(), fileName)
+	return syms, nil
+}
+
+// loadedROM contains the cartridge and the files which belong to the rom.
+type loadedROM struct {
+	cart    *cartridge.Cartridge
+	cheats  *cheat.Engine
+	symbols *symbols.Table
+	// data is the content of the rom after the patch was applied.
+	data []byte
+	// name is the path other files of the rom are located next to.
+	name string
+}
+
+func loadCatridge() (*loadedROM, error) {
 	if flag.NArg() != 1 {
 		showUsage()
 	}
-	f, err := os.Open(flag.Arg(0))
+	rf, err := romfile.Open(flag.Arg(0), *entry)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
+	rom := rf.Data
 
-	bf := func() cartridge.Battery {
-		return cartridge.GetBattery(flag.Arg(0))
+	patchFile := *patchFlag
+	if patchFile == "" {
+		patchFile = patch.Find(rf.Name)
+	}
+	if patchFile != "" {
+		p, err := ioutil.ReadFile(patchFile)
+		if err != nil {
+			return nil, err
+		}
+		if rom, err = patch.Apply(rom, p); err != nil {
+			return nil, fmt.Errorf("%s: %v", patchFile, err)
+		}
+		log.Println("applied patch", patchFile)
+	}
+
+	settings, err := loadSettings(rf.Name)
+	if err != nil {
+		return nil, err
+	}
+	if *mapper != "" {
+		settings.Mapper = *mapper
+	}
+
+	bf := func() (cartridge.Battery, error) {
+		if *mooneye {
+			// test roms should not leave save files behind
+			return new(cartridge.MemoryBattery), nil
+		}
+		if *play != "" {
+			// the ram of the movie must not overwrite the save file.
+			return new(cartridge.MemoryBattery), nil
+		}
+		// syncing the file takes too long for the emulation goroutine.
+		return cartridge.NewBackgroundBattery(cartridge.GetBattery(rf.Name)), nil
 	}
 
-	return cartridge.Load(f, bf)
+	c, err := cartridge.LoadMapper(bytes.NewReader(rom), settings.Mapper, bf)
+	if err != nil {
+		return nil, err
+	}
+	cheats, err := loadCheats(rf.Name)
+	if err != nil {
+		return nil, err
+	}
+	if cheats != nil {
+		c.MBC = cheats.Wrap(c.MBC)
+	}
+	syms, err := loadSymbols(rf.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &loadedROM{cart: c, cheats: cheats, symbols: syms, data: rom, name: rf.Name}, nil
 }
 
 var (
-	dump       = flag.Bool("dump", false, "dump cpu state after every instruction")
-	noboot     = flag.Bool("noboot", false, "skip boot sequence")
-	cpuprofile = flag.String("cpuprofile", "", "write cpu profile to `file`")
-	mooneye    = flag.Bool("mooneye", false, "runs a mooneye test-rom")
-	gbc        = flag.Bool("color", false, "Force Gameboy Color mode")
-	dmg        = flag.Bool("dmg", false, "Force DMG-Gameboy mode")
+	traceFile   = flag.String("trace", "", "write the cpu state before every instruction to `file`")
+	traceFormat = flag.String("traceformat", "doctor", "`format` of the trace, doctor or rich")
+	traceRing   = flag.Int("tracering", 0, "only write the last `n` instructions of the trace when the emulation stops or crashes")
+	cdlFile     = flag.String("cdl", "", "log which bytes of the rom are executed or read as data to `file` and print the coverage, an existing log is extended")
+	romProfile  = flag.String("romprofile", "", "write a pprof profile of the cycles spent in the routines of the rom to `file`")
+	symFile     = flag.String("sym", "", "load the labels from the RGBDS symbol `file` instead of the symbol file next to the rom")
+	noboot      = flag.Bool("noboot", false, "skip boot sequence")
+	cpuprofile  = flag.String("cpuprofile", "", "write cpu profile to `file`")
+	mooneye     = flag.Bool("mooneye", false, "runs a mooneye test-rom")
+	gbc         = flag.Bool("color", false, "Force Gameboy Color mode")
+	dmg         = flag.Bool("dmg", false, "Force DMG-Gameboy mode")
+	rewindRate  = flag.Int("rewind", 10, "take a rewind snapshot every `n` frames, 0 disables rewinding")
+	rewindMem   = flag.Int("rewindmem", 64, "memory budget of the rewind buffer in `MB`")
+	record      = flag.String("record", "", "record the input to a movie `file`")
+	play        = flag.String("play", "", "replay the input of a movie `file`")
+	entry       = flag.String("entry", "", "`name` of the rom within a zip archive with multiple roms")
+	mapper      = flag.String("mapper", "", "override the `mapper` of the cartridge, one of "+strings.Join(cartridge.MapperNames(), ", "))
+	patchFlag   = flag.String("patch", "", "apply an IPS, UPS or BPS patch `file` to the rom")
+	cheatFile   = flag.String("cheats", "", "load the cheats from `file` instead of the cheat file next to the rom")
+	ramSearch   = flag.Bool("ramsearch", false, "read ram search commands from stdin")
+	debug       = flag.Bool("debug", false, "start the emulation stopped and read debugger commands from stdin")
+	dapAddr     = flag.String("dap", "", "start the emulation stopped and serve the Debug Adapter Protocol on `address`, like localhost:4711")
+	cameraSrc   = flag.String("camera", "", "png `file` or directory of png frames seen by the Game Boy Camera, defaults to a test pattern")
+	irTarget    = flag.String("infrared", "", "connect the infrared port of the cartridge to `target`: loopback, listen:address or connect:address of another emulator")
 )
 
+func loadMovie() (*movie.Movie, error) {
+	f, err := os.Open(*play)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return movie.Read(f)
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "info" {
+		os.Exit(runInfo(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "disasm" {
+		os.Exit(runDisasm(os.Args[2:]))
+	}
 	flag.Parse()
+	if *debug && *ramSearch {
+		log.Fatal("-debug and -ramsearch can not be used together, both read commands from stdin")
+	}
+	if *debug && *dapAddr != "" {
+		log.Fatal("-debug and -dap can not be used together")
+	}
+	if *dapAddr != "" && *ramSearch {
+		log.Fatal("-dap and -ramsearch can not be used together, the ram search can not run while the debugger is stopped")
+	}
 
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
@@ -62,10 +209,37 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
-	c, err := loadCatridge()
+	rom, err := loadCatridge()
 	if err != nil {
 		log.Fatal(err)
 	}
+	c, cheats, syms := rom.cart, rom.cheats, rom.symbols
+	if _, ok := c.Mapper().(cartridge.CameraPort); ok {
+		src, err := camera.Open(*cameraSrc)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.SetImageSource(src)
+	}
+	tilt := input.NewTilt()
+	if c.SetAccelerometer(tilt) && (*record != "" || *play != "") {
+		log.Fatal("movies of tilt controlled cartridges are not supported, the tilt is not recorded")
+	}
+	closeInfrared := func() {}
+	if *irTarget != "" {
+		if _, ok := c.Mapper().(cartridge.InfraredPort); !ok {
+			log.Fatal("the cartridge has no infrared port")
+		}
+		if *irTarget != "loopback" && (*record != "" || *play != "") {
+			log.Fatal("the light of another emulator is not recorded in movies")
+		}
+		ir, closeIR, err := openInfrared(*irTarget)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.SetInfrared(ir)
+		closeInfrared = closeIR
+	}
 
 	hw := compatAuto
 	if *gbc {
@@ -75,17 +249,32 @@ func main() {
 	}
 
 	if *mooneye {
-		runMooneyeRom(c, hw)
+		runMooneyeRom(c, syms, hw)
 		return
 	}
 
+	var mov *movie.Movie
+	if *play != "" {
+		if mov, err = loadMovie(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	screen.Main(func(s *screen.Screen, input <-chan interface{}, exitChan <-chan struct{}) {
 		gb := NewGameBoy(c, s.GetOutputChannel(), hw, exitChan)
+		gb.SetSymbols(syms)
+		c.SetRumble(s.SetRumble)
+		if cheats != nil {
+			gb.PPU.OnVBlank = func() { cheats.WriteRAM(gb.MMU) }
+		}
+		if *rewindRate > 0 && *record == "" && mov == nil {
+			// loading a snapshot would break the timing of the movie.
+			gb.EnableRewind(*rewindRate, *rewindMem<<20)
+		}
 		go func() {
 			for {
 				select {
 				case _, _ = <-exitChan:
-					c.Shutdown()
 					return
 				case ev := <-input:
 					switch e := ev.(type) {
@@ -93,8 +282,23 @@ func main() {
 						if e.Key == sdl.K_d && e.Pressed {
 							gb.PPU.PrintPalettes()
 						}
+						if e.Key == sdl.K_r {
+							gb.SetRewinding(e.Pressed)
+						}
+						if e.Key == sdl.K_c && e.Pressed && cheats != nil {
+							if *record != "" || mov != nil {
+								// the toggle is not part of the movie.
+								log.Println("cheats can not be toggled while a movie is recorded or played")
+							} else if cheats.Toggle() {
+								log.Println("cheats enabled")
+							} else {
+								log.Println("cheats disabled")
+							}
+						}
 
-						gb.Input.HandleKeyEvent(e.Pressed, e.Key)
+						if !tilt.HandleKeyEvent(e.Pressed, e.Key) {
+							gb.HandleKeyEvent(e.Pressed, e.Key)
+						}
 					}
 				}
 			}
@@ -105,7 +309,42 @@ func main() {
 			(hw == consts.DMG && len(mmu.BOOTROM) == 0)
 
 		gb.Init(noBootRom)
-		gb.CPU.Dump = *dump
+		stopTrace := startTraceFile(gb)
+		stopCDL := startCDLFile(gb, len(rom.data))
+		stopProfile := startProfileFile(gb)
+		if mov != nil {
+			if err := gb.Play(mov); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *record != "" {
+			f, err := os.Create(*record)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := gb.StartRecording(f); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *debug {
+			go gb.AttachDebugger().RunREPL(os.Stdin, os.Stdout)
+		} else if *ramSearch {
+			go gb.runRAMSearch(os.Stdin, os.Stdout)
+		}
+		if *dapAddr != "" {
+			gb.serveDAP(*dapAddr, rom)
+		}
 		gb.Run()
+		// the battery is written by Run, so it has to be shut down on the same goroutine.
+		if err := c.Shutdown(); err != nil {
+			log.Println("could not save battery:", err)
+		}
+		closeInfrared()
+		stopTrace()
+		stopCDL()
+		stopProfile()
+		if err := gb.StopRecording(); err != nil {
+			log.Println("could not write movie:", err)
+		}
 	})
 }
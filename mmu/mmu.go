@@ -3,6 +3,7 @@ package mmu
 import (
 	"github.com/boombuler/goboy2/cartridge"
 	"github.com/boombuler/goboy2/consts"
+	"github.com/boombuler/goboy2/savestate"
 )
 
 type MMU interface {
@@ -14,8 +15,19 @@ type MMU interface {
 	ConnectPPU(ppu IODevice)
 	LoadCartridge(cartridge *cartridge.Cartridge)
 	AddIODevice(d IODevice, addrs ...uint16)
+	// AddWatcher adds a watcher which is notified about all reads and writes.
+	// Adding a watcher twice has no effect.
+	AddWatcher(w Watcher)
+	// RemoveWatcher removes a watcher.
+	RemoveWatcher(w Watcher)
+	// Peek reads a value without notifying the watchers.
+	Peek(addr uint16) byte
+	// ROMBank returns the rom bank which is mapped at addr, or -1 if addr is
+	// not in the cartridge rom or the boot rom is mapped there.
+	ROMBank(addr uint16) int
 	Step()
 	Init(noBoot bool)
+	savestate.Stater
 }
 
 type mmuImpl struct {
@@ -23,10 +35,16 @@ type mmuImpl struct {
 	ioDevices []IODevice
 	cartridge *cartridge.Cartridge
 	ppu       IODevice
-	ram       IODevice
+	ram       *workingRAM
 	zpram     [127]byte
 	dma       *dmaTransfer
+	irq       *irqHandler
+	boot      *bootMode
+	gbcRegs   *gbcRegisters
 	lcdMode   byte
+	watchers  []Watcher
+	// cartBus is notified about the reads of the cpu from the cartridge.
+	cartBus cartridge.BusListener
 }
 
 type IODevice interface {
@@ -34,6 +52,14 @@ type IODevice interface {
 	Write(addr uint16, value byte)
 }
 
+// Watcher is notified about the memory accesses of the MMU.
+type Watcher interface {
+	// WatchRead is called after a value was read.
+	WatchRead(addr uint16, value byte)
+	// WatchWrite is called before a value is written.
+	WatchWrite(addr uint16, value byte)
+}
+
 type IOAddrDevice interface {
 	IOAddrs() []uint16
 }
@@ -59,12 +85,15 @@ func New(hw consts.HardwareCompat) MMU {
 	}
 	res.ram = newWorkingRAM(res)
 	res.dma = &dmaTransfer{mmu: res}
-	res.AddIODevice(new(irqHandler), consts.AddrIRQFlags, consts.AddrIRQEnabled)
-	res.AddIODevice(new(bootMode), consts.AddrBootmodeFlag)
+	res.irq = new(irqHandler)
+	res.boot = new(bootMode)
+	res.AddIODevice(res.irq, consts.AddrIRQFlags, consts.AddrIRQEnabled)
+	res.AddIODevice(res.boot, consts.AddrBootmodeFlag)
 	res.AddIODevice(res.dma, consts.AddrDMATransfer)
 	if hw == consts.GBC {
 		// Add undocumented GBC registers.
-		res.AddIODevice(newGBCRegisters(res))
+		res.gbcRegs = newGBCRegisters(res)
+		res.AddIODevice(res.gbcRegs)
 	}
 
 	return res
@@ -89,14 +118,15 @@ func (m *mmuImpl) HardwareCompat() consts.HardwareCompat {
 }
 
 func (m *mmuImpl) EmuMode() consts.HardwareCompat {
-	if m.hw == consts.GBC && (m.lcdMode != 4 || m.Read(consts.AddrBootmodeFlag) == 0x00) {
+	if m.hw == consts.GBC && (m.lcdMode != 4 || m.read(consts.AddrBootmodeFlag) == 0x00) {
 		return consts.GBC
 	}
 	return consts.DMG
 }
 
-func (m *mmuImpl) LoadCartridge(cartridge *cartridge.Cartridge) {
-	m.cartridge = cartridge
+func (m *mmuImpl) LoadCartridge(c *cartridge.Cartridge) {
+	m.cartridge = c
+	m.cartBus, _ = c.Mapper().(cartridge.BusListener)
 }
 
 func (m *mmuImpl) ConnectPPU(ppu IODevice) {
@@ -108,12 +138,76 @@ func (m *mmuImpl) Init(noBoot bool) {
 		if !m.cartridge.GBC {
 			m.lcdMode = 4
 		}
-		m.Write(consts.AddrBootmodeFlag, 0x01) // Disable Boot ROM.
-		m.Write(consts.AddrIRQFlags, 1)
+		m.write(consts.AddrBootmodeFlag, 0x01) // Disable Boot ROM.
+		m.write(consts.AddrIRQFlags, 1)
+		m.cartridge.SkipBoot()
+	}
+}
+
+func (m *mmuImpl) AddWatcher(w Watcher) {
+	for _, o := range m.watchers {
+		if o == w {
+			return
+		}
 	}
+	// the slice is replaced, so a read which is notifying the watchers is not affected.
+	m.watchers = append(m.watchers[:len(m.watchers):len(m.watchers)], w)
+}
+
+func (m *mmuImpl) RemoveWatcher(w Watcher) {
+	watchers := make([]Watcher, 0, len(m.watchers))
+	for _, o := range m.watchers {
+		if o != w {
+			watchers = append(watchers, o)
+		}
+	}
+	if len(watchers) == 0 {
+		watchers = nil
+	}
+	m.watchers = watchers
 }
 
 func (m *mmuImpl) Read(addr uint16) byte {
+	value := m.read(addr)
+	if m.cartBus != nil && addr < 0x8000 && !m.bootROMMapped(addr) {
+		m.cartBus.CPURead(addr)
+	}
+	for _, w := range m.watchers {
+		w.WatchRead(addr, value)
+	}
+	return value
+}
+
+func (m *mmuImpl) Peek(addr uint16) byte {
+	return m.read(addr)
+}
+
+func (m *mmuImpl) ROMBank(addr uint16) int {
+	if addr >= 0x8000 {
+		return -1
+	}
+	if m.bootROMMapped(addr) {
+		return -1
+	}
+	return m.cartridge.ROMBank(addr)
+}
+
+// bootROMMapped returns true if the boot rom hides the cartridge at addr.
+func (m *mmuImpl) bootROMMapped(addr uint16) bool {
+	if addr >= 0x4000 || m.read(consts.AddrBootmodeFlag) != 0x00 {
+		return false
+	}
+	switch m.hw {
+	case consts.GBC:
+		// the header of the cartridge is visible in the gbc boot rom.
+		return addr < uint16(len(GBC_BOOTROM)) && (addr < 0x0100 || addr > 0x014F)
+	case consts.DMG:
+		return addr < uint16(len(BOOTROM))
+	}
+	return false
+}
+
+func (m *mmuImpl) read(addr uint16) byte {
 	// [FF80-FFFE] Zero-page RAM
 	if addr >= 0xFF80 && addr < 0xFFFF {
 		return m.zpram[addr-0xFF80]
@@ -124,18 +218,11 @@ func (m *mmuImpl) Read(addr uint16) byte {
 	switch {
 	// [0000-3FFF] Cartridge ROM, bank 0
 	case addr >= 0x0000 && addr <= 0x3FFF:
-		if m.Read(consts.AddrBootmodeFlag) == 0x00 {
-			switch m.hw {
-			case consts.GBC:
-				// if in gbc mode and reading Cartridge header then read from card...
-				if addr < uint16(len(GBC_BOOTROM)) && (addr < 0x0100 || addr > 0x014F) {
-					return GBC_BOOTROM[addr]
-				}
-			case consts.DMG:
-				if addr < uint16(len(BOOTROM)) {
-					return BOOTROM[addr]
-				}
+		if m.bootROMMapped(addr) {
+			if m.hw == consts.GBC {
+				return GBC_BOOTROM[addr]
 			}
+			return BOOTROM[addr]
 		}
 		return m.cartridge.Read(addr)
 	// [4000-7FFF] Cartridge ROM, other banks
@@ -165,6 +252,15 @@ func (m *mmuImpl) Read(addr uint16) byte {
 }
 
 func (m *mmuImpl) Write(addr uint16, value byte) {
+	for _, w := range m.watchers {
+		w.WatchWrite(addr, value)
+	}
+	m.write(addr, value)
+}
+
+// write writes a value without notifying the watchers. It is used for the
+// accesses of the hardware itself, which are not visible to watchpoints.
+func (m *mmuImpl) write(addr uint16, value byte) {
 	// [FF80-FFFE] Zero-page RAM
 	if addr >= 0xFF80 && addr < 0xFFFF {
 		m.zpram[addr-0xFF80] = value
@@ -194,7 +290,7 @@ func (m *mmuImpl) Write(addr uint16, value byte) {
 		m.ppu.Write(addr, value)
 	// [FF00-FF7F] Memory-mapped I/O
 	case (addr >= 0xFF00 && addr <= 0xFF7F) || addr == 0xFFFF:
-		if addr == consts.AddrLCDMODE && m.HardwareCompat() == consts.GBC && m.Read(consts.AddrBootmodeFlag) == 0x00 {
+		if addr == consts.AddrLCDMODE && m.HardwareCompat() == consts.GBC && m.read(consts.AddrBootmodeFlag) == 0x00 {
 			m.lcdMode = value
 		}
 
@@ -205,15 +301,15 @@ func (m *mmuImpl) Write(addr uint16, value byte) {
 }
 
 func (m *mmuImpl) RequestInterrupt(i IRQ) {
-	m.Write(consts.AddrIRQFlags, m.Read(consts.AddrIRQFlags)|byte(i))
+	m.write(consts.AddrIRQFlags, m.read(consts.AddrIRQFlags)|byte(i))
 }
 
 func (m *mmuImpl) GetCurrentIterrupt() IRQ {
-	i := IRQ(m.Read(consts.AddrIRQEnabled) & m.Read(consts.AddrIRQFlags))
+	i := IRQ(m.read(consts.AddrIRQEnabled) & m.read(consts.AddrIRQFlags))
 	handle := func(test IRQ) bool {
 		if i&test == test {
-			f := IRQ(m.Read(consts.AddrIRQFlags))
-			m.Write(consts.AddrIRQFlags, byte(f&(0xFF^test)))
+			f := IRQ(m.read(consts.AddrIRQFlags))
+			m.write(consts.AddrIRQFlags, byte(f&(0xFF^test)))
 			return true
 		}
 		return false
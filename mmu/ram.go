@@ -11,7 +11,7 @@ type workingRAM struct {
 	banks        []rambank
 }
 
-func newWorkingRAM(mmu MMU) IODevice {
+func newWorkingRAM(mmu MMU) *workingRAM {
 	wr := new(workingRAM)
 	wr.mmu = mmu
 
@@ -0,0 +1,54 @@
+package mmu
+
+import "github.com/boombuler/goboy2/savestate"
+
+// SaveState writes the state of the memory and all io devices owned by the mmu.
+func (m *mmuImpl) SaveState(w *savestate.Writer) {
+	w.Bytes(m.zpram[:])
+	w.U8(m.lcdMode)
+	w.U8(byte(*m.boot))
+	w.U8(byte(m.irq.flag))
+	w.U8(byte(m.irq.mask))
+
+	w.U16(m.dma.steps)
+	w.U16(m.dma.addr)
+	w.Bool(m.dma.block)
+
+	w.Int(m.ram.selectedBank)
+	for i := range m.ram.banks {
+		w.Bytes(m.ram.banks[i][:])
+	}
+
+	if r := m.gbcRegs; r != nil {
+		w.Bytes([]byte{r.reg6C, r.reg72, r.reg73, r.reg74, r.reg75})
+	}
+}
+
+// LoadState restores a state written by SaveState.
+func (m *mmuImpl) LoadState(r *savestate.Reader) {
+	r.Bytes(m.zpram[:])
+	m.lcdMode = r.U8()
+	*m.boot = bootMode(r.U8())
+	m.irq.flag = IRQ(r.U8())
+	m.irq.mask = IRQ(r.U8())
+
+	m.dma.steps = r.U16()
+	m.dma.addr = r.U16()
+	m.dma.block = r.Bool()
+	m.dma.ticking = false
+
+	m.ram.selectedBank = r.Int()
+	if m.ram.selectedBank < 1 || m.ram.selectedBank >= len(m.ram.banks) {
+		r.Failf("savestate: invalid ram bank %d", m.ram.selectedBank)
+		m.ram.selectedBank = 1
+	}
+	for i := range m.ram.banks {
+		r.Bytes(m.ram.banks[i][:])
+	}
+
+	if g := m.gbcRegs; g != nil {
+		var regs [5]byte
+		r.Bytes(regs[:])
+		g.reg6C, g.reg72, g.reg73, g.reg74, g.reg75 = regs[0], regs[1], regs[2], regs[3], regs[4]
+	}
+}
@@ -5,7 +5,9 @@ import (
 
 	"github.com/boombuler/goboy2/cartridge"
 	"github.com/boombuler/goboy2/consts"
+	"github.com/boombuler/goboy2/cpu"
 	"github.com/boombuler/goboy2/ppu"
+	"github.com/boombuler/goboy2/symbols"
 )
 
 func newNULLScreen(exitChan <-chan struct{}) chan<- *ppu.ScreenImage {
@@ -23,20 +25,24 @@ func newNULLScreen(exitChan <-chan struct{}) chan<- *ppu.ScreenImage {
 	return screen
 }
 
-func runMooneyeRom(card *cartridge.Cartridge, compat consts.HardwareCompat) {
+func runMooneyeRom(card *cartridge.Cartridge, syms *symbols.Table, compat consts.HardwareCompat) {
 	exitChan := make(chan struct{})
 
 	gb := NewGameBoy(card, newNULLScreen(exitChan), compat, exitChan)
-	gb.CPU.Dump = *dump
+	gb.SetSymbols(syms)
 	gb.APU.TestMode = true // no frame limiting, no audio output
-	gb.CPU.OnExecOpCode = func(oc string) {
-		if oc == "LD B, B" {
+	hook := gb.CPU.OnInstruction
+	gb.CPU.OnInstruction = func(i *cpu.Instruction) {
+		if i.Opcode == 0x40 && !i.Extended { // LD B, B
 			close(exitChan) // Test finished...
 		}
+		hook(i)
 	}
 	gb.Init(true)
+	stopTrace := startTraceFile(gb)
 
 	gb.Run()
+	stopTrace()
 	_, _, _, b, c, d, e, _, h, l := gb.CPU.GetRegisterValues()
 	if b != 3 || c != 5 || d != 8 || e != 13 || h != 21 || l != 34 {
 		os.Exit(1)
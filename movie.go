@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"sync/atomic"
+
+	"github.com/boombuler/goboy2/input"
+	"github.com/boombuler/goboy2/movie"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+type buttonEvent struct {
+	btn     input.Button
+	pressed bool
+}
+
+// HandleKeyEvent passes a key event of the frontend to the emulation. The
+// button change is applied by Run, so it can be assigned to an exact cycle.
+// It is safe to call while Run executes and never blocks.
+func (gb *GameBoy) HandleKeyEvent(pressed bool, key sdl.Keycode) {
+	btn, ok := gb.Input.ButtonForKey(key)
+	if !ok {
+		return
+	}
+	bit := uint32(1) << btn
+	for {
+		old := atomic.LoadUint32(&gb.heldButtons)
+		held := old &^ bit
+		if pressed {
+			held |= bit
+		}
+		if atomic.CompareAndSwapUint32(&gb.heldButtons, old, held) {
+			break
+		}
+	}
+	select {
+	case gb.buttons <- struct{}{}:
+	default:
+		// Run was already notified. While the debugger stops it, only the
+		// latest state of every button is kept until it continues.
+	}
+}
+
+// applyButtons presses and releases the buttons whose state changed since the
+// last call.
+func (gb *GameBoy) applyButtons() {
+	held := atomic.LoadUint32(&gb.heldButtons)
+	for btn := input.Button(0); btn < input.ButtonCount; btn++ {
+		if bit := uint32(1) << btn; (held^gb.appliedButtons)&bit != 0 {
+			gb.pressButton(buttonEvent{btn, held&bit != 0})
+		}
+	}
+	gb.appliedButtons = held
+}
+
+func (gb *GameBoy) pressButton(ev buttonEvent) {
+	if gb.playback != nil {
+		// the movie controls the input
+		return
+	}
+	gb.Input.SetButton(ev.btn, ev.pressed)
+	if gb.recorder != nil {
+		err := gb.recorder.Record(movie.Event{
+			Cycle:   gb.cycle,
+			Button:  ev.btn,
+			Pressed: ev.pressed,
+		})
+		if err != nil {
+			log.Println("recording failed:", err)
+			gb.StopRecording()
+		}
+	}
+}
+
+// StartRecording writes all button changes to the given output, until
+// StopRecording is called. It must be called before Run.
+func (gb *GameBoy) StartRecording(out io.Writer) error {
+	state := new(bytes.Buffer)
+	if err := gb.SaveState(state); err != nil {
+		return err
+	}
+	rec, err := movie.NewRecorder(out, movie.Header{
+		ROMChecksum: gb.cart.CRC32,
+		State:       state.Bytes(),
+	})
+	if err != nil {
+		return err
+	}
+	gb.recorder = rec
+	gb.cycle = 0
+	return nil
+}
+
+// StopRecording finishes a recording started with StartRecording.
+// It must not be called while Run is executing on another goroutine.
+func (gb *GameBoy) StopRecording() error {
+	if gb.recorder == nil {
+		return nil
+	}
+	err := gb.recorder.Close()
+	gb.recorder = nil
+	return err
+}
+
+// Play restores the initial state of the movie and replays its button changes.
+// While playing all other input is ignored. It must be called before Run.
+func (gb *GameBoy) Play(m *movie.Movie) error {
+	if m.ROMChecksum != gb.cart.CRC32 {
+		return fmt.Errorf("movie was recorded with a different rom")
+	}
+	if err := gb.LoadState(bytes.NewReader(m.State)); err != nil {
+		return err
+	}
+	gb.playback = m.Events
+	gb.cycle = 0
+	return nil
+}
+
+// playMovie applies all button changes of the current cycle.
+func (gb *GameBoy) playMovie() {
+	for len(gb.playback) > 0 && gb.playback[0].Cycle == gb.cycle {
+		ev := gb.playback[0]
+		gb.Input.SetButton(ev.Button, ev.Pressed)
+		if gb.playback = gb.playback[1:]; len(gb.playback) == 0 {
+			log.Println("movie finished")
+			gb.playback = nil
+		}
+	}
+}
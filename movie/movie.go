@@ -0,0 +1,142 @@
+// Package movie stores the input of an emulation run, so it can be replayed deterministically.
+//
+// A movie starts with a header containing the checksum of the rom and the save
+// state the recording started with. It is followed by all button changes, each
+// tagged with the emulated cycle it happened in.
+package movie
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/boombuler/goboy2/input"
+	"github.com/boombuler/goboy2/savestate"
+)
+
+const (
+	magic   = "GB2M"
+	version = 1
+
+	eventSize = 10
+)
+
+// Event is a single button change.
+type Event struct {
+	Cycle   uint64
+	Button  input.Button
+	Pressed bool
+}
+
+// Header contains the information needed to start the playback of a movie.
+type Header struct {
+	// ROMChecksum is the CRC32 of the rom the movie was recorded with.
+	ROMChecksum uint32
+	// State is the save state of the gameboy when the recording started.
+	State []byte
+}
+
+// Recorder writes a movie.
+type Recorder struct {
+	out       io.Writer
+	buf       *bufio.Writer
+	w         *savestate.Writer
+	lastCycle uint64
+}
+
+// NewRecorder writes the header to out and returns a recorder for the events.
+// The recorder has to be closed to make sure all events are written.
+func NewRecorder(out io.Writer, h Header) (*Recorder, error) {
+	rec := &Recorder{out: out}
+	rec.buf = bufio.NewWriter(out)
+	rec.w = savestate.NewWriter(rec.buf)
+	rec.w.Bytes([]byte(magic))
+	rec.w.U16(version)
+	rec.w.U32(h.ROMChecksum)
+	rec.w.Blob(h.State)
+	if err := rec.w.Err(); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Record appends an event to the movie. Events have to be recorded in the order they happened.
+func (rec *Recorder) Record(ev Event) error {
+	if ev.Cycle < rec.lastCycle {
+		return fmt.Errorf("movie: event at cycle %d recorded after cycle %d", ev.Cycle, rec.lastCycle)
+	}
+	rec.lastCycle = ev.Cycle
+	rec.w.U64(ev.Cycle)
+	rec.w.U8(byte(ev.Button))
+	rec.w.Bool(ev.Pressed)
+	return rec.w.Err()
+}
+
+// Close flushes the movie and closes the underlying writer if it is an io.Closer.
+func (rec *Recorder) Close() error {
+	err := rec.w.Err()
+	if err == nil {
+		err = rec.buf.Flush()
+	}
+	if c, ok := rec.out.(io.Closer); ok {
+		if cErr := c.Close(); err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+// Movie is a recording loaded into memory.
+type Movie struct {
+	Header
+	Events []Event
+}
+
+// Read loads a movie which was written by a Recorder.
+func Read(in io.Reader) (*Movie, error) {
+	br := bufio.NewReader(in)
+	r := savestate.NewReader(br)
+	m := new(Movie)
+
+	mg := make([]byte, len(magic))
+	r.Bytes(mg)
+	v := r.U16()
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	if string(mg) != magic {
+		return nil, fmt.Errorf("not a movie file")
+	}
+	if v != version {
+		return nil, fmt.Errorf("unsupported movie version %d", v)
+	}
+	m.ROMChecksum = r.U32()
+	m.State = r.Blob(-1)
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+
+	var buf [eventSize]byte
+	for {
+		_, err := io.ReadFull(br, buf[:])
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// A recording which was not closed properly may end with an incomplete event.
+			return m, nil
+		} else if err != nil {
+			return nil, err
+		}
+		ev := Event{
+			Cycle:   binary.LittleEndian.Uint64(buf[:8]),
+			Button:  input.Button(buf[8]),
+			Pressed: buf[9] != 0,
+		}
+		if n := len(m.Events); n > 0 && m.Events[n-1].Cycle > ev.Cycle {
+			return nil, fmt.Errorf("movie: events are not in order")
+		}
+		if ev.Button >= input.ButtonCount {
+			return nil, fmt.Errorf("movie: invalid button %d", ev.Button)
+		}
+		m.Events = append(m.Events, ev)
+	}
+}
@@ -0,0 +1,79 @@
+package movie
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/boombuler/goboy2/input"
+)
+
+func TestRoundTrip(t *testing.T) {
+	h := Header{ROMChecksum: 0xDEADBEEF, State: []byte("initial state")}
+	events := []Event{
+		{Cycle: 0, Button: input.ButtonStart, Pressed: true},
+		{Cycle: 100, Button: input.ButtonStart, Pressed: false},
+		{Cycle: 100, Button: input.ButtonA, Pressed: true},
+		{Cycle: 1 << 40, Button: input.ButtonA, Pressed: false},
+	}
+
+	buf := new(bytes.Buffer)
+	rec, err := NewRecorder(buf, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range events {
+		if err := rec.Record(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ROMChecksum != h.ROMChecksum || !bytes.Equal(m.State, h.State) {
+		t.Errorf("header was not restored: %08X %q", m.ROMChecksum, m.State)
+	}
+	if !reflect.DeepEqual(m.Events, events) {
+		t.Errorf("got events %v, expected %v", m.Events, events)
+	}
+}
+
+func TestRecordOrder(t *testing.T) {
+	rec, err := NewRecorder(new(bytes.Buffer), Header{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Record(Event{Cycle: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Record(Event{Cycle: 9}); err == nil {
+		t.Error("expected an error for an event in the past")
+	}
+}
+
+func TestReadIncomplete(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rec, _ := NewRecorder(buf, Header{State: []byte{1, 2, 3}})
+	rec.Record(Event{Cycle: 5, Button: input.ButtonB, Pressed: true})
+	rec.Record(Event{Cycle: 7, Button: input.ButtonB})
+	rec.Close()
+
+	// a recording which was not closed properly ends with a partial event.
+	data := buf.Bytes()[:buf.Len()-3]
+	m, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Events) != 1 || m.Events[0].Cycle != 5 {
+		t.Errorf("unexpected events %v", m.Events)
+	}
+
+	if _, err := Read(bytes.NewReader([]byte("GB2S\x01\x00"))); err == nil {
+		t.Error("expected an error for a file which is not a movie")
+	}
+}
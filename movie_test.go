@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/boombuler/goboy2/consts"
+	"github.com/boombuler/goboy2/input"
+	"github.com/boombuler/goboy2/movie"
+)
+
+func TestMovieReplay(t *testing.T) {
+	exitChan := make(chan struct{})
+	defer close(exitChan)
+
+	gb := newTestGameBoy(t, exitChan)
+	runFrames(gb, 1)
+	buf := new(bytes.Buffer)
+	if err := gb.StartRecording(buf); err != nil {
+		t.Fatal(err)
+	}
+	runFrames(gb, 1)
+	gb.pressButton(buttonEvent{input.ButtonStart, true})
+	runFrames(gb, 2)
+	gb.step()
+	gb.pressButton(buttonEvent{input.ButtonStart, false})
+	gb.pressButton(buttonEvent{input.ButtonA, true})
+	runFrames(gb, 1)
+	gb.pressButton(buttonEvent{input.ButtonA, false})
+	runFrames(gb, 1)
+	// the state of the opcodes is shared by all cpus, so the cpu has to be
+	// stopped at an instruction before the replay runs.
+	runToInstruction(gb)
+	if err := gb.StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+	cycles := gb.cycle
+	expected := componentStates(t, gb)
+
+	m, err := movie.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(m.Events))
+	}
+
+	replay := newTestGameBoy(t, exitChan)
+	if err := replay.Play(m); err != nil {
+		t.Fatal(err)
+	}
+	for replay.cycle < cycles {
+		replay.step()
+	}
+	if replay.playback != nil {
+		t.Errorf("%d events were not played", len(replay.playback))
+	}
+	got := componentStates(t, replay)
+	for i := range expected {
+		if !bytes.Equal(expected[i], got[i]) {
+			t.Errorf("component %d differs after the replay", i)
+		}
+	}
+
+	// the replay has to depend on the input.
+	m.Events = m.Events[:1]
+	replay = newTestGameBoy(t, exitChan)
+	if err := replay.Play(m); err != nil {
+		t.Fatal(err)
+	}
+	for replay.cycle < cycles {
+		replay.step()
+	}
+	runToInstruction(replay)
+	if bytes.Equal(expected[1], componentStates(t, replay)[1]) {
+		t.Error("the input did not change the memory")
+	}
+}
+
+func TestKeyEventsWhileStopped(t *testing.T) {
+	exitChan := make(chan struct{})
+	defer close(exitChan)
+	gb := newTestGameBoy(t, exitChan)
+
+	// Run does not take the events, like while the debugger stops it.
+	for i := 0; i < 20; i++ {
+		gb.HandleKeyEvent(true, input.DefaultKeymap.A)
+		gb.HandleKeyEvent(false, input.DefaultKeymap.A)
+	}
+	gb.HandleKeyEvent(true, input.DefaultKeymap.B)
+	gb.applyButtons()
+
+	gb.Input.Write(consts.AddrInput, 0x10)
+	if v := gb.Input.Read(consts.AddrInput) & 0x0F; v != 0x0D {
+		t.Errorf("expected only B to be held, got %X", v)
+	}
+}
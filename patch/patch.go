@@ -0,0 +1,295 @@
+// Package patch applies IPS, UPS and BPS patches to roms.
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrUnknownFormat = errors.New("patch: unknown patch format")
+	ErrCorrupt       = errors.New("patch: patch is corrupt")
+)
+
+var (
+	ipsMagic = []byte("PATCH")
+	ipsEOF   = []byte("EOF")
+	upsMagic = []byte("UPS1")
+	bpsMagic = []byte("BPS1")
+)
+
+// Extensions are the file extensions of the supported patch formats.
+var Extensions = []string{".ips", ".ups", ".bps"}
+
+// Find returns the first patch which is stored next to the rom and has the same
+// name as the rom. It returns an empty string if there is none.
+func Find(romFileName string) string {
+	base := romFileName[:len(romFileName)-len(filepath.Ext(romFileName))]
+	for _, ext := range Extensions {
+		if fi, err := os.Stat(base + ext); err == nil && !fi.IsDir() {
+			return base + ext
+		}
+	}
+	return ""
+}
+
+// Apply detects the format of the patch and returns the patched rom. The rom
+// itself is not changed.
+func Apply(rom, patch []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(patch, ipsMagic):
+		return ApplyIPS(rom, patch)
+	case bytes.HasPrefix(patch, upsMagic):
+		return ApplyUPS(rom, patch)
+	case bytes.HasPrefix(patch, bpsMagic):
+		return ApplyBPS(rom, patch)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// ApplyIPS applies an IPS patch. The format has no checksums.
+func ApplyIPS(rom, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, ipsMagic) {
+		return nil, ErrUnknownFormat
+	}
+	out := append([]byte(nil), rom...)
+	write := func(offset int, data []byte) {
+		if end := offset + len(data); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[offset:], data)
+	}
+
+	p := patch[len(ipsMagic):]
+	for {
+		if bytes.HasPrefix(p, ipsEOF) {
+			rest := p[len(ipsEOF):]
+			if len(rest) == 3 {
+				// the optional truncation extension follows the end marker.
+				size := int(rest[0])<<16 | int(rest[1])<<8 | int(rest[2])
+				if size < len(out) {
+					out = out[:size]
+				}
+				return out, nil
+			}
+			// "EOF" is also the offset 0x454F46, so it is only the end marker if
+			// it is not followed by a record. Other trailing data is ignored.
+			if _, _, _, ok := ipsRecord(p); !ok {
+				return out, nil
+			}
+		}
+		offset, data, rest, ok := ipsRecord(p)
+		if !ok {
+			return nil, ErrCorrupt
+		}
+		write(offset, data)
+		p = rest
+	}
+}
+
+// ipsRecord parses the IPS record at the start of p and returns the data to
+// write at offset and the rest of p. ok is false if the record is incomplete.
+func ipsRecord(p []byte) (offset int, data, rest []byte, ok bool) {
+	if len(p) < 5 {
+		return 0, nil, nil, false
+	}
+	offset = int(p[0])<<16 | int(p[1])<<8 | int(p[2])
+	size := int(binary.BigEndian.Uint16(p[3:]))
+	p = p[5:]
+	if size > 0 {
+		if len(p) < size {
+			return 0, nil, nil, false
+		}
+		return offset, p[:size], p[size:], true
+	}
+	// run length encoded record
+	if len(p) < 3 {
+		return 0, nil, nil, false
+	}
+	count := int(binary.BigEndian.Uint16(p))
+	return offset, bytes.Repeat(p[2:3], count), p[3:], true
+}
+
+// reader reads the variable length integers used by UPS and BPS.
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *reader) byte() byte {
+	if r.pos >= len(r.data) {
+		r.err = ErrCorrupt
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *reader) varint() int {
+	var value, shift uint64 = 0, 1
+	for r.err == nil {
+		x := r.byte()
+		value += uint64(x&0x7F) * shift
+		if x&0x80 != 0 {
+			break
+		}
+		shift <<= 7
+		value += shift
+		if shift > 1<<42 {
+			r.err = ErrCorrupt
+		}
+	}
+	return int(value)
+}
+
+// footer contains the checksums at the end of UPS and BPS patches.
+type footer struct {
+	source, target uint32
+}
+
+// readFooter validates the checksum of the patch and returns the remaining checksums.
+func readFooter(patch []byte) (footer, error) {
+	if len(patch) < 12 {
+		return footer{}, ErrCorrupt
+	}
+	f := patch[len(patch)-12:]
+	if crc32.ChecksumIEEE(patch[:len(patch)-4]) != binary.LittleEndian.Uint32(f[8:]) {
+		return footer{}, fmt.Errorf("patch: checksum of the patch does not match")
+	}
+	return footer{
+		source: binary.LittleEndian.Uint32(f[0:]),
+		target: binary.LittleEndian.Uint32(f[4:]),
+	}, nil
+}
+
+func (f footer) checkSource(rom []byte) error {
+	if crc32.ChecksumIEEE(rom) != f.source {
+		return fmt.Errorf("patch: the patch was made for a different rom")
+	}
+	return nil
+}
+
+func (f footer) checkTarget(out []byte) error {
+	if crc32.ChecksumIEEE(out) != f.target {
+		return fmt.Errorf("patch: checksum of the patched rom does not match")
+	}
+	return nil
+}
+
+// ApplyUPS applies an UPS patch and validates the checksums of the rom, the patch and the result.
+func ApplyUPS(rom, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, upsMagic) {
+		return nil, ErrUnknownFormat
+	}
+	f, err := readFooter(patch)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.checkSource(rom); err != nil {
+		return nil, err
+	}
+
+	r := &reader{data: patch[:len(patch)-12], pos: len(upsMagic)}
+	srcSize, dstSize := r.varint(), r.varint()
+	if r.err != nil || srcSize != len(rom) {
+		return nil, ErrCorrupt
+	}
+	out := make([]byte, dstSize)
+	copy(out, rom)
+	for ptr := 0; r.err == nil && r.pos < len(r.data); {
+		ptr += r.varint()
+		for r.err == nil {
+			x := r.byte()
+			if ptr < len(out) {
+				out[ptr] ^= x
+			}
+			ptr++
+			if x == 0 {
+				break
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return out, f.checkTarget(out)
+}
+
+// ApplyBPS applies a BPS patch and validates the checksums of the rom, the patch and the result.
+func ApplyBPS(rom, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, bpsMagic) {
+		return nil, ErrUnknownFormat
+	}
+	f, err := readFooter(patch)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.checkSource(rom); err != nil {
+		return nil, err
+	}
+
+	r := &reader{data: patch[:len(patch)-12], pos: len(bpsMagic)}
+	srcSize, dstSize := r.varint(), r.varint()
+	r.pos += r.varint() // skip the metadata
+	if r.err != nil || srcSize != len(rom) || r.pos > len(r.data) {
+		return nil, ErrCorrupt
+	}
+
+	out := make([]byte, 0, dstSize)
+	var srcRel, dstRel int
+	relative := func(offset int) int {
+		d := r.varint()
+		if d&1 != 0 {
+			return offset - d>>1
+		}
+		return offset + d>>1
+	}
+	for r.err == nil && r.pos < len(r.data) {
+		data := r.varint()
+		length := data>>2 + 1
+		if len(out)+length > dstSize {
+			return nil, ErrCorrupt
+		}
+		switch data & 3 {
+		case 0: // source read
+			if len(out)+length > len(rom) {
+				return nil, ErrCorrupt
+			}
+			out = append(out, rom[len(out):len(out)+length]...)
+		case 1: // target read
+			if r.pos+length > len(r.data) {
+				return nil, ErrCorrupt
+			}
+			out = append(out, r.data[r.pos:r.pos+length]...)
+			r.pos += length
+		case 2: // source copy
+			if srcRel = relative(srcRel); srcRel < 0 || srcRel+length > len(rom) {
+				return nil, ErrCorrupt
+			}
+			out = append(out, rom[srcRel:srcRel+length]...)
+			srcRel += length
+		case 3: // target copy, the ranges may overlap.
+			if dstRel = relative(dstRel); dstRel < 0 || dstRel >= len(out) {
+				return nil, ErrCorrupt
+			}
+			for i := 0; i < length; i++ {
+				out = append(out, out[dstRel])
+				dstRel++
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(out) != dstSize {
+		return nil, ErrCorrupt
+	}
+	return out, f.checkTarget(out)
+}
@@ -0,0 +1,147 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+func encodeVarint(v int) []byte {
+	var res []byte
+	for {
+		x := byte(v & 0x7F)
+		v >>= 7
+		if v == 0 {
+			return append(res, 0x80|x)
+		}
+		res = append(res, x)
+		v--
+	}
+}
+
+func withFooter(patch, src, dst []byte) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], crc32.ChecksumIEEE(src))
+	patch = append(patch, buf[:]...)
+	binary.LittleEndian.PutUint32(buf[:], crc32.ChecksumIEEE(dst))
+	patch = append(patch, buf[:]...)
+	binary.LittleEndian.PutUint32(buf[:], crc32.ChecksumIEEE(patch))
+	return append(patch, buf[:]...)
+}
+
+var (
+	source = []byte("Hello World!")
+	target = []byte("Hello Gameboy World!!!")
+)
+
+func TestVarint(t *testing.T) {
+	for _, v := range []int{0, 1, 127, 128, 255, 16511, 16512, 1 << 30} {
+		r := &reader{data: encodeVarint(v)}
+		if got := r.varint(); got != v || r.err != nil {
+			t.Errorf("Expected %d but got %d (%v)", v, got, r.err)
+		}
+	}
+}
+
+func TestIPS(t *testing.T) {
+	patch := []byte("PATCH")
+	patch = append(patch, 0, 0, 6, 0, 8)
+	patch = append(patch, "Gameboy "...)
+	patch = append(patch, 0, 0, 14, 0, 0, 0, 8, 'x')
+	patch = append(patch, "EOF"...)
+
+	out, err := Apply(source, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "Hello Gameboy xxxxxxxx"; string(out) != exp {
+		t.Errorf("Expected %q but got %q", exp, out)
+	}
+
+	out, err = Apply(source, append(patch, 0, 0, 5))
+	if err != nil || string(out) != "Hello" {
+		t.Errorf("Expected the rom to be truncated but got %q, %v", out, err)
+	}
+	if string(source) != "Hello World!" {
+		t.Errorf("Expected the source not to be changed")
+	}
+
+	// a record at the offset 0x454F46 looks like the end marker
+	big := make([]byte, 0x454F46)
+	out, err = Apply(big, []byte("PATCHEOF\x00\x00\x00\x03cEOF\x00\x01aEOF"))
+	if err != nil || len(out) != 0x454F49 || string(out[0x454F46:]) != "acc" {
+		t.Errorf("Expected the records at 454F46 to be applied but got %d bytes, %v", len(out), err)
+	}
+
+	// trailing data after the end marker is not a record
+	for _, trailer := range []string{"\n", "\x00\x00", "created by a patcher"} {
+		out, err = Apply(source, append(patch, trailer...))
+		if exp := "Hello Gameboy xxxxxxxx"; err != nil || string(out) != exp {
+			t.Errorf("Expected %q with trailer %q but got %q, %v", exp, trailer, out, err)
+		}
+	}
+}
+
+func TestUPS(t *testing.T) {
+	patch := []byte("UPS1")
+	patch = append(patch, encodeVarint(len(source))...)
+	patch = append(patch, encodeVarint(len(target))...)
+	// xor the differences starting at offset 6
+	patch = append(patch, encodeVarint(6)...)
+	for i := 6; i < len(target); i++ {
+		var s byte
+		if i < len(source) {
+			s = source[i]
+		}
+		if s^target[i] == 0 {
+			t.Fatal("test data must differ")
+		}
+		patch = append(patch, s^target[i])
+	}
+	patch = append(patch, 0)
+	patch = withFooter(patch, source, target)
+
+	out, err := Apply(source, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, target) {
+		t.Errorf("Expected %q but got %q", target, out)
+	}
+
+	if _, err := Apply([]byte("Hello Moon!!"), patch); err == nil {
+		t.Errorf("Expected an error for a different source")
+	}
+	patch[6] ^= 0xFF
+	if _, err := Apply(source, patch); err == nil {
+		t.Errorf("Expected an error for a corrupt patch")
+	}
+}
+
+func TestBPS(t *testing.T) {
+	patch := []byte("BPS1")
+	patch = append(patch, encodeVarint(len(source))...)
+	patch = append(patch, encodeVarint(len(target))...)
+	patch = append(patch, encodeVarint(0)...)
+	// source read "Hello "
+	patch = append(patch, encodeVarint((6-1)<<2|0)...)
+	// target read "Gameboy "
+	patch = append(patch, encodeVarint((8-1)<<2|1)...)
+	patch = append(patch, "Gameboy "...)
+	// source copy "World!" from offset 6
+	patch = append(patch, encodeVarint((6-1)<<2|2)...)
+	patch = append(patch, encodeVarint(6<<1)...)
+	// target copy "!!" from the last written byte
+	patch = append(patch, encodeVarint((2-1)<<2|3)...)
+	patch = append(patch, encodeVarint(19<<1)...)
+	patch = withFooter(patch, source, target)
+
+	out, err := Apply(source, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, target) {
+		t.Errorf("Expected %q but got %q", target, out)
+	}
+}
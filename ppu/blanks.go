@@ -42,8 +42,15 @@ func (vb *vblank) start(ppu *PPU) bool {
 	}
 	vb.ticks = 0
 	if int(ppu.ly) == consts.DisplayHeight {
-		ppu.screenOut <- ppu.curScreen
-		ppu.curScreen = newScreen()
+		select {
+		case ppu.screenOut <- ppu.curScreen:
+			ppu.curScreen = newScreen()
+		case _, _ = <-ppu.exitChan:
+			// the frames are no longer taken when the emulation stops.
+		}
+		if fn := ppu.OnVBlank; fn != nil {
+			fn()
+		}
 		ppu.requstLcdcInterrupt(liVBlank)
 		ppu.mmu.RequestInterrupt(mmu.IRQVBlank)
 	}
@@ -42,7 +42,10 @@ func dropFrames(output chan<- *ScreenImage, exitChan <-chan struct{}) chan<- *Sc
 }
 
 func newScreen() *ScreenImage {
-	return screenPool.Get().(*ScreenImage)
+	img := screenPool.Get().(*ScreenImage)
+	// a recycled image contains an older frame, which must not end up in a save state.
+	*img = ScreenImage{}
+	return img
 }
 
 type palette interface {
@@ -30,11 +30,16 @@ type PPU struct {
 
 	screenOut chan<- *ScreenImage
 	curScreen *ScreenImage
+	exitChan  <-chan struct{}
 
 	vram0  vRAM
 	vram1  vRAM
 	vramHi bool
 	oam    *oam
+
+	// OnVBlank is called when the ppu enters the vblank period, right before the
+	// vblank interrupt is requested.
+	OnVBlank func()
 }
 
 // New creates a new ppu and connects it to the given mmu
@@ -46,6 +51,7 @@ func New(mmu mmu.MMU, screen chan<- *ScreenImage, exitChan <-chan struct{}) *PPU
 		vram0:     newVRAM(),
 		oam:       newOAM(mmu.HardwareCompat() == consts.GBC),
 		screenOut: screen,
+		exitChan:  exitChan,
 		phaseIdx:  0,
 		bgPal:     new(gbPalette),
 		objPal:    new(gbPalette),
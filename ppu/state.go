@@ -0,0 +1,269 @@
+package ppu
+
+import (
+	"github.com/boombuler/goboy2/consts"
+	"github.com/boombuler/goboy2/savestate"
+)
+
+// SaveState writes the ppu registers, the video memory and the progress of the current frame.
+func (p *PPU) SaveState(w *savestate.Writer) {
+	w.U8(p.lcdc)
+	w.U8(byte(p.ie))
+	w.U8(p.ly)
+	w.U8(p.lyc)
+	w.U8(p.scrollY)
+	w.U8(p.scrollX)
+	w.U8(p.winX)
+	w.U8(p.winY)
+	w.U16(uint16(*p.bgPal))
+	w.U16(uint16(*p.objPal))
+
+	w.Bytes(p.vram0)
+	w.Bool(p.vramHi)
+	for _, s := range p.oam.data {
+		w.Bytes([]byte{s.y, s.x, s.tileID, s.flags})
+	}
+
+	w.Int(p.phaseIdx)
+	w.U16(p.ticksInLine)
+	w.Int(len(p.visibleSprites))
+	for _, s := range p.visibleSprites {
+		w.Int(s)
+	}
+	for _, ph := range p.phases {
+		ph.saveState(w)
+	}
+
+	w.Bool(p.curScreen != nil)
+	if p.curScreen != nil {
+		for _, c := range p.curScreen {
+			w.Bytes([]byte{c.R, c.G, c.B})
+		}
+	}
+
+	if p.mmu.HardwareCompat() == consts.GBC {
+		w.Bytes(p.vram1)
+		p.bgcPal.saveState(w)
+		p.obcPal.saveState(w)
+		p.dma.saveState(w)
+	}
+}
+
+// LoadState restores a state written by SaveState.
+func (p *PPU) LoadState(r *savestate.Reader) {
+	p.lcdc = r.U8()
+	p.ie = lcdInterrupts(r.U8()) & liALL
+	if p.ly = r.U8(); p.ly > 153 {
+		r.Failf("savestate: invalid ly %d", p.ly)
+		p.ly = 0
+	}
+	p.lyc = r.U8()
+	p.scrollY = r.U8()
+	p.scrollX = r.U8()
+	p.winX = r.U8()
+	p.winY = r.U8()
+	*p.bgPal = gbPalette(r.U16())
+	*p.objPal = gbPalette(r.U16())
+
+	r.Bytes(p.vram0)
+	p.vramHi = r.Bool()
+	var sprite [4]byte
+	for i := range p.oam.data {
+		r.Bytes(sprite[:])
+		p.oam.data[i] = spriteData{sprite[0], sprite[1], sprite[2], sprite[3]}
+	}
+
+	p.phaseIdx = r.Int()
+	if p.phaseIdx < 0 || p.phaseIdx >= len(p.phases) {
+		r.Failf("savestate: invalid ppu phase %d", p.phaseIdx)
+		p.phaseIdx = 0
+	}
+	p.ticksInLine = r.U16()
+	if cnt := r.Int(); cnt == 0 {
+		p.visibleSprites = nil
+	} else if cnt != visibleSpriteDataCount {
+		r.Failf("savestate: invalid sprite count %d", cnt)
+	} else {
+		p.visibleSprites = make([]int, cnt)
+		for i := range p.visibleSprites {
+			if p.visibleSprites[i] = r.Int(); p.visibleSprites[i] < -1 || p.visibleSprites[i] >= len(p.oam.data) {
+				r.Failf("savestate: invalid sprite index %d", p.visibleSprites[i])
+				p.visibleSprites[i] = -1
+			}
+		}
+	}
+	for _, ph := range p.phases {
+		ph.loadState(r)
+	}
+
+	if r.Bool() {
+		if p.curScreen == nil {
+			p.curScreen = newScreen()
+		}
+		var c [3]byte
+		for i := range p.curScreen {
+			r.Bytes(c[:])
+			p.curScreen[i] = RGB{c[0], c[1], c[2]}
+		}
+	} else if p.curScreen != nil {
+		FreeScreen(p.curScreen)
+		p.curScreen = nil
+	}
+
+	if p.mmu.HardwareCompat() == consts.GBC {
+		r.Bytes(p.vram1)
+		p.bgcPal.loadState(r)
+		p.obcPal.loadState(r)
+		p.dma.loadState(r)
+	}
+}
+
+func (p *gbcPalette) saveState(w *savestate.Writer) {
+	w.Int(p.idx)
+	w.Bool(p.autoInc)
+	for _, c := range p.data {
+		w.Bytes([]byte{c.R, c.G, c.B})
+	}
+}
+
+func (p *gbcPalette) loadState(r *savestate.Reader) {
+	p.idx = r.Int() & 0x3F
+	p.autoInc = r.Bool()
+	var c [3]byte
+	for i := range p.data {
+		r.Bytes(c[:])
+		p.data[i] = RGB{c[0], c[1], c[2]}
+	}
+}
+
+func (dma *vramDMA) saveState(w *savestate.Writer) {
+	w.U16(dma.hdma12)
+	w.U16(dma.hdma34)
+	w.U16(dma.src)
+	w.U16(dma.dest)
+	w.U8(dma.length)
+	w.Bool(dma.running)
+	w.Bool(dma.hdmaMode)
+	w.U8(byte(dma.ppuState))
+	w.Bool(dma.hblankHandled)
+	w.Int(dma.timer)
+}
+
+func (dma *vramDMA) loadState(r *savestate.Reader) {
+	dma.hdma12 = r.U16()
+	dma.hdma34 = r.U16()
+	dma.src = r.U16()
+	dma.dest = r.U16()
+	dma.length = r.U8()
+	dma.running = r.Bool()
+	dma.hdmaMode = r.Bool()
+	dma.ppuState = ppuState(r.U8())
+	dma.hblankHandled = r.Bool()
+	dma.timer = r.Int()
+}
+
+func (os *oamSearch) saveState(w *savestate.Writer) {
+	w.Bool(os.readY)
+	w.U8(os.spriteX)
+	w.U8(os.spriteY)
+	w.Int(os.resIdx)
+	w.Int(os.spriteIdx)
+}
+
+func (os *oamSearch) loadState(r *savestate.Reader) {
+	os.readY = r.Bool()
+	os.spriteX = r.U8()
+	os.spriteY = r.U8()
+	os.resIdx = r.Int()
+	os.spriteIdx = r.Int()
+	if os.spriteIdx < 0 || os.spriteIdx > 40 || os.resIdx < 0 {
+		r.Failf("savestate: invalid oam search state")
+		os.spriteIdx, os.resIdx = 0, 0
+	}
+}
+
+func (pt *pixelTransfer) saveState(w *savestate.Writer) {
+	w.Int(pt.dropped)
+	w.U8(pt.curX)
+	w.Bool(pt.wnd)
+
+	w.Bytes(pt.fifo.buffer)
+	for _, idx := range pt.fifo.oamIdx {
+		w.Int(idx)
+	}
+	w.Int(pt.fifo.len)
+	w.Int(pt.fifo.startIdx)
+
+	f := pt.fetcher
+	w.Bool(f.disabled)
+	w.U8(byte(f.state))
+	w.Bool(f.skipTick)
+	w.Bytes(f.pixBuffer)
+	w.U16(f.mapAddress)
+	w.U16(f.tileAddress)
+	w.U16(f.xOffset)
+	w.Bool(f.signedIDs)
+	w.U8(f.tileLine)
+	w.U8(f.tileID)
+	w.U8(byte(f.tileAttr))
+	w.U8(f.data1)
+	w.U8(f.data2)
+	w.Int(f.spriteIdx)
+	w.U8(f.spriteLine)
+	w.U8(f.spriteOffset)
+}
+
+func (pt *pixelTransfer) loadState(r *savestate.Reader) {
+	pt.dropped = r.Int()
+	pt.curX = r.U8()
+	pt.wnd = r.Bool()
+
+	r.Bytes(pt.fifo.buffer)
+	for i := range pt.fifo.oamIdx {
+		pt.fifo.oamIdx[i] = r.Int()
+	}
+	pt.fifo.len = r.Int()
+	pt.fifo.startIdx = r.Int()
+	if pt.fifo.len < 0 || pt.fifo.len > fifoBufferLen || pt.fifo.startIdx < 0 || pt.fifo.startIdx >= fifoBufferLen {
+		r.Failf("savestate: invalid pixel fifo state")
+		pt.fifo.len, pt.fifo.startIdx = 0, 0
+	}
+
+	f := pt.fetcher
+	f.disabled = r.Bool()
+	f.state = fetcherState(r.U8())
+	f.skipTick = r.Bool()
+	r.Bytes(f.pixBuffer)
+	f.mapAddress = r.U16()
+	f.tileAddress = r.U16()
+	f.xOffset = r.U16()
+	f.signedIDs = r.Bool()
+	f.tileLine = r.U8()
+	f.tileID = r.U8()
+	f.tileAttr = tileAttr(r.U8())
+	f.data1 = r.U8()
+	f.data2 = r.U8()
+	f.spriteIdx = r.Int()
+	f.spriteLine = r.U8()
+	f.spriteOffset = r.U8()
+	if f.spriteIdx < 0 || f.spriteIdx >= 40 {
+		r.Failf("savestate: invalid fetcher state")
+		f.spriteIdx = 0
+	}
+}
+
+func (hb *hblank) saveState(w *savestate.Writer) {
+	w.U16(hb.ticks)
+}
+
+func (hb *hblank) loadState(r *savestate.Reader) {
+	hb.ticks = r.U16()
+}
+
+func (vb *vblank) saveState(w *savestate.Writer) {
+	w.U16(vb.ticks)
+}
+
+func (vb *vblank) loadState(r *savestate.Reader) {
+	vb.ticks = r.U16()
+}
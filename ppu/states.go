@@ -1,5 +1,7 @@
 package ppu
 
+import "github.com/boombuler/goboy2/savestate"
+
 type lcdInterrupts byte
 
 const (
@@ -15,6 +17,8 @@ type ppuPhase interface {
 	state() ppuState
 	step(ppu *PPU) bool
 	start(ppu *PPU) bool
+	saveState(w *savestate.Writer)
+	loadState(r *savestate.Reader)
 }
 
 type ppuState byte
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/boombuler/goboy2/profiler"
+)
+
+// StartProfile attributes the cycles of the emulation to the routines of the
+// rom until StopProfile is called. It must be called before Run.
+func (gb *GameBoy) StartProfile() {
+	gb.profiler = profiler.New(gb.cart.ROMBank)
+	gb.profiler.SetSymbols(gb.symbols)
+}
+
+// StopProfile returns the profile. It must not be called while Run executes.
+func (gb *GameBoy) StopProfile() *profiler.Profiler {
+	p := gb.profiler
+	gb.profiler = nil
+	return p
+}
+
+// startProfileFile starts the profile requested by the command line flags. The
+// returned function writes the profile.
+func startProfileFile(gb *GameBoy) func() {
+	if *romProfile == "" {
+		return func() {}
+	}
+	f, err := os.Create(*romProfile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	gb.StartProfile()
+	return func() {
+		if _, err := gb.StopProfile().WriteTo(f); err != nil {
+			log.Println("could not write rom profile:", err)
+		}
+		f.Close()
+	}
+}
@@ -0,0 +1,332 @@
+// Package profiler measures where the program of the rom spends its cycles.
+//
+// The m-cycles of every instruction are attributed to its address and to the
+// call stack, which is reconstructed from the calls, restarts and interrupts
+// and their returns. The profile is written in the format of pprof, so it can
+// be viewed with go tool pprof:
+//
+//	go tool pprof -top game.pprof
+//	go tool pprof -http :8080 game.pprof
+//
+// Functions are named after the labels of the symbol file, or after the bank
+// and address of their first instruction.
+package profiler
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/boombuler/goboy2/cpu"
+	"github.com/boombuler/goboy2/symbols"
+)
+
+// location is an address in a rom bank. The bank of addresses outside of the rom is -1.
+type location struct {
+	bank int
+	addr uint16
+}
+
+func (l location) String() string {
+	if l.bank < 0 {
+		return fmt.Sprintf("%04X", l.addr)
+	}
+	return fmt.Sprintf("%02X:%04X", l.bank, l.addr)
+}
+
+// address returns a unique address for the location, the rom bank is stored
+// above the 16 bits of the address.
+func (l location) address() uint64 {
+	if l.bank <= 0 {
+		return uint64(l.addr)
+	}
+	return uint64(l.bank)<<16 | uint64(l.addr)
+}
+
+// frame is a node of the call tree. The same routine has different frames
+// for every path it is called on.
+type frame struct {
+	parent *frame
+	// site is the address of the call in the parent, or the address at which
+	// the parent was interrupted.
+	site location
+	// entry is the address of the first instruction of the routine.
+	entry    location
+	children map[[2]location]*frame
+}
+
+func (f *frame) child(site, entry location) *frame {
+	key := [2]location{site, entry}
+	c := f.children[key]
+	if c == nil {
+		if f.children == nil {
+			f.children = make(map[[2]location]*frame)
+		}
+		c = &frame{parent: f, site: site, entry: entry}
+		f.children[key] = c
+	}
+	return c
+}
+
+// sample is an instruction executed in a frame.
+type sample struct {
+	frame *frame
+	pc    location
+}
+
+// Profiler attributes the cycles of the emulation to the instructions and the
+// call stacks of the program.
+type Profiler struct {
+	romBank func(addr uint16) int
+	symbols *symbols.Table
+
+	start   time.Time
+	calls   cpu.CallStack
+	root    *frame
+	current *frame
+	samples map[sample]uint64
+
+	last      sample
+	lastCycle uint64
+}
+
+// New creates a profiler. romBank returns the rom bank which is mapped at an
+// address in 0000-7FFF.
+func New(romBank func(addr uint16) int) *Profiler {
+	p := &Profiler{
+		romBank: romBank,
+		start:   time.Now(),
+		samples: make(map[sample]uint64),
+	}
+	p.calls.OnCall = p.call
+	p.calls.OnReturn = p.ret
+	return p
+}
+
+// SetSymbols sets the labels which are used to name the functions.
+func (p *Profiler) SetSymbols(t *symbols.Table) {
+	p.symbols = t
+}
+
+func (p *Profiler) location(addr uint16) location {
+	if addr >= 0x8000 {
+		return location{-1, addr}
+	}
+	return location{p.romBank(addr), addr}
+}
+
+// Instruction has to be called by the OnInstruction hook of the cpu. The
+// cycles since the last instruction are attributed to the last instruction.
+func (p *Profiler) Instruction(i *cpu.Instruction) {
+	here := p.location(i.PC)
+	if p.root == nil {
+		p.root = &frame{entry: here}
+		p.current = p.root
+	} else {
+		p.samples[p.last] += i.Cycle - p.lastCycle
+	}
+	p.last, p.lastCycle = sample{p.current, here}, i.Cycle
+	p.calls.Instruction(i)
+}
+
+// Interrupt has to be called by the OnInterrupt hook of the cpu.
+func (p *Profiler) Interrupt(i *cpu.Interrupt) {
+	if p.root != nil {
+		p.calls.Interrupt(i)
+	}
+}
+
+// call enters the frame of a call below the current frame.
+func (p *Profiler) call(c *cpu.Call) {
+	p.current = p.current.child(location{c.CallerBank, c.Caller}, p.location(c.Target))
+}
+
+// ret returns to the parent of the current frame.
+func (p *Profiler) ret(c *cpu.Call) {
+	p.current = p.current.parent
+}
+
+// routineName returns the name of the routine starting at entry.
+func (p *Profiler) routineName(entry location) string {
+	bank := entry.bank
+	if bank < 0 {
+		bank = symbols.AnyBank
+	}
+	if name := p.symbols.Name(bank, entry.addr); name != "" {
+		return name
+	}
+	if name := p.symbols.Describe(bank, entry.addr); name != "" {
+		return name
+	}
+	return entry.String()
+}
+
+// stackLocation is an instruction in a function.
+type stackLocation struct {
+	pc       location
+	function location
+}
+
+// stack returns the instructions of the call stack of a sample, the innermost first.
+func (s sample) stack() []stackLocation {
+	res := []stackLocation{{s.pc, s.frame.entry}}
+	for f := s.frame; f.parent != nil; f = f.parent {
+		res = append(res, stackLocation{f.site, f.parent.entry})
+	}
+	return res
+}
+
+// WriteTo writes the gzip compressed profile.
+func (p *Profiler) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	gz := gzip.NewWriter(cw)
+	if _, err := gz.Write(p.encode()); err != nil {
+		return cw.n, err
+	}
+	err := gz.Close()
+	return cw.n, err
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(data []byte) (int, error) {
+	n, err := c.w.Write(data)
+	c.n += int64(n)
+	return n, err
+}
+
+// Field numbers of the messages of the pprof format.
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileMapping       = 3
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profileTimeNanos     = 9
+	profileDurationNanos = 10
+	profilePeriodType    = 11
+	profilePeriod        = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	mappingID           = 1
+	mappingMemoryLimit  = 3
+	mappingHasFunctions = 7
+
+	locationID        = 1
+	locationMappingID = 2
+	locationAddress   = 3
+	locationLine      = 4
+
+	lineFunctionID = 1
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+)
+
+// encode returns the profile as protocol buffer.
+func (p *Profiler) encode() []byte {
+	strs := []string{""}
+	strIndex := map[string]int64{"": 0}
+	str := func(s string) int64 {
+		i, ok := strIndex[s]
+		if !ok {
+			i = int64(len(strs))
+			strs = append(strs, s)
+			strIndex[s] = i
+		}
+		return i
+	}
+
+	var b protoBuffer
+	valueType := func(tag int) {
+		b.message(tag, func(m *protoBuffer) {
+			m.int64(valueTypeType, str("cycles"))
+			m.int64(valueTypeUnit, str("count"))
+		})
+	}
+	valueType(profileSampleType)
+
+	functions := make(map[location]uint64)
+	var functionOrder []location
+	locations := make(map[stackLocation]uint64)
+	var locationOrder []stackLocation
+	for s, cycles := range p.samples {
+		stack := s.stack()
+		ids := make([]uint64, len(stack))
+		for i, l := range stack {
+			id, ok := locations[l]
+			if !ok {
+				id = uint64(len(locations) + 1)
+				locations[l] = id
+				locationOrder = append(locationOrder, l)
+			}
+			if _, ok := functions[l.function]; !ok {
+				functions[l.function] = uint64(len(functions) + 1)
+				functionOrder = append(functionOrder, l.function)
+			}
+			ids[i] = id
+		}
+		b.message(profileSample, func(m *protoBuffer) {
+			m.uint64s(sampleLocationID, ids)
+			m.uint64s(sampleValue, []uint64{cycles})
+		})
+	}
+
+	b.message(profileMapping, func(m *protoBuffer) {
+		m.uint64(mappingID, 1)
+		m.uint64(mappingMemoryLimit, 1<<32)
+		m.bool(mappingHasFunctions, true)
+	})
+	for _, l := range locationOrder {
+		b.message(profileLocation, func(m *protoBuffer) {
+			m.uint64(locationID, locations[l])
+			m.uint64(locationMappingID, 1)
+			m.uint64(locationAddress, l.pc.address())
+			m.message(locationLine, func(line *protoBuffer) {
+				line.uint64(lineFunctionID, functions[l.function])
+			})
+		})
+	}
+	for _, f := range functionOrder {
+		b.message(profileFunction, func(m *protoBuffer) {
+			m.uint64(functionID, functions[f])
+			m.int64(functionName, str(p.routineName(f)))
+			m.int64(functionSystemName, str(f.String()))
+		})
+	}
+
+	b.int64(profileTimeNanos, p.start.UnixNano())
+	b.int64(profileDurationNanos, time.Since(p.start).Nanoseconds())
+	valueType(profilePeriodType)
+	b.int64(profilePeriod, 1)
+	for _, s := range strs {
+		b.string(profileStringTable, s)
+	}
+	return b.data
+}
+
+// stacks returns the cycles of the call stacks, which are named like "Main;Update".
+func (p *Profiler) stacks() map[string]uint64 {
+	res := make(map[string]uint64)
+	for s, cycles := range p.samples {
+		stack := s.stack()
+		names := make([]string, len(stack))
+		for i, l := range stack {
+			names[len(stack)-1-i] = p.routineName(l.function)
+		}
+		res[strings.Join(names, ";")] += cycles
+	}
+	return res
+}
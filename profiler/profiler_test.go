@@ -0,0 +1,79 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/boombuler/goboy2/cpu"
+	"github.com/boombuler/goboy2/mmu"
+	"github.com/boombuler/goboy2/symbols"
+)
+
+const testSymbols = `00:0040 VBlank
+00:0100 Main
+00:0200 Sub
+`
+
+func TestCallStacks(t *testing.T) {
+	p := New(func(addr uint16) int { return 0 })
+	syms, err := symbols.Read(strings.NewReader(testSymbols))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetSymbols(syms)
+	instruction := func(pc, sp uint16, cycle uint64, code ...byte) {
+		i := cpu.Instruction{PC: pc, Opcode: code[0], Cycle: cycle, Registers: cpu.Registers{PC: pc, SP: sp}}
+		i.OperandCount = copy(i.Operands[:], code[1:])
+		p.Instruction(&i)
+	}
+
+	instruction(0x0100, 0xFFFE, 0, 0xCD, 0x00, 0x02) // CALL 0200
+	instruction(0x0200, 0xFFFC, 6, 0x00)             // NOP
+	instruction(0x0201, 0xFFFC, 7, 0xC9)             // RET
+	instruction(0x0103, 0xFFFE, 11, 0xC5)            // PUSH BC
+	p.Interrupt(&cpu.Interrupt{IRQ: mmu.IRQVBlank, Vector: 0x0040, Return: 0x0104, SP: 0xFFFA, Cycle: 20})
+	instruction(0x0040, 0xFFFA, 20, 0xD9) // RETI
+	instruction(0x0104, 0xFFFC, 24, 0xC1) // POP BC
+	instruction(0x0105, 0xFFFE, 27, 0x00)
+
+	expected := map[string]uint64{
+		"Main":        6 + 9 + 3,
+		"Main;Sub":    1 + 4,
+		"Main;VBlank": 4,
+	}
+	stacks := p.stacks()
+	if len(stacks) != len(expected) {
+		t.Errorf("got %v, expected %v", stacks, expected)
+	}
+	for stack, cycles := range expected {
+		if stacks[stack] != cycles {
+			t.Errorf("%s: got %d cycles, expected %d", stack, stacks[stack], cycles)
+		}
+	}
+
+	p.SetSymbols(nil)
+	if _, ok := p.stacks()["00:0100;00:0200"]; !ok {
+		t.Errorf("got %v without symbols", p.stacks())
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := p.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"cycles", "00:0200"} {
+		if !bytes.Contains(data, []byte(s)) {
+			t.Errorf("the profile does not contain %q", s)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package profiler
+
+// protoBuffer encodes the protocol buffer messages of the pprof format.
+type protoBuffer struct {
+	data []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *protoBuffer) key(tag, wireType int) {
+	b.varint(uint64(tag)<<3 | uint64(wireType))
+}
+
+// uint64 writes a field, which is omitted if x is 0.
+func (b *protoBuffer) uint64(tag int, x uint64) {
+	if x != 0 {
+		b.key(tag, wireVarint)
+		b.varint(x)
+	}
+}
+
+func (b *protoBuffer) int64(tag int, x int64) {
+	b.uint64(tag, uint64(x))
+}
+
+func (b *protoBuffer) bool(tag int, x bool) {
+	if x {
+		b.uint64(tag, 1)
+	}
+}
+
+func (b *protoBuffer) bytes(tag int, data []byte) {
+	b.key(tag, wireBytes)
+	b.varint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+func (b *protoBuffer) string(tag int, s string) {
+	b.bytes(tag, []byte(s))
+}
+
+// uint64s writes a packed repeated field.
+func (b *protoBuffer) uint64s(tag int, xs []uint64) {
+	var packed protoBuffer
+	for _, x := range xs {
+		packed.varint(x)
+	}
+	b.bytes(tag, packed.data)
+}
+
+// message writes the message encoded by fn.
+func (b *protoBuffer) message(tag int, fn func(m *protoBuffer)) {
+	var m protoBuffer
+	fn(&m)
+	b.bytes(tag, m.data)
+}
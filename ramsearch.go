@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/boombuler/goboy2/consts"
+	"github.com/boombuler/goboy2/ramsearch"
+)
+
+const ramSearchHelp = `commands:
+  new                   start a new search with all bytes of the ram
+  changed, unchanged    keep the bytes which changed since the last command
+  increased, decreased  keep the bytes which increased or decreased
+  eq|ne|gt|lt value     keep the bytes which are equal, not equal, greater or less than value
+  list [n]              print the first n candidates (default 20)
+  set location value    write value to a location, like D123 or D123:2 for a wram bank
+  help                  print this help`
+
+// maxListedCandidates is the default number of candidates printed by list.
+const maxListedCandidates = 20
+
+var ramSearchComparisons = map[string]ramsearch.Comparison{
+	"eq":        ramsearch.Equal,
+	"ne":        ramsearch.NotEqual,
+	"gt":        ramsearch.Greater,
+	"lt":        ramsearch.Less,
+	"unchanged": ramsearch.Equal,
+	"changed":   ramsearch.NotEqual,
+	"increased": ramsearch.Greater,
+	"decreased": ramsearch.Less,
+}
+
+// runRAMSearch reads ram search commands from in until it is closed or the
+// emulation stops. The commands are executed between two frames.
+func (gb *GameBoy) runRAMSearch(in io.Reader, out io.Writer) {
+	regions := ramsearch.Regions(gb.MMU.HardwareCompat() == consts.GBC, gb.cart.RAMSize > 0)
+	search := ramsearch.New(regions)
+	started := false
+
+	fmt.Fprintln(out, "ram search, type help for a list of commands")
+	scanner := bufio.NewScanner(in)
+	for fmt.Fprint(out, "> "); scanner.Scan(); fmt.Fprint(out, "> ") {
+		args := strings.Fields(scanner.Text())
+		if len(args) == 0 {
+			continue
+		}
+		var err error
+		ok := gb.Exec(func() {
+			cmd := args[0]
+			cmp, isFilter := ramSearchComparisons[cmd]
+			switch {
+			case cmd == "help":
+				fmt.Fprintln(out, ramSearchHelp)
+			case cmd == "new":
+				search.Start(gb.MMU)
+				started = true
+				fmt.Fprintf(out, "%d candidates\n", search.Len())
+			case cmd == "set":
+				err = ramSearchSet(gb, args[1:])
+			case !started && (isFilter || cmd == "list"):
+				err = fmt.Errorf("no search started, use new")
+			case cmd == "list":
+				err = ramSearchList(search, args[1:], out)
+			case isFilter && len(args) == 1:
+				fmt.Fprintf(out, "%d candidates\n", search.FilterPrevious(gb.MMU, cmp))
+			case isFilter && len(args) == 2 && len(cmd) == 2:
+				var v uint64
+				if v, err = strconv.ParseUint(args[1], 0, 8); err == nil {
+					fmt.Fprintf(out, "%d candidates\n", search.FilterValue(gb.MMU, cmp, byte(v)))
+				}
+			default:
+				err = fmt.Errorf("invalid command %q, type help for a list of commands", scanner.Text())
+			}
+		})
+		if !ok {
+			return
+		}
+		if err != nil {
+			fmt.Fprintln(out, "error:", err)
+		}
+	}
+}
+
+func ramSearchList(search *ramsearch.Search, args []string, out io.Writer) error {
+	n := maxListedCandidates
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+		if v < 1 {
+			return fmt.Errorf("the count must be positive")
+		}
+		n = v
+	}
+	candidates := search.Candidates()
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	for _, c := range candidates[:n] {
+		fmt.Fprintf(out, "%-7s %3d (was %3d)\n", c.Location, c.Value, c.Previous)
+	}
+	if n < len(candidates) {
+		fmt.Fprintf(out, "... %d more\n", len(candidates)-n)
+	}
+	return nil
+}
+
+func ramSearchSet(gb *GameBoy, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: set location value")
+	}
+	var loc ramsearch.Location
+	addr := args[0]
+	if i := strings.IndexByte(addr, ':'); i >= 0 {
+		bank, err := strconv.ParseUint(addr[i+1:], 10, 3)
+		if err != nil {
+			return fmt.Errorf("invalid bank %q", addr[i+1:])
+		}
+		loc.Bank = byte(bank)
+		addr = addr[:i]
+	}
+	a, err := strconv.ParseUint(addr, 16, 16)
+	if err != nil {
+		return fmt.Errorf("invalid address %q", addr)
+	}
+	loc.Address = uint16(a)
+	v, err := strconv.ParseUint(args[1], 0, 8)
+	if err != nil {
+		return err
+	}
+	loc.Write(gb.MMU, byte(v))
+	return nil
+}
@@ -0,0 +1,173 @@
+// Package ramsearch finds variables in the ram of the gameboy by repeatedly
+// filtering the bytes of the ram by the way their values changed.
+package ramsearch
+
+import (
+	"fmt"
+
+	"github.com/boombuler/goboy2/consts"
+)
+
+// Memory is the address space which is searched.
+type Memory interface {
+	Read(addr uint16) byte
+	Write(addr uint16, value byte)
+}
+
+// Location is the address of a byte. Bank is the working ram bank of the Game
+// Boy Color for addresses in D000-DFFF and 0 for all other addresses.
+type Location struct {
+	Address uint16
+	Bank    byte
+}
+
+func (l Location) String() string {
+	if l.Bank != 0 {
+		return fmt.Sprintf("%04X:%d", l.Address, l.Bank)
+	}
+	return fmt.Sprintf("%04X", l.Address)
+}
+
+// Read returns the value of the location.
+func (l Location) Read(mem Memory) byte {
+	var res byte
+	l.access(mem, func() { res = mem.Read(l.Address) })
+	return res
+}
+
+// Write changes the value of the location.
+func (l Location) Write(mem Memory, value byte) {
+	l.access(mem, func() { mem.Write(l.Address, value) })
+}
+
+// access switches to the bank of the location while fn is executed.
+func (l Location) access(mem Memory, fn func()) {
+	if l.Bank == 0 {
+		fn()
+		return
+	}
+	bank := mem.Read(consts.AddrSVBK)
+	mem.Write(consts.AddrSVBK, l.Bank)
+	fn()
+	mem.Write(consts.AddrSVBK, bank)
+}
+
+// Region is a range of memory which is searched. End is inclusive.
+type Region struct {
+	Name       string
+	Start, End uint16
+	Bank       byte
+}
+
+// Regions returns the working ram, the high ram and optionally the cartridge ram.
+// The cartridge ram is searched in the currently selected bank only. On the Game
+// Boy Color all banks of the working ram are searched.
+func Regions(gbc, cartRAM bool) []Region {
+	var res []Region
+	if gbc {
+		res = append(res, Region{"WRAM0", 0xC000, 0xCFFF, 0})
+		for bank := byte(1); bank < 8; bank++ {
+			res = append(res, Region{fmt.Sprintf("WRAM%d", bank), 0xD000, 0xDFFF, bank})
+		}
+	} else {
+		res = append(res, Region{"WRAM", 0xC000, 0xDFFF, 0})
+	}
+	res = append(res, Region{"HRAM", 0xFF80, 0xFFFE, 0})
+	if cartRAM {
+		res = append(res, Region{"SRAM", 0xA000, 0xBFFF, 0})
+	}
+	return res
+}
+
+// Comparison is the relation a value must have to be kept by a filter.
+type Comparison int
+
+const (
+	Equal Comparison = iota
+	NotEqual
+	Greater
+	Less
+)
+
+func (c Comparison) match(a, b byte) bool {
+	switch c {
+	case Equal:
+		return a == b
+	case NotEqual:
+		return a != b
+	case Greater:
+		return a > b
+	case Less:
+		return a < b
+	}
+	return false
+}
+
+// Candidate is a location which matched all filters so far.
+type Candidate struct {
+	Location
+	// Value is the value read by the last filter, Previous the value before.
+	Value, Previous byte
+}
+
+// Search contains the candidates of a search.
+type Search struct {
+	regions    []Region
+	candidates []Candidate
+}
+
+// New creates a search over the given regions. Start has to be called before
+// the candidates can be filtered.
+func New(regions []Region) *Search {
+	return &Search{regions: regions}
+}
+
+// Start takes a snapshot of all bytes of the regions, which become the candidates.
+func (s *Search) Start(mem Memory) {
+	s.candidates = s.candidates[:0]
+	for _, r := range s.regions {
+		for addr := int(r.Start); addr <= int(r.End); addr++ {
+			loc := Location{uint16(addr), r.Bank}
+			v := loc.Read(mem)
+			s.candidates = append(s.candidates, Candidate{loc, v, v})
+		}
+	}
+}
+
+// Len returns the number of candidates.
+func (s *Search) Len() int {
+	return len(s.candidates)
+}
+
+// Candidates returns the remaining candidates. The slice must not be modified.
+func (s *Search) Candidates() []Candidate {
+	return s.candidates
+}
+
+func (s *Search) filter(mem Memory, keep func(c Candidate) bool) int {
+	res := s.candidates[:0]
+	for _, c := range s.candidates {
+		c.Previous, c.Value = c.Value, c.Read(mem)
+		if keep(c) {
+			res = append(res, c)
+		}
+	}
+	s.candidates = res
+	return len(res)
+}
+
+// FilterPrevious keeps the candidates whose current value has the given relation
+// to the value of the last snapshot. It returns the number of remaining candidates.
+func (s *Search) FilterPrevious(mem Memory, cmp Comparison) int {
+	return s.filter(mem, func(c Candidate) bool {
+		return cmp.match(c.Value, c.Previous)
+	})
+}
+
+// FilterValue keeps the candidates whose current value has the given relation
+// to value. It returns the number of remaining candidates.
+func (s *Search) FilterValue(mem Memory, cmp Comparison, value byte) int {
+	return s.filter(mem, func(c Candidate) bool {
+		return cmp.match(c.Value, value)
+	})
+}
@@ -0,0 +1,70 @@
+package ramsearch
+
+import (
+	"testing"
+
+	"github.com/boombuler/goboy2/consts"
+)
+
+// memory emulates the working ram banks of the Game Boy Color.
+type memory struct {
+	bank byte
+	ram  map[Location]byte
+}
+
+func (m *memory) loc(addr uint16) Location {
+	if addr >= 0xD000 && addr < 0xE000 {
+		return Location{addr, m.bank}
+	}
+	return Location{addr, 0}
+}
+
+func (m *memory) Read(addr uint16) byte {
+	if addr == consts.AddrSVBK {
+		return m.bank | 0xF8
+	}
+	return m.ram[m.loc(addr)]
+}
+
+func (m *memory) Write(addr uint16, value byte) {
+	if addr == consts.AddrSVBK {
+		m.bank = value & 0x07
+		return
+	}
+	m.ram[m.loc(addr)] = value
+}
+
+func TestSearch(t *testing.T) {
+	mem := &memory{bank: 1, ram: make(map[Location]byte)}
+	lives := Location{0xD123, 3}
+	score := Location{0xC010, 0}
+	lives.Write(mem, 3)
+	score.Write(mem, 10)
+	if mem.bank != 1 {
+		t.Fatalf("Expected the bank to be restored but got %d", mem.bank)
+	}
+
+	s := New(Regions(true, false))
+	s.Start(mem)
+	if exp := 0x1000*8 + 0x7F; s.Len() != exp {
+		t.Errorf("Expected %d candidates but got %d", exp, s.Len())
+	}
+	lives.Write(mem, 2)
+	score.Write(mem, 20)
+	if n := s.FilterPrevious(mem, NotEqual); n != 2 {
+		t.Errorf("Expected 2 changed bytes but got %d", n)
+	}
+	if n := s.FilterPrevious(mem, Equal); n != 2 {
+		t.Errorf("Expected 2 unchanged bytes but got %d", n)
+	}
+	lives.Write(mem, 1)
+	if n := s.FilterPrevious(mem, Less); n != 1 || s.Candidates()[0].Location != lives {
+		t.Errorf("Expected lives to be found but got %v", s.Candidates())
+	}
+	if n := s.FilterValue(mem, Equal, 1); n != 1 {
+		t.Errorf("Expected lives to have the value 1")
+	}
+	if c := s.Candidates()[0]; c.String() != "D123:3" {
+		t.Errorf("Unexpected location %s", c)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRAMSearchList(t *testing.T) {
+	exitChan := make(chan struct{})
+	gb := newTestGameBoy(t, exitChan)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		gb.run()
+	}()
+
+	out := new(bytes.Buffer)
+	gb.runRAMSearch(strings.NewReader("new\nlist -1\nlist 0\nlist 2\n"), out)
+	close(exitChan)
+	<-done
+	// the state of the opcodes is shared by all cpus, so the cpu has to be
+	// stopped at an instruction before the next test starts.
+	runToInstruction(gb)
+
+	res := out.String()
+	if n := strings.Count(res, "error: the count must be positive"); n != 2 {
+		t.Errorf("expected 2 errors for the invalid counts, got %d:\n%s", n, res)
+	}
+	if !strings.Contains(res, "C000 ") || !strings.Contains(res, "C001 ") || strings.Contains(res, "C002 ") {
+		t.Errorf("expected the first 2 candidates:\n%s", res)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"sync/atomic"
+
+	"github.com/boombuler/goboy2/rewind"
+)
+
+// frameCycles is the number of ppu steps of a complete frame.
+const frameCycles = 70224 / 4
+
+// EnableRewind starts taking a snapshot every interval frames. The
+// snapshots are kept until they exceed the given memory budget in bytes.
+// It must be called before Run.
+func (gb *GameBoy) EnableRewind(interval, budget int) {
+	gb.rewindInterval = interval
+	gb.rewind = rewind.New(budget)
+}
+
+// SetRewinding starts or stops stepping back in time. While rewinding the
+// gameboy restores one snapshot every interval frames, so it steps back at
+// the speed it runs forward. It is safe to call while Run executes.
+func (gb *GameBoy) SetRewinding(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&gb.rewinding, v)
+}
+
+// rewindFrame takes or restores a snapshot at the end of a frame.
+func (gb *GameBoy) rewindFrame() {
+	if gb.rewind == nil {
+		return
+	}
+	if atomic.LoadInt32(&gb.rewinding) != 0 {
+		if gb.rewindWait > 0 {
+			gb.rewindWait--
+			return
+		}
+		gb.rewindWait = gb.rewindInterval - 1
+		state := gb.rewind.Pop()
+		if state == nil {
+			return
+		}
+		if gb.rewind.Len() == 0 {
+			// keep the oldest state, so it is possible to rewind to it again.
+			gb.rewind.Push(state)
+		}
+		if err := gb.LoadState(bytes.NewReader(state)); err != nil {
+			log.Println("rewind failed:", err)
+			gb.rewind.Clear()
+		}
+		gb.frames = 0
+		return
+	}
+	gb.rewindWait = 0
+
+	if gb.frames++; gb.frames >= gb.rewindInterval {
+		gb.frames = 0
+		buf := new(bytes.Buffer)
+		if err := gb.SaveState(buf); err != nil {
+			log.Println("rewind snapshot failed:", err)
+			return
+		}
+		gb.rewind.Push(buf.Bytes())
+	}
+}
@@ -0,0 +1,150 @@
+// Package rewind keeps a history of save states which allows to step back in time.
+//
+// Only the newest state is kept as it is. All older states are stored as the
+// compressed xor difference to their successor. Since only a small part of the
+// emulator state changes within a few frames, the differences compress very well.
+package rewind
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io/ioutil"
+	"log"
+)
+
+type delta struct {
+	size int
+	data []byte
+}
+
+// Buffer is a ring buffer of save states with a limited memory budget.
+// If the budget is exceeded the oldest states are dropped.
+type Buffer struct {
+	budget int
+	used   int
+	head   []byte
+	deltas []delta // oldest first
+
+	comp    *flate.Writer
+	compBuf bytes.Buffer
+	xorBuf  []byte
+}
+
+// New creates a new buffer which uses at most budget bytes. The newest
+// state is always kept, even if it is bigger than the budget itself.
+func New(budget int) *Buffer {
+	b := &Buffer{budget: budget}
+	b.comp, _ = flate.NewWriter(&b.compBuf, flate.BestSpeed)
+	return b
+}
+
+// Len returns the number of states in the buffer.
+func (b *Buffer) Len() int {
+	if b.head == nil {
+		return 0
+	}
+	return len(b.deltas) + 1
+}
+
+// Size returns the number of bytes used by the buffer.
+func (b *Buffer) Size() int {
+	return b.used
+}
+
+// Push adds a new state to the buffer. The buffer takes ownership of the data.
+func (b *Buffer) Push(state []byte) {
+	if b.head != nil {
+		d := b.diff(b.head, state)
+		b.deltas = append(b.deltas, d)
+		b.used += len(d.data) - len(b.head)
+	}
+	b.head = state
+	b.used += len(state)
+
+	drop := 0
+	for b.used > b.budget && drop < len(b.deltas) {
+		b.used -= len(b.deltas[drop].data)
+		b.deltas[drop] = delta{}
+		drop++
+	}
+	b.deltas = b.deltas[drop:]
+}
+
+// Pop removes the newest state from the buffer and returns it.
+// It returns nil if the buffer is empty.
+func (b *Buffer) Pop() []byte {
+	res := b.head
+	if res == nil {
+		return nil
+	}
+	b.used -= len(res)
+	b.head = nil
+	if n := len(b.deltas); n > 0 {
+		d := b.deltas[n-1]
+		b.deltas[n-1] = delta{}
+		b.deltas = b.deltas[:n-1]
+		b.used -= len(d.data)
+
+		prev, err := b.restore(d, res)
+		if err != nil {
+			// can only happen if the buffer itself is broken, so drop the history.
+			log.Println("rewind:", err)
+			b.Clear()
+		} else {
+			b.head = prev
+			b.used += len(prev)
+		}
+	}
+	return res
+}
+
+// Clear removes all states from the buffer.
+func (b *Buffer) Clear() {
+	b.head = nil
+	b.deltas = nil
+	b.used = 0
+}
+
+// diff creates the delta which restores older from newer.
+func (b *Buffer) diff(older, newer []byte) delta {
+	if cap(b.xorBuf) < len(older) {
+		b.xorBuf = make([]byte, len(older))
+	}
+	x := b.xorBuf[:len(older)]
+	copy(x, older)
+	xor(x, newer)
+
+	b.compBuf.Reset()
+	b.comp.Reset(&b.compBuf)
+	b.comp.Write(x)
+	b.comp.Close()
+	return delta{
+		size: len(older),
+		data: append([]byte(nil), b.compBuf.Bytes()...),
+	}
+}
+
+// restore applies the delta to newer and returns the older state.
+func (b *Buffer) restore(d delta, newer []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(d.data))
+	defer r.Close()
+	older, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(older) != d.size {
+		return nil, fmt.Errorf("invalid state size %d", len(older))
+	}
+	xor(older, newer)
+	return older, nil
+}
+
+func xor(dst, src []byte) {
+	if len(src) > len(dst) {
+		src = src[:len(dst)]
+	}
+	for i, v := range src {
+		dst[i] ^= v
+	}
+}
@@ -0,0 +1,113 @@
+package rewind
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// states creates n states of the given size, which differ in a few bytes from
+// their predecessor.
+func states(n, size int) [][]byte {
+	rnd := rand.New(rand.NewSource(1))
+	res := make([][]byte, n)
+	cur := make([]byte, size)
+	rnd.Read(cur)
+	for i := range res {
+		for j := 0; j < 8; j++ {
+			cur[rnd.Intn(size)] = byte(rnd.Int())
+		}
+		res[i] = append([]byte(nil), cur...)
+	}
+	return res
+}
+
+func TestPushPop(t *testing.T) {
+	b := New(1 << 20)
+	if b.Pop() != nil {
+		t.Fatal("expected nil from an empty buffer")
+	}
+	st := states(20, 4096)
+	for _, s := range st {
+		b.Push(append([]byte(nil), s...))
+	}
+	if b.Len() != len(st) {
+		t.Fatalf("expected %d states, got %d", len(st), b.Len())
+	}
+	for i := len(st) - 1; i >= 0; i-- {
+		if got := b.Pop(); !bytes.Equal(got, st[i]) {
+			t.Fatalf("state %d was not restored", i)
+		}
+	}
+	if b.Len() != 0 || b.Size() != 0 || b.Pop() != nil {
+		t.Errorf("buffer is not empty: %d states, %d bytes", b.Len(), b.Size())
+	}
+}
+
+func TestDeltaSizes(t *testing.T) {
+	// states of different sizes have to be restored with their own size.
+	st := [][]byte{
+		[]byte("first state"),
+		[]byte("the second state is longer"),
+		[]byte("third"),
+	}
+	b := New(1 << 20)
+	for _, s := range st {
+		b.Push(append([]byte(nil), s...))
+	}
+	for i := len(st) - 1; i >= 0; i-- {
+		if got := b.Pop(); !bytes.Equal(got, st[i]) {
+			t.Errorf("state %d: got %q, expected %q", i, got, st[i])
+		}
+	}
+}
+
+func TestBudget(t *testing.T) {
+	const size, budget = 4096, 4096 + 1024
+	st := states(100, size)
+	b := New(budget)
+	for _, s := range st {
+		b.Push(append([]byte(nil), s...))
+		if b.Size() > budget {
+			t.Fatalf("buffer uses %d bytes, budget is %d", b.Size(), budget)
+		}
+	}
+	n := b.Len()
+	if n < 2 || n >= len(st) {
+		t.Fatalf("expected the oldest states to be dropped, got %d states", n)
+	}
+	// the remaining states are the newest ones.
+	for i := len(st) - 1; i >= len(st)-n; i-- {
+		if got := b.Pop(); !bytes.Equal(got, st[i]) {
+			t.Fatalf("state %d was not restored", i)
+		}
+	}
+	if b.Len() != 0 {
+		t.Errorf("expected an empty buffer, got %d states", b.Len())
+	}
+}
+
+func TestNewestExceedsBudget(t *testing.T) {
+	b := New(16)
+	st := states(3, 64)
+	for _, s := range st {
+		b.Push(append([]byte(nil), s...))
+	}
+	if b.Len() != 1 {
+		t.Fatalf("expected only the newest state, got %d states", b.Len())
+	}
+	if got := b.Pop(); !bytes.Equal(got, st[2]) {
+		t.Error("the newest state was not kept")
+	}
+}
+
+func TestClear(t *testing.T) {
+	b := New(1 << 20)
+	for _, s := range states(5, 128) {
+		b.Push(s)
+	}
+	b.Clear()
+	if b.Len() != 0 || b.Size() != 0 || b.Pop() != nil {
+		t.Error("buffer was not cleared")
+	}
+}
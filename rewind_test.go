@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestRewindSpeed(t *testing.T) {
+	exitChan := make(chan struct{})
+	defer close(exitChan)
+	gb := newTestGameBoy(t, exitChan)
+	gb.EnableRewind(10, 1<<20)
+
+	runFrames(gb, 30)
+	if n := gb.rewind.Len(); n != 3 {
+		t.Fatalf("got %d snapshots after 30 frames, expected 3", n)
+	}
+	gb.SetRewinding(true)
+	runFrames(gb, 1)
+	if n := gb.rewind.Len(); n != 2 {
+		t.Fatalf("got %d snapshots after the first rewound frame, expected 2", n)
+	}
+	runFrames(gb, 9)
+	if n := gb.rewind.Len(); n != 2 {
+		t.Fatalf("got %d snapshots after 10 rewound frames, expected 2", n)
+	}
+	runFrames(gb, 1)
+	if n := gb.rewind.Len(); n != 1 {
+		t.Fatalf("got %d snapshots after 11 rewound frames, expected 1", n)
+	}
+	gb.SetRewinding(false)
+	runToInstruction(gb)
+}
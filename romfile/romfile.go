@@ -0,0 +1,130 @@
+// Package romfile reads roms from plain files and from zip or gzip archives.
+package romfile
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	gzipMagic = []byte{0x1F, 0x8B}
+)
+
+// Extensions are the file extensions of roms within archives.
+var Extensions = []string{".gb", ".gbc", ".cgb", ".sgb"}
+
+// ROM is a rom read by Open.
+type ROM struct {
+	Data []byte
+	// Name is the path other files of the rom, like save files or patches, are
+	// derived from. For archives it is based on the name of the archive.
+	Name string
+}
+
+// Open reads the rom from the given file. Archives are detected by their content.
+// If a zip archive contains more than one rom, entry selects the rom.
+func Open(fileName, entry string) (*ROM, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(data, zipMagic):
+		return openZip(fileName, data, entry)
+	case bytes.HasPrefix(data, gzipMagic):
+		return openGzip(fileName, data)
+	}
+	return &ROM{Data: data, Name: fileName}, nil
+}
+
+func isROM(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+func openGzip(fileName string, data []byte) (*ROM, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	rom, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	name := fileName
+	if strings.EqualFold(filepath.Ext(name), ".gz") {
+		// game.gb.gz uses the same files as game.gb
+		name = name[:len(name)-len(".gz")]
+	}
+	return &ROM{Data: rom, Name: name}, nil
+}
+
+func openZip(fileName string, data []byte, entry string) (*ROM, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	var roms []*zip.File
+	for _, f := range zr.File {
+		if !f.FileInfo().IsDir() && isROM(f.Name) {
+			roms = append(roms, f)
+		}
+	}
+	sort.Slice(roms, func(i, j int) bool { return roms[i].Name < roms[j].Name })
+
+	var selected *zip.File
+	switch {
+	case entry != "":
+		for _, f := range zr.File {
+			if f.Name == entry || path.Base(f.Name) == entry {
+				selected = f
+				break
+			}
+		}
+		if selected == nil {
+			return nil, fmt.Errorf("%s does not contain %s", fileName, entry)
+		}
+	case len(roms) == 1:
+		selected = roms[0]
+	case len(roms) == 0:
+		return nil, fmt.Errorf("%s does not contain a rom", fileName)
+	default:
+		names := make([]string, len(roms))
+		for i, f := range roms {
+			names[i] = f.Name
+		}
+		return nil, fmt.Errorf("%s contains multiple roms, select one of: %s", fileName, strings.Join(names, ", "))
+	}
+
+	rc, err := selected.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	rom, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	name := fileName
+	if len(roms) > 1 {
+		// every rom of the archive gets its own files
+		base := path.Base(selected.Name)
+		name = strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "-" + base
+	}
+	return &ROM{Data: rom, Name: name}, nil
+}
@@ -0,0 +1,79 @@
+package romfile
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeZip(t *testing.T, name string, entries map[string]string) {
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for n, content := range entries {
+		w, err := zw.Create(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goboy2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	plain := filepath.Join(dir, "plain.gb")
+	ioutil.WriteFile(plain, []byte("plain"), 0666)
+
+	gz := filepath.Join(dir, "packed.gb.gz")
+	f, _ := os.Create(gz)
+	gw := gzip.NewWriter(f)
+	gw.Write([]byte("gzip"))
+	gw.Close()
+	f.Close()
+
+	single := filepath.Join(dir, "single.zip")
+	writeZip(t, single, map[string]string{"readme.txt": "text", "game.gbc": "zip"})
+	multi := filepath.Join(dir, "multi.zip")
+	writeZip(t, multi, map[string]string{"a/first.gb": "first", "second.gb": "second"})
+
+	testCases := []struct {
+		File, Entry, Data, Name string
+	}{
+		{plain, "", "plain", plain},
+		{gz, "", "gzip", filepath.Join(dir, "packed.gb")},
+		{single, "", "zip", single},
+		{multi, "first.gb", "first", filepath.Join(dir, "multi-first.gb")},
+		{multi, "second.gb", "second", filepath.Join(dir, "multi-second.gb")},
+	}
+	for _, tc := range testCases {
+		rom, err := Open(tc.File, tc.Entry)
+		if err != nil {
+			t.Errorf("%s: %v", tc.File, err)
+			continue
+		}
+		if string(rom.Data) != tc.Data || rom.Name != tc.Name {
+			t.Errorf("%s: Expected %q named %s but got %q named %s", tc.File, tc.Data, tc.Name, rom.Data, rom.Name)
+		}
+	}
+
+	if _, err := Open(multi, ""); err == nil {
+		t.Errorf("Expected an error for an archive with multiple roms")
+	}
+	if _, err := Open(single, "missing.gb"); err == nil {
+		t.Errorf("Expected an error for a missing entry")
+	}
+}
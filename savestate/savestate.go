@@ -0,0 +1,207 @@
+// Package savestate provides the binary encoding used to snapshot the emulator state.
+//
+// Writer and Reader keep the first error that occurs, so components can serialize
+// their fields without checking every single call. The caller checks Err() once
+// after all components have been processed.
+package savestate
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Stater is implemented by all components which are part of a save state.
+type Stater interface {
+	SaveState(w *Writer)
+	LoadState(r *Reader)
+}
+
+// Writer encodes values in little endian byte order.
+type Writer struct {
+	w   io.Writer
+	err error
+	buf [8]byte
+}
+
+// NewWriter creates a new state writer for the given output.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Err returns the first error that occurred while writing.
+func (w *Writer) Err() error {
+	return w.err
+}
+
+// Fail stores the given error, if there was no other error before.
+// Components use it to report a state which can not be written.
+func (w *Writer) Fail(err error) {
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// Failf is like Fail but formats the error message.
+func (w *Writer) Failf(format string, args ...interface{}) {
+	w.Fail(fmt.Errorf(format, args...))
+}
+
+func (w *Writer) write(b []byte) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = w.w.Write(b)
+}
+
+// U8 writes a single byte.
+func (w *Writer) U8(v byte) {
+	w.buf[0] = v
+	w.write(w.buf[:1])
+}
+
+// U16 writes a 16bit value.
+func (w *Writer) U16(v uint16) {
+	binary.LittleEndian.PutUint16(w.buf[:], v)
+	w.write(w.buf[:2])
+}
+
+// U32 writes a 32bit value.
+func (w *Writer) U32(v uint32) {
+	binary.LittleEndian.PutUint32(w.buf[:], v)
+	w.write(w.buf[:4])
+}
+
+// U64 writes a 64bit value.
+func (w *Writer) U64(v uint64) {
+	binary.LittleEndian.PutUint64(w.buf[:], v)
+	w.write(w.buf[:8])
+}
+
+// Int writes an int as 64bit value.
+func (w *Writer) Int(v int) {
+	w.U64(uint64(int64(v)))
+}
+
+// Bool writes a boolean as single byte.
+func (w *Writer) Bool(v bool) {
+	if v {
+		w.U8(1)
+	} else {
+		w.U8(0)
+	}
+}
+
+// Bytes writes the given data without any length information.
+func (w *Writer) Bytes(b []byte) {
+	w.write(b)
+}
+
+// Blob writes the given data prefixed by its length.
+func (w *Writer) Blob(b []byte) {
+	w.U32(uint32(len(b)))
+	w.write(b)
+}
+
+// Reader decodes the values written by a Writer.
+type Reader struct {
+	r   io.Reader
+	err error
+	buf [8]byte
+}
+
+// NewReader creates a new state reader for the given input.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// Err returns the first error that occurred while reading.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Fail stores the given error, if there was no other error before.
+// Components use it to reject invalid state data.
+func (r *Reader) Fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// Failf is like Fail but formats the error message.
+func (r *Reader) Failf(format string, args ...interface{}) {
+	r.Fail(fmt.Errorf(format, args...))
+}
+
+func (r *Reader) read(b []byte) {
+	if r.err != nil {
+		for i := range b {
+			b[i] = 0
+		}
+		return
+	}
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		r.err = err
+	}
+}
+
+// U8 reads a single byte.
+func (r *Reader) U8() byte {
+	r.read(r.buf[:1])
+	return r.buf[0]
+}
+
+// U16 reads a 16bit value.
+func (r *Reader) U16() uint16 {
+	r.read(r.buf[:2])
+	return binary.LittleEndian.Uint16(r.buf[:])
+}
+
+// U32 reads a 32bit value.
+func (r *Reader) U32() uint32 {
+	r.read(r.buf[:4])
+	return binary.LittleEndian.Uint32(r.buf[:])
+}
+
+// U64 reads a 64bit value.
+func (r *Reader) U64() uint64 {
+	r.read(r.buf[:8])
+	return binary.LittleEndian.Uint64(r.buf[:])
+}
+
+// Int reads an int which was written by Writer.Int.
+func (r *Reader) Int() int {
+	return int(int64(r.U64()))
+}
+
+// Bool reads a boolean.
+func (r *Reader) Bool() bool {
+	return r.U8() != 0
+}
+
+// Bytes fills the given buffer.
+func (r *Reader) Bytes(b []byte) {
+	r.read(b)
+}
+
+// Blob reads length prefixed data. If the length of the data does not
+// match the expected length the reader fails. A negative expected length
+// accepts data of any size up to maxBlobSize.
+func (r *Reader) Blob(expected int) []byte {
+	l := int(r.U32())
+	if r.err != nil {
+		return nil
+	}
+	if (expected >= 0 && l != expected) || l > maxBlobSize {
+		r.Failf("savestate: unexpected data length %d", l)
+		return nil
+	}
+	b := make([]byte, l)
+	r.read(b)
+	return b
+}
+
+const maxBlobSize = 16 << 20
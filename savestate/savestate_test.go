@@ -0,0 +1,75 @@
+package savestate
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriter(buf)
+	w.U8(0x12)
+	w.U16(0x3456)
+	w.U32(0x789ABCDE)
+	w.U64(0x0102030405060708)
+	w.Int(-42)
+	w.Bool(true)
+	w.Bool(false)
+	w.Bytes([]byte{1, 2, 3})
+	w.Blob([]byte("blob"))
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader(buf)
+	if v := r.U8(); v != 0x12 {
+		t.Errorf("U8: got %02X", v)
+	}
+	if v := r.U16(); v != 0x3456 {
+		t.Errorf("U16: got %04X", v)
+	}
+	if v := r.U32(); v != 0x789ABCDE {
+		t.Errorf("U32: got %08X", v)
+	}
+	if v := r.U64(); v != 0x0102030405060708 {
+		t.Errorf("U64: got %016X", v)
+	}
+	if v := r.Int(); v != -42 {
+		t.Errorf("Int: got %d", v)
+	}
+	if !r.Bool() || r.Bool() {
+		t.Error("Bool: wrong values")
+	}
+	b := make([]byte, 3)
+	if r.Bytes(b); !bytes.Equal(b, []byte{1, 2, 3}) {
+		t.Errorf("Bytes: got % X", b)
+	}
+	if v := r.Blob(4); string(v) != "blob" {
+		t.Errorf("Blob: got %q", v)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	r := NewReader(bytes.NewReader([]byte{1}))
+	r.U16()
+	if r.Err() != io.ErrUnexpectedEOF {
+		t.Errorf("expected unexpected EOF, got %v", r.Err())
+	}
+	// the first error is kept and all further values are zero.
+	r.Fail(io.ErrClosedPipe)
+	if r.Err() != io.ErrUnexpectedEOF || r.U8() != 0 {
+		t.Error("the first error has to be kept")
+	}
+
+	buf := new(bytes.Buffer)
+	w := NewWriter(buf)
+	w.Blob([]byte("data"))
+	r = NewReader(buf)
+	if r.Blob(3) != nil || r.Err() == nil {
+		t.Error("expected an error for a blob of the wrong length")
+	}
+}
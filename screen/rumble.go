@@ -0,0 +1,61 @@
+package screen
+
+import (
+	"log"
+	"sync/atomic"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+const rumbleStrength = 0.75
+
+// indicator shown in the upper right corner while the rumble motor is running
+// and there is no haptic device.
+var rumbleIndicator = sdl.Rect{X: winWidth - 6, Y: 2, W: 4, H: 4}
+
+// SetRumble switches the rumble feedback on or off. It is safe to call from any goroutine.
+func (s *Screen) SetRumble(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&s.rumble, v)
+}
+
+func (s *Screen) rumbling() bool {
+	return atomic.LoadInt32(&s.rumble) != 0
+}
+
+// openHaptic opens the first haptic device which supports rumbling. It returns
+// nil if there is none.
+func openHaptic() *sdl.Haptic {
+	if err := sdl.InitSubSystem(sdl.INIT_HAPTIC); err != nil {
+		log.Println("haptic feedback is not available:", err)
+		return nil
+	}
+	if n, err := sdl.NumHaptics(); err != nil || n == 0 {
+		return nil
+	}
+	h, err := sdl.HapticOpen(0)
+	if err != nil || h == nil {
+		return nil
+	}
+	if err := h.RumbleInit(); err != nil {
+		h.Close()
+		return nil
+	}
+	return h
+}
+
+// updateHaptic starts or stops the rumble effect of the haptic device.
+func updateHaptic(h *sdl.Haptic, on bool) {
+	var err error
+	if on {
+		err = h.RumblePlay(rumbleStrength, sdl.HAPTIC_INFINITY)
+	} else {
+		err = h.RumbleStop()
+	}
+	if err != nil {
+		log.Println("rumble failed:", err)
+	}
+}
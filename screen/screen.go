@@ -23,6 +23,7 @@ type Screen struct {
 	stop   chan struct{}
 	render chan *ppu.ScreenImage
 	input  chan interface{}
+	rumble int32
 }
 
 func Main(mainFn func(s *Screen, input <-chan interface{}, exitChan <-chan struct{})) {
@@ -52,9 +53,19 @@ func Main(mainFn func(s *Screen, input <-chan interface{}, exitChan <-chan struc
 	}
 	defer renderer.Destroy()
 	renderer.SetLogicalSize(winWidth, winHeight)
-	drawImageOnRenderer(nil, 0, 0, renderer)
+	drawImageOnRenderer(nil, 0, 0, false, renderer)
 
-	go mainFn(screen, screen.input, screen.stop)
+	haptic := openHaptic()
+	if haptic != nil {
+		defer haptic.Close()
+	}
+	rumbling := false
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mainFn(screen, screen.input, screen.stop)
+	}()
 
 	var texture *sdl.Texture
 	var dx, dy int32
@@ -80,8 +91,15 @@ func Main(mainFn func(s *Screen, input <-chan interface{}, exitChan <-chan struc
 
 	for {
 		handleEvents() // Handle events at least once per frame...
+		if r := screen.rumbling(); r != rumbling {
+			rumbling = r
+			if haptic != nil {
+				updateHaptic(haptic, r)
+			}
+		}
 		select {
 		case _, _ = <-screen.stop:
+			screen.wait(done)
 			return
 		case img := <-screen.render:
 			if texture != nil {
@@ -99,7 +117,7 @@ func Main(mainFn func(s *Screen, input <-chan interface{}, exitChan <-chan struc
 				dy = 0
 			}
 
-			drawImageOnRenderer(texture, dx, dy, renderer)
+			drawImageOnRenderer(texture, dx, dy, rumbling && haptic == nil, renderer)
 		default:
 			handleEvents()
 		}
@@ -124,7 +142,8 @@ func imgToTex(img *ppu.ScreenImage, renderer *sdl.Renderer) (tex *sdl.Texture, d
 	return tex, int32(bnds.Dx()), int32(bnds.Dy())
 }
 
-func drawImageOnRenderer(img *sdl.Texture, dx, dy int32, renderer *sdl.Renderer) {
+func drawImageOnRenderer(img *sdl.Texture, dx, dy int32, rumble bool, renderer *sdl.Renderer) {
+	renderer.SetDrawColor(0, 0, 0, 0xFF)
 	renderer.Clear()
 	if img != nil {
 		renderer.Copy(img,
@@ -132,9 +151,28 @@ func drawImageOnRenderer(img *sdl.Texture, dx, dy int32, renderer *sdl.Renderer)
 			&sdl.Rect{W: int32(winWidth), H: int32(winHeight)},
 		)
 	}
+	if rumble {
+		renderer.SetDrawColor(0xFF, 0, 0, 0xFF)
+		renderer.FillRect(&rumbleIndicator)
+	}
 	renderer.Present()
 }
 
+// wait waits until the main function returned, so it can save the battery and
+// write its files before the program exits. Frames are dropped meanwhile.
+func (s *Screen) wait(done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case img := <-s.render:
+			if img != nil {
+				ppu.FreeScreen(img)
+			}
+		}
+	}
+}
+
 func (s *Screen) Stop() {
 	close(s.stop)
 }
@@ -0,0 +1,19 @@
+package serial
+
+import "github.com/boombuler/goboy2/savestate"
+
+// SaveState writes the serial registers and the progress of a running transfer.
+func (s *Serial) SaveState(w *savestate.Writer) {
+	w.U8(s.sb)
+	w.U8(s.sc)
+	w.Bool(s.transferInProgress)
+	w.Int(s.divider)
+}
+
+// LoadState restores a state written by SaveState.
+func (s *Serial) LoadState(r *savestate.Reader) {
+	s.sb = r.U8()
+	s.sc = r.U8()
+	s.transferInProgress = r.Bool()
+	s.divider = r.Int()
+}
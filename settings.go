@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// romSettings are the settings of a single rom. They are read from a file with
+// the extension ".cfg" next to the rom. Each line of the file contains a
+// "key = value" pair, lines starting with "#" are ignored.
+type romSettings struct {
+	// Mapper overrides the mapper of the cartridge.
+	Mapper string
+}
+
+func settingsFileName(romName string) string {
+	return romName[:len(romName)-len(filepath.Ext(romName))] + ".cfg"
+}
+
+// loadSettings reads the settings of the rom. A missing file results in empty settings.
+func loadSettings(romName string) (*romSettings, error) {
+	res := new(romSettings)
+	fileName := settingsFileName(romName)
+	f, err := os.Open(fileName)
+	if os.IsNotExist(err) {
+		return res, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected key = value", fileName, lineNo)
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		switch strings.ToLower(key) {
+		case "mapper":
+			res.Mapper = value
+		default:
+			log.Printf("%s:%d: unknown setting %q is ignored", fileName, lineNo, key)
+		}
+	}
+	return res, scanner.Err()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/boombuler/goboy2/consts"
+	"github.com/boombuler/goboy2/savestate"
+)
+
+const (
+	stateMagic = "GB2S"
+	// stateVersion has to be increased whenever the layout of the state changes.
+	stateVersion uint16 = 4
+)
+
+// components returns all parts of the gameboy in the order they are stored in a save state.
+func (gb *GameBoy) components() []savestate.Stater {
+	return []savestate.Stater{
+		gb.CPU,
+		gb.MMU,
+		gb.PPU,
+		gb.APU,
+		gb.Timer,
+		gb.Serial,
+		gb.Input,
+		gb.cart.MBC,
+	}
+}
+
+// SaveState writes a snapshot of the complete emulator state.
+// It must not be called while Run is executing on another goroutine.
+func (gb *GameBoy) SaveState(out io.Writer) error {
+	buf := bufio.NewWriter(out)
+	w := savestate.NewWriter(buf)
+	w.Bytes([]byte(stateMagic))
+	w.U16(stateVersion)
+	w.U8(byte(gb.MMU.HardwareCompat()))
+	w.Blob([]byte(gb.cart.Title))
+
+	w.Bool(gb.dsTick)
+	for _, c := range gb.components() {
+		c.SaveState(w)
+	}
+	if err := w.Err(); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// LoadState restores a snapshot written by SaveState. The snapshot has to be
+// created with the same cartridge and hardware mode. If an error is returned
+// the gameboy is left in an undefined state.
+// It must not be called while Run is executing on another goroutine.
+func (gb *GameBoy) LoadState(in io.Reader) error {
+	r := savestate.NewReader(bufio.NewReader(in))
+	magic := make([]byte, len(stateMagic))
+	r.Bytes(magic)
+	version := r.U16()
+	hw := consts.HardwareCompat(r.U8())
+	title := string(r.Blob(-1))
+	if err := r.Err(); err != nil {
+		return err
+	}
+	switch {
+	case string(magic) != stateMagic:
+		return fmt.Errorf("not a save state")
+	case version != stateVersion:
+		return fmt.Errorf("unsupported save state version %d", version)
+	case hw != gb.MMU.HardwareCompat():
+		return fmt.Errorf("save state was created in a different hardware mode")
+	case title != gb.cart.Title:
+		return fmt.Errorf("save state was created for %q", title)
+	}
+
+	gb.dsTick = r.Bool()
+	for _, c := range gb.components() {
+		c.LoadState(r)
+	}
+	return r.Err()
+}
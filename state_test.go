@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/boombuler/goboy2/cartridge"
+	"github.com/boombuler/goboy2/consts"
+	"github.com/boombuler/goboy2/savestate"
+)
+
+var testProgram = map[uint16][]byte{
+	0x0040: {
+		0x04, // INC B
+		0xD9, // RETI
+	},
+	0x0100: {
+		0x00,             // NOP
+		0xC3, 0x50, 0x01, // JP 0150
+	},
+	0x0150: {
+		0x3E, 0x01, // LD A, 1
+		0xE0, 0xFF, // LDH (FF), A
+		0x3E, 0x10, // LD A, 10
+		0xE0, 0x00, // LDH (00), A
+		0x21, 0x00, 0xC0, // LD HL, C000
+		0xFB,       // EI
+		0xF0, 0x00, // LDH A, (00)
+		0xE6, 0x0F, // AND 0F
+		0x80,       // ADD A, B
+		0x86,       // ADD A, (HL)
+		0x22,       // LD (HL+), A
+		0xCB, 0xAC, // RES 5, H
+		0x18, 0xF5, // JR 015C
+	},
+}
+
+func newTestGameBoy(t *testing.T, exitChan <-chan struct{}) *GameBoy {
+	rom := make([]byte, 0x8000)
+	for addr, code := range testProgram {
+		copy(rom[addr:], code)
+	}
+	cart, err := cartridge.Load(bytes.NewReader(rom), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gb := NewGameBoy(cart, newNULLScreen(exitChan), consts.DMG, exitChan)
+	gb.APU.TestMode = true
+	gb.Init(true)
+	return gb
+}
+
+func runFrames(gb *GameBoy, frames int) {
+	for i := 0; i < frames*frameCycles; i++ {
+		gb.step()
+	}
+}
+
+// runToInstruction steps the gameboy until the cpu is at the start of an
+// instruction and returns the number of steps.
+func runToInstruction(gb *GameBoy) int {
+	n := 0
+	for ; !gb.CPU.AtInstruction(); n++ {
+		gb.step()
+	}
+	return n
+}
+
+// componentStates returns the serialized state of every component.
+func componentStates(t *testing.T, gb *GameBoy) [][]byte {
+	var res [][]byte
+	for _, c := range gb.components() {
+		buf := new(bytes.Buffer)
+		w := savestate.NewWriter(buf)
+		c.SaveState(w)
+		if err := w.Err(); err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, buf.Bytes())
+	}
+	return res
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	exitChan := make(chan struct{})
+	defer close(exitChan)
+
+	gb := newTestGameBoy(t, exitChan)
+	runFrames(gb, 3)
+	// save in the middle of an instruction to store the pipelined opcode.
+	for gb.CPU.AtInstruction() {
+		gb.step()
+	}
+	state := new(bytes.Buffer)
+	if err := gb.SaveState(state); err != nil {
+		t.Fatal(err)
+	}
+	runFrames(gb, 5)
+	// the state of the opcodes is shared by all cpus, so the cpu has to be
+	// stopped at an instruction before the next one runs.
+	extra := runToInstruction(gb)
+	expected := componentStates(t, gb)
+
+	restored := newTestGameBoy(t, exitChan)
+	if err := restored.LoadState(bytes.NewReader(state.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	runFrames(restored, 5)
+	for i := 0; i < extra; i++ {
+		restored.step()
+	}
+	got := componentStates(t, restored)
+
+	names := []string{"CPU", "MMU", "PPU", "APU", "Timer", "Serial", "Input", "MBC"}
+	for i := range expected {
+		if !bytes.Equal(expected[i], got[i]) {
+			t.Errorf("%s state differs after restoring the save state", names[i])
+		}
+	}
+	if b := gb.CPU.Registers().B; b == 0 {
+		t.Errorf("vblank interrupt was not handled")
+	}
+}
+
+func TestLoadStateRejectsOtherCartridge(t *testing.T) {
+	exitChan := make(chan struct{})
+	defer close(exitChan)
+
+	gb := newTestGameBoy(t, exitChan)
+	state := new(bytes.Buffer)
+	if err := gb.SaveState(state); err != nil {
+		t.Fatal(err)
+	}
+	other := newTestGameBoy(t, exitChan)
+	other.cart.Title = "OTHER"
+	if err := other.LoadState(state); err == nil {
+		t.Error("expected an error for a save state of another cartridge")
+	}
+}
@@ -0,0 +1,211 @@
+// Package symbols reads the symbol files written by the linker of RGBDS.
+//
+// Every line of a symbol file contains the bank and the address of a label,
+// followed by its name. Comments start with ";".
+//
+//	; File generated by rgblink
+//	00:0150 Start
+//	01:4000 Main
+//	01:4010 Main.loop
+package symbols
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Extension is the file extension of symbol files.
+const Extension = ".sym"
+
+// AnyBank matches the labels of all banks.
+const AnyBank = -1
+
+// Symbol is a label of the program.
+type Symbol struct {
+	Name    string
+	Bank    int
+	Address uint16
+}
+
+func (s Symbol) String() string {
+	return fmt.Sprintf("%02X:%04X %s", s.Bank, s.Address, s.Name)
+}
+
+// Table contains the labels of a symbol file. The methods of a nil table find no labels.
+type Table struct {
+	// symbols are sorted by bank and address.
+	symbols []Symbol
+	byName  map[string]Symbol
+	// byAddr contains the first label of every address, used for AnyBank.
+	byAddr map[uint16]string
+}
+
+// Find returns the name of the symbol file next to the rom, or "" if there is none.
+func Find(romFileName string) string {
+	fileName := romFileName[:len(romFileName)-len(filepath.Ext(romFileName))] + Extension
+	if fi, err := os.Stat(fileName); err == nil && !fi.IsDir() {
+		return fileName
+	}
+	return ""
+}
+
+// Load reads a symbol file.
+func Load(fileName string) (*Table, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	return t, nil
+}
+
+// Read parses a symbol file.
+func Read(r io.Reader) (*Table, error) {
+	t := &Table{byName: make(map[string]Symbol), byAddr: make(map[uint16]string)}
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, ';'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		sym, err := parseSymbol(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		t.symbols = append(t.symbols, sym)
+		if _, ok := t.byName[sym.Name]; !ok {
+			t.byName[sym.Name] = sym
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(t.symbols, func(i, j int) bool {
+		a, b := t.symbols[i], t.symbols[j]
+		if a.Bank != b.Bank {
+			return a.Bank < b.Bank
+		}
+		return a.Address < b.Address
+	})
+	for _, sym := range t.symbols {
+		if _, ok := t.byAddr[sym.Address]; !ok {
+			t.byAddr[sym.Address] = sym.Name
+		}
+	}
+	return t, nil
+}
+
+func parseSymbol(fields []string) (Symbol, error) {
+	loc := strings.SplitN(fields[0], ":", 2)
+	if len(fields) != 2 || len(loc) != 2 {
+		return Symbol{}, fmt.Errorf("expected bank:address label")
+	}
+	bank, err := strconv.ParseUint(loc[0], 16, 16)
+	if err != nil {
+		return Symbol{}, fmt.Errorf("invalid bank %q", loc[0])
+	}
+	addr, err := strconv.ParseUint(loc[1], 16, 16)
+	if err != nil {
+		return Symbol{}, fmt.Errorf("invalid address %q", loc[1])
+	}
+	return Symbol{Name: fields[1], Bank: int(bank), Address: uint16(addr)}, nil
+}
+
+// Len returns the number of labels.
+func (t *Table) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.symbols)
+}
+
+// Lookup returns the label with the given name.
+func (t *Table) Lookup(name string) (Symbol, bool) {
+	if t == nil {
+		return Symbol{}, false
+	}
+	s, ok := t.byName[name]
+	return s, ok
+}
+
+// search returns the index of the first label at or after bank:addr.
+func (t *Table) search(bank int, addr uint16) int {
+	return sort.Search(len(t.symbols), func(i int) bool {
+		s := t.symbols[i]
+		return s.Bank > bank || (s.Bank == bank && s.Address >= addr)
+	})
+}
+
+// Name returns the name of the label at an address, or "" if there is none.
+func (t *Table) Name(bank int, addr uint16) string {
+	if t == nil {
+		return ""
+	}
+	if bank == AnyBank {
+		return t.byAddr[addr]
+	}
+	if i := t.search(bank, addr); i < len(t.symbols) && t.symbols[i].Bank == bank && t.symbols[i].Address == addr {
+		return t.symbols[i].Name
+	}
+	return ""
+}
+
+// regionStarts are the start addresses of the memory regions. Labels are only
+// used for addresses in the same region.
+var regionStarts = []uint16{0x0000, 0x4000, 0x8000, 0xA000, 0xC000, 0xD000, 0xE000, 0xFE00, 0xFF00, 0xFF80, 0xFFFF}
+
+func region(addr uint16) int {
+	return sort.Search(len(regionStarts), func(i int) bool { return regionStarts[i] > addr })
+}
+
+// Describe returns the name of the label at an address. If there is none, the
+// nearest label before the address in the same memory region is returned with
+// an offset, like "Main+3". It returns "" if there is no such label. For
+// AnyBank only labels at the address are returned.
+func (t *Table) Describe(bank int, addr uint16) string {
+	if t == nil {
+		return ""
+	}
+	if bank == AnyBank {
+		return t.Name(bank, addr)
+	}
+	i := t.search(bank, addr)
+	if i < len(t.symbols) && t.symbols[i].Bank == bank && t.symbols[i].Address == addr {
+		return t.symbols[i].Name
+	}
+	if i == 0 {
+		return ""
+	}
+	s := t.symbols[i-1]
+	if s.Bank != bank || region(s.Address) != region(addr) {
+		return ""
+	}
+	return fmt.Sprintf("%s+%d", s.Name, addr-s.Address)
+}
+
+// Names returns a function which returns the label at an address. romBank
+// returns the rom bank which is mapped at an address in 0000-7FFF, other
+// addresses match the labels of all banks.
+func (t *Table) Names(romBank func(addr uint16) int) func(addr uint16) string {
+	return func(addr uint16) string {
+		bank := AnyBank
+		if addr < 0x8000 {
+			bank = romBank(addr)
+		}
+		return t.Name(bank, addr)
+	}
+}
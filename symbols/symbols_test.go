@@ -0,0 +1,69 @@
+package symbols
+
+import (
+	"strings"
+	"testing"
+)
+
+const testFile = `; File generated by rgblink
+01:4000 Main
+00:0150 Start
+01:4010 Main.loop ; comment
+00:C000 wPlayerX
+00:FF80 hFrame
+
+02:4000 Other
+`
+
+func TestRead(t *testing.T) {
+	tbl, err := Read(strings.NewReader(testFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tbl.Len() != 6 {
+		t.Fatalf("got %d labels, expected 6", tbl.Len())
+	}
+	if s, ok := tbl.Lookup("Main.loop"); !ok || s.Bank != 1 || s.Address != 0x4010 {
+		t.Errorf("got %v %v for Main.loop", s, ok)
+	}
+	if _, ok := tbl.Lookup("Missing"); ok {
+		t.Error("found an unknown label")
+	}
+
+	for _, line := range []string{"Start", "0150 Start", "XX:0150 Start", "00:X150 Start"} {
+		if _, err := Read(strings.NewReader(line)); err == nil {
+			t.Errorf("%q: expected an error", line)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tbl, _ := Read(strings.NewReader(testFile))
+	tests := []struct {
+		bank int
+		addr uint16
+		exp  string
+	}{
+		{0, 0x0150, "Start"},
+		{0, 0x0153, "Start+3"},
+		{0, 0x0100, ""},
+		{1, 0x4000, "Main"},
+		{1, 0x4012, "Main.loop+2"},
+		{2, 0x4001, "Other+1"},
+		{3, 0x4001, ""},
+		{0, 0xC001, "wPlayerX+1"},
+		{0, 0xD000, ""},
+		{AnyBank, 0x4000, "Main"},
+		{AnyBank, 0x4001, ""},
+	}
+	for _, test := range tests {
+		if got := tbl.Describe(test.bank, test.addr); got != test.exp {
+			t.Errorf("%02X:%04X: got %q, expected %q", test.bank, test.addr, got, test.exp)
+		}
+	}
+
+	var empty *Table
+	if empty.Describe(0, 0x150) != "" || empty.Name(0, 0x150) != "" {
+		t.Error("a nil table returned a label")
+	}
+}
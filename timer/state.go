@@ -0,0 +1,21 @@
+package timer
+
+import "github.com/boombuler/goboy2/savestate"
+
+// SaveState writes the timer registers and the internal divider.
+func (t *Timer) SaveState(w *savestate.Writer) {
+	w.U16(t.div)
+	w.U8(t.tac)
+	w.U8(t.tima)
+	w.U8(t.tma)
+	w.U8(byte(t.overflow))
+}
+
+// LoadState restores a state written by SaveState.
+func (t *Timer) LoadState(r *savestate.Reader) {
+	t.div = r.U16()
+	t.tac = r.U8()
+	t.tima = r.U8()
+	t.tma = r.U8()
+	t.overflow = overflowState(r.U8())
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"github.com/boombuler/goboy2/consts"
+	"github.com/boombuler/goboy2/cpu"
+	"github.com/boombuler/goboy2/trace"
+)
+
+// StartTrace writes the cpu state before every instruction to out, until
+// StopTrace is called or Run returns. It must be called before Run. An instruction hook of the
+// cpu which was set before is still called.
+func (gb *GameBoy) StartTrace(out io.Writer, format trace.Format, ringSize int) {
+	gb.tracer = trace.NewWriter(out, format, ringSize)
+	if gb.symbols != nil {
+		gb.tracer.SetSymbols(gb.symbols, gb.cart.ROMBank)
+	}
+	gb.tracedHook = gb.CPU.OnInstruction
+	gb.CPU.OnInstruction = gb.traceInstruction
+}
+
+// StopTrace writes the remaining lines of the trace. It must not be called while Run executes.
+func (gb *GameBoy) StopTrace() error {
+	if gb.tracer == nil {
+		return nil
+	}
+	gb.CPU.OnInstruction = gb.tracedHook
+	gb.tracedHook = nil
+	err := gb.tracer.Flush()
+	gb.tracer = nil
+	return err
+}
+
+func (gb *GameBoy) traceInstruction(i *cpu.Instruction) {
+	r := &i.Registers
+	s := trace.State{
+		PC: r.PC, SP: r.SP,
+		A: r.A, F: r.F, B: r.B, C: r.C, D: r.D, E: r.E, H: r.H, L: r.L,
+		Cycle: i.Cycle,
+		Bank:  i.Bank,
+		LY:    gb.MMU.Peek(consts.AddrLY),
+	}
+	for j := range s.PCMem {
+		s.PCMem[j] = gb.MMU.Peek(s.PC + uint16(j))
+	}
+	gb.tracer.Trace(s)
+	if gb.tracedHook != nil {
+		gb.tracedHook(i)
+	}
+}
+
+// startTraceFile starts the trace requested by the command line flags. The
+// returned function closes the file, the rest of the trace is already written
+// when Run returned.
+func startTraceFile(gb *GameBoy) func() {
+	if *traceFile == "" {
+		return func() {}
+	}
+	format, err := trace.ParseFormat(*traceFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	f, err := os.Create(*traceFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	gb.StartTrace(f, format, *traceRing)
+	return func() {
+		if err := gb.StopTrace(); err != nil {
+			log.Println("could not write trace:", err)
+		}
+		f.Close()
+	}
+}
@@ -0,0 +1,194 @@
+// Package trace writes the cpu state before every instruction to a file.
+//
+// The doctor format is compatible with gameboy-doctor and the logs of other
+// emulators, so traces can be compared with diff:
+//
+//	A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0100 PCMEM:00,C3,13,02
+//
+// The rich format adds the cycle count, the rom bank, LY and the disassembled
+// instruction. If a symbol file is loaded, addresses are replaced by labels
+// and the label of PC is appended:
+//
+//	12345678 00:0100 LY:00 A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE  NOP ; Start
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/boombuler/goboy2/disasm"
+	"github.com/boombuler/goboy2/symbols"
+)
+
+// Format is the line format of a trace.
+type Format int
+
+const (
+	// Doctor is the format of gameboy-doctor.
+	Doctor Format = iota
+	// Rich contains the cycle count, the rom bank, LY and the instruction.
+	Rich
+)
+
+var formatNames = map[string]Format{
+	"doctor": Doctor,
+	"rich":   Rich,
+}
+
+// ParseFormat returns the format with the given name, "doctor" or "rich".
+func ParseFormat(name string) (Format, error) {
+	if f, ok := formatNames[name]; ok {
+		return f, nil
+	}
+	return 0, fmt.Errorf("unknown trace format %q, expected doctor or rich", name)
+}
+
+// State is the state of the gameboy before an instruction is executed.
+type State struct {
+	PC, SP                 uint16
+	A, F, B, C, D, E, H, L byte
+	// PCMem contains the bytes at PC.
+	PCMem [4]byte
+	// Cycle is the number of m-cycles since the emulation started.
+	Cycle uint64
+	// Bank is the rom bank mapped at PC, or -1 if PC is not in the rom.
+	Bank int
+	LY   byte
+}
+
+// Writer writes a line for every traced instruction. In ring mode only the
+// last lines are kept in memory and written by Flush.
+type Writer struct {
+	out    *bufio.Writer
+	format Format
+	line   []byte
+	err    error
+
+	symbols *symbols.Table
+	names   func(addr uint16) string
+
+	ring [][]byte
+	next int
+	full bool
+}
+
+// NewWriter creates a writer with the given format. If ringSize is greater
+// than 0, only the last ringSize lines are written when Flush is called.
+func NewWriter(out io.Writer, format Format, ringSize int) *Writer {
+	w := &Writer{
+		out:    bufio.NewWriterSize(out, 64*1024),
+		format: format,
+	}
+	if ringSize > 0 {
+		w.ring = make([][]byte, ringSize)
+	}
+	return w
+}
+
+// SetSymbols sets the labels which are used by the rich format. romBank returns
+// the rom bank which is mapped at an address in 0000-7FFF.
+func (w *Writer) SetSymbols(t *symbols.Table, romBank func(addr uint16) int) {
+	w.symbols = t
+	w.names = t.Names(romBank)
+}
+
+// Trace writes the line for an instruction. After a write error all lines are
+// dropped, the error is returned by Flush.
+func (w *Writer) Trace(s State) {
+	if w.err != nil {
+		return
+	}
+	if w.ring != nil {
+		w.ring[w.next] = w.appendLine(w.ring[w.next][:0], s)
+		if w.next++; w.next == len(w.ring) {
+			w.next = 0
+			w.full = true
+		}
+		return
+	}
+	w.line = w.appendLine(w.line[:0], s)
+	_, w.err = w.out.Write(w.line)
+}
+
+// Flush writes the buffered lines. In ring mode the kept lines are written and
+// the ring is cleared.
+func (w *Writer) Flush() error {
+	if w.ring != nil && w.err == nil {
+		if w.full {
+			w.writeLines(w.ring[w.next:])
+		}
+		w.writeLines(w.ring[:w.next])
+		w.next, w.full = 0, false
+	}
+	if w.err == nil {
+		w.err = w.out.Flush()
+	}
+	return w.err
+}
+
+func (w *Writer) writeLines(lines [][]byte) {
+	for _, l := range lines {
+		if w.err == nil {
+			_, w.err = w.out.Write(l)
+		}
+	}
+}
+
+func (w *Writer) appendLine(b []byte, s State) []byte {
+	if w.format == Rich {
+		b = strconv.AppendUint(b, s.Cycle, 10)
+		b = append(b, ' ')
+		if s.Bank < 0 {
+			b = append(b, "--"...)
+		} else {
+			b = appendHex(b, uint16(s.Bank), 2)
+		}
+		b = appendHex(append(b, ':'), s.PC, 4)
+		b = appendHex(append(b, " LY:"...), uint16(s.LY), 2)
+		b = append(b, ' ')
+	}
+	b = appendHex(append(b, "A:"...), uint16(s.A), 2)
+	b = appendHex(append(b, " F:"...), uint16(s.F), 2)
+	b = appendHex(append(b, " B:"...), uint16(s.B), 2)
+	b = appendHex(append(b, " C:"...), uint16(s.C), 2)
+	b = appendHex(append(b, " D:"...), uint16(s.D), 2)
+	b = appendHex(append(b, " E:"...), uint16(s.E), 2)
+	b = appendHex(append(b, " H:"...), uint16(s.H), 2)
+	b = appendHex(append(b, " L:"...), uint16(s.L), 2)
+	b = appendHex(append(b, " SP:"...), s.SP, 4)
+	if w.format == Rich {
+		b = append(b, "  "...)
+		inst := disasm.DecodeBytes(s.PCMem[:], s.PC)
+		if w.symbols == nil {
+			b = append(b, inst.String()...)
+		} else {
+			b = append(b, inst.Format(w.names)...)
+			if label := w.symbols.Describe(s.Bank, s.PC); label != "" {
+				b = append(b, " ; "...)
+				b = append(b, label...)
+			}
+		}
+	} else {
+		b = appendHex(append(b, " PC:"...), s.PC, 4)
+		b = append(b, " PCMEM:"...)
+		for i, v := range s.PCMem {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendHex(b, uint16(v), 2)
+		}
+	}
+	return append(b, '\n')
+}
+
+const hexDigits = "0123456789ABCDEF"
+
+// appendHex appends the lowest digits of v as upper case hex.
+func appendHex(b []byte, v uint16, digits int) []byte {
+	for i := digits - 1; i >= 0; i-- {
+		b = append(b, hexDigits[(v>>(4*uint(i)))&0xF])
+	}
+	return b
+}
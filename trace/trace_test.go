@@ -0,0 +1,88 @@
+package trace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/boombuler/goboy2/symbols"
+)
+
+var bootState = State{
+	PC: 0x0100, SP: 0xFFFE,
+	A: 0x01, F: 0xB0, B: 0x00, C: 0x13, D: 0x00, E: 0xD8, H: 0x01, L: 0x4D,
+	PCMem: [4]byte{0x00, 0xC3, 0x13, 0x02},
+	Cycle: 1234,
+	Bank:  0,
+	LY:    0x90,
+}
+
+func TestFormats(t *testing.T) {
+	tests := []struct {
+		format Format
+		exp    string
+	}{
+		{Doctor, "A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0100 PCMEM:00,C3,13,02\n"},
+		{Rich, "1234 00:0100 LY:90 A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE  NOP\n"},
+	}
+	for _, test := range tests {
+		buf := new(bytes.Buffer)
+		w := NewWriter(buf, test.format, 0)
+		w.Trace(bootState)
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.exp {
+			t.Errorf("format %d: got %q, expected %q", test.format, buf.String(), test.exp)
+		}
+	}
+}
+
+func TestRing(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriter(buf, Doctor, 3)
+	s := bootState
+	for pc := uint16(0); pc < 5; pc++ {
+		s.PC = pc
+		w.Trace(s)
+	}
+	if buf.Len() != 0 {
+		t.Fatal("ring mode wrote lines before Flush")
+	}
+	w.Flush()
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, expected 3", len(lines))
+	}
+	for i, l := range lines {
+		exp := []byte{'0', '0', '0', byte('2' + i)}
+		if !bytes.Contains(l, append([]byte("PC:"), exp...)) {
+			t.Errorf("line %d: got %q, expected PC:%s", i, l, exp)
+		}
+	}
+
+	// the ring is cleared by Flush
+	buf.Reset()
+	w.Flush()
+	if buf.Len() != 0 {
+		t.Errorf("got %q after the second Flush", buf.String())
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	syms, err := symbols.Read(strings.NewReader("00:0100 Start\n00:0150 Init\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	w := NewWriter(buf, Rich, 0)
+	w.SetSymbols(syms, func(addr uint16) int { return int(addr / 0x4000) })
+	s := bootState
+	s.PC = 0x0101
+	s.PCMem = [4]byte{0xC3, 0x50, 0x01, 0xCE}
+	w.Trace(s)
+	w.Flush()
+	if exp := "  JP Init ; Start+1\n"; !strings.HasSuffix(buf.String(), exp) {
+		t.Errorf("got %q, expected the suffix %q", buf.String(), exp)
+	}
+}
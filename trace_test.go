@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/boombuler/goboy2/trace"
+)
+
+func TestTraceRingWrittenOnExit(t *testing.T) {
+	exitChan := make(chan struct{})
+	gb := newTestGameBoy(t, exitChan)
+	out := new(bytes.Buffer)
+	gb.StartTrace(out, trace.Doctor, 10)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		gb.run()
+	}()
+	if !gb.Exec(func() {}) {
+		t.Fatal("the emulation stopped")
+	}
+	if out.Len() != 0 {
+		t.Fatal("the ring was written before the emulation stopped")
+	}
+	close(exitChan)
+	<-done
+	// the state of the opcodes is shared by all cpus, so the cpu has to be
+	// stopped at an instruction before the next test starts.
+	runToInstruction(gb)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 10 {
+		t.Fatalf("got %d lines, expected the last 10 instructions", len(lines))
+	}
+	if gb.tracer != nil {
+		t.Error("the trace was not stopped")
+	}
+}